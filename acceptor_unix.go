@@ -18,6 +18,7 @@ package gnet
 
 import (
 	"runtime"
+	"strings"
 
 	"golang.org/x/sys/unix"
 
@@ -28,8 +29,14 @@ import (
 )
 
 func (el *eventloop) accept0(fd int, _ netpoll.IOEvent, _ netpoll.IOFlags) error {
+	ln := el.listeners[fd]
+	eng := el.engine
 	for {
-		nfd, sa, err := socket.Accept(fd)
+		if el.pauseAccepting() {
+			return nil
+		}
+
+		nfd, sa, err := el.poller.Accept(fd)
 		switch err {
 		case nil:
 		case unix.EAGAIN: // the Accept queue has been drained out, we can return now
@@ -45,8 +52,17 @@ func (el *eventloop) accept0(fd int, _ netpoll.IOEvent, _ netpoll.IOFlags) error
 		}
 
 		remoteAddr := socket.SockaddrToTCPOrUnixAddr(sa)
-		network := el.listeners[fd].network
-		if opts := el.engine.opts; opts.TCPKeepAlive > 0 && network == "tcp" &&
+		network := ln.network
+		if strings.HasPrefix(network, "unix") {
+			remoteAddr = socket.SockaddrToUnixAddr(sa, network)
+		}
+		if eng.limited() {
+			if reason, ok := eng.admit(sa); !ok {
+				el.reject(ln, nfd, remoteAddr, reason)
+				continue
+			}
+		}
+		if opts := ln.opts; opts.TCPKeepAlive > 0 && network == "tcp" &&
 			(runtime.GOOS != "linux" && runtime.GOOS != "freebsd" && runtime.GOOS != "dragonfly") {
 			// TCP keepalive options are not inherited from the listening socket
 			// on platforms other than Linux, FreeBSD, or DragonFlyBSD.
@@ -63,24 +79,41 @@ func (el *eventloop) accept0(fd int, _ netpoll.IOEvent, _ netpoll.IOFlags) error
 			}
 		}
 
-		el := el.engine.eventLoops.next(remoteAddr)
-		c := newStreamConn(network, nfd, el, sa, el.listeners[fd].addr, remoteAddr)
+		el := eng.eventLoops.next(remoteAddr)
+		c := newStreamConn(network, nfd, el, ln, sa, ln.addr, remoteAddr)
+		c.admitted = eng.limited()
+		if config := ln.opts.TLSConfig; config != nil {
+			c.tls = newTLSConn(c, config, false)
+		}
+		if ln.opts.ProxyProtocol != ProxyProtocolOff {
+			c.proxy = &proxyState{}
+		}
 		err = el.poller.Trigger(queue.HighPriority, el.register, c)
 		if err != nil {
 			el.getLogger().Errorf("failed to enqueue the accepted socket fd=%d to poller: %v", c.fd, err)
 			_ = unix.Close(nfd)
 			c.release()
+			continue
 		}
 	}
 }
 
 func (el *eventloop) accept(fd int, ev netpoll.IOEvent, flags netpoll.IOFlags) error {
-	network := el.listeners[fd].network
-	if network == "udp" {
+	ln := el.listeners[fd]
+	network := ln.network
+	switch network {
+	case "udp":
 		return el.readUDP(fd, ev, flags)
+	case "unixgram":
+		return el.readDatagram(fd, ev, flags)
 	}
 
-	nfd, sa, err := socket.Accept(fd)
+	eng := el.engine
+	if el.pauseAccepting() {
+		return nil
+	}
+
+	nfd, sa, err := el.poller.Accept(fd)
 	switch err {
 	case nil:
 	case unix.EINTR, unix.EAGAIN, unix.ECONNRESET, unix.ECONNABORTED:
@@ -94,7 +127,16 @@ func (el *eventloop) accept(fd int, ev netpoll.IOEvent, flags netpoll.IOFlags) e
 	}
 
 	remoteAddr := socket.SockaddrToTCPOrUnixAddr(sa)
-	if opts := el.engine.opts; opts.TCPKeepAlive > 0 && el.listeners[fd].network == "tcp" &&
+	if strings.HasPrefix(network, "unix") {
+		remoteAddr = socket.SockaddrToUnixAddr(sa, network)
+	}
+	if eng.limited() {
+		if reason, ok := eng.admit(sa); !ok {
+			el.reject(ln, nfd, remoteAddr, reason)
+			return nil
+		}
+	}
+	if opts := ln.opts; opts.TCPKeepAlive > 0 && network == "tcp" &&
 		(runtime.GOOS != "linux" && runtime.GOOS != "freebsd" && runtime.GOOS != "dragonfly") {
 		// TCP keepalive options are not inherited from the listening socket
 		// on platforms other than Linux, FreeBSD, or DragonFlyBSD.
@@ -111,6 +153,13 @@ func (el *eventloop) accept(fd int, ev netpoll.IOEvent, flags netpoll.IOFlags) e
 		}
 	}
 
-	c := newStreamConn(network, nfd, el, sa, el.listeners[fd].addr, remoteAddr)
+	c := newStreamConn(network, nfd, el, ln, sa, ln.addr, remoteAddr)
+	c.admitted = eng.limited()
+	if config := ln.opts.TLSConfig; config != nil {
+		c.tls = newTLSConn(c, config, false)
+	}
+	if ln.opts.ProxyProtocol != ProxyProtocolOff {
+		c.proxy = &proxyState{}
+	}
 	return el.register0(c)
 }
@@ -23,7 +23,7 @@ import (
 	"github.com/panjf2000/gnet/v2/pkg/pool/goroutine"
 )
 
-func (eng *engine) listenStream(ln net.Listener) (err error) {
+func (eng *engine) listenStream(l *listener) (err error) {
 	if eng.opts.LockOSThread {
 		runtime.LockOSThread()
 		defer runtime.UnlockOSThread()
@@ -33,7 +33,7 @@ func (eng *engine) listenStream(ln net.Listener) (err error) {
 
 	for {
 		// Accept TCP socket.
-		tc, e := ln.Accept()
+		tc, e := l.ln.Accept()
 		if e != nil {
 			err = e
 			if !eng.beingShutdown.Load() {
@@ -45,6 +45,7 @@ func (eng *engine) listenStream(ln net.Listener) (err error) {
 		}
 		el := eng.eventLoops.next(tc.RemoteAddr())
 		c := newStreamConn(el, tc, nil)
+		c.ln = l
 		el.ch <- &openConn{c: c}
 		goroutine.DefaultWorkerPool.Submit(func() {
 			var buffer [0x10000]byte
@@ -60,7 +61,7 @@ func (eng *engine) listenStream(ln net.Listener) (err error) {
 	}
 }
 
-func (eng *engine) ListenUDP(pc net.PacketConn) (err error) {
+func (eng *engine) ListenUDP(l *listener) (err error) {
 	if eng.opts.LockOSThread {
 		runtime.LockOSThread()
 		defer runtime.UnlockOSThread()
@@ -71,7 +72,7 @@ func (eng *engine) ListenUDP(pc net.PacketConn) (err error) {
 	var buffer [0x10000]byte
 	for {
 		// Read data from UDP socket.
-		n, addr, e := pc.ReadFrom(buffer[:])
+		n, addr, e := l.pc.ReadFrom(buffer[:])
 		if e != nil {
 			err = e
 			if !eng.beingShutdown.Load() {
@@ -82,7 +83,8 @@ func (eng *engine) ListenUDP(pc net.PacketConn) (err error) {
 			return
 		}
 		el := eng.eventLoops.next(addr)
-		c := newUDPConn(el, pc, nil, pc.LocalAddr(), addr, nil)
+		c := newUDPConn(el, l.pc, nil, l.pc.LocalAddr(), addr, nil)
+		c.ln = l
 		el.ch <- packUDPConn(c, buffer[:n])
 	}
 }
@@ -0,0 +1,177 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package gnet
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/panjf2000/gnet/v2/pkg/queue"
+)
+
+// admission keeps track of the accepted connections for MaxConnections and MaxConnectionsPerIP.
+type admission struct {
+	conns  atomic.Int32       // number of the admitted connections
+	mu     sync.Mutex         // protects perIP and paused
+	perIP  map[[16]byte]int32 // number of the admitted connections of each source IP
+	paused []*eventloop       // event-loops that stop accepting for MaxConnections
+}
+
+// admissionKey returns the source IP of sa in the 16-byte form,
+// it reports false for the addresses that are not limited per IP.
+func admissionKey(sa unix.Sockaddr) (key [16]byte, ok bool) {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		copy(key[:], net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]))
+		return key, true
+	case *unix.SockaddrInet6:
+		return sa.Addr, true
+	default:
+		return key, false
+	}
+}
+
+// limited reports whether the accepted connections are subject to any limit.
+func (eng *engine) limited() bool {
+	return eng.opts.MaxConnections > 0 || eng.opts.MaxConnectionsPerIP > 0
+}
+
+// admit checks the connection accepted from sa against the connection limits and counts it in
+// if it's admitted, otherwise the reason of rejection is returned.
+func (eng *engine) admit(sa unix.Sockaddr) (RejectReason, bool) {
+	a := &eng.admission
+	if limit := eng.opts.MaxConnections; limit > 0 && a.conns.Add(1) > int32(limit) {
+		eng.release(nil)
+		return RejectMaxConnections, false
+	}
+
+	if limit := eng.opts.MaxConnectionsPerIP; limit > 0 {
+		if key, ok := admissionKey(sa); ok {
+			a.mu.Lock()
+			if a.perIP[key] >= int32(limit) {
+				a.mu.Unlock()
+				if eng.opts.MaxConnections > 0 {
+					eng.release(nil)
+				}
+				return RejectMaxConnectionsPerIP, false
+			}
+			if a.perIP == nil {
+				a.perIP = make(map[[16]byte]int32)
+			}
+			a.perIP[key]++
+			a.mu.Unlock()
+		}
+	}
+	return 0, true
+}
+
+// release counts out an admitted connection accepted from sa, or just one connection
+// of MaxConnections if sa is nil, and resumes the paused event-loops if there is room.
+func (eng *engine) release(sa unix.Sockaddr) {
+	a := &eng.admission
+	if sa != nil && eng.opts.MaxConnectionsPerIP > 0 {
+		if key, ok := admissionKey(sa); ok {
+			a.mu.Lock()
+			if a.perIP[key]--; a.perIP[key] <= 0 {
+				delete(a.perIP, key)
+			}
+			a.mu.Unlock()
+		}
+	}
+
+	limit := eng.opts.MaxConnections
+	if limit <= 0 {
+		return
+	}
+	if a.conns.Add(-1) >= int32(limit) || eng.opts.RejectPolicy != RejectPause {
+		return
+	}
+	a.mu.Lock()
+	paused := a.paused
+	a.paused = nil
+	a.mu.Unlock()
+	for _, el := range paused {
+		if err := el.poller.Trigger(queue.HighPriority, el.resumeAccepting, nil); err != nil {
+			el.getLogger().Errorf("failed to enqueue resuming accepting to event-loop: %v", err)
+		}
+	}
+}
+
+// pauseAccepting removes the stream-oriented listeners from the poller until there is room for
+// new connections if MaxConnections has been reached under RejectPause, it reports whether
+// accepting is paused.
+func (el *eventloop) pauseAccepting() bool {
+	a, opts := &el.engine.admission, el.engine.opts
+	if opts.RejectPolicy != RejectPause || opts.MaxConnections <= 0 ||
+		a.conns.Load() < int32(opts.MaxConnections) {
+		return false
+	}
+	a.mu.Lock()
+	if a.conns.Load() < int32(opts.MaxConnections) {
+		a.mu.Unlock()
+		return false
+	}
+	a.paused = append(a.paused, el)
+	a.mu.Unlock()
+
+	el.acceptPaused = true
+	_ = el.stopAccepting(nil)
+	return true
+}
+
+// resumeAccepting adds the stream-oriented listeners removed by pauseAccepting back to the poller.
+func (el *eventloop) resumeAccepting(_ any) error {
+	if !el.acceptPaused {
+		return nil
+	}
+	el.acceptPaused = false
+	if el.engine.inDrain.Load() {
+		return nil
+	}
+	// The ingress event-loop of the reactor mode accepts connections in edge-triggered mode.
+	edgeTriggered := el == el.engine.ingress
+	for _, ln := range el.listeners {
+		if ln.isDatagram() {
+			continue
+		}
+		if err := el.poller.AddRead(ln.pollAttachment, edgeTriggered); err != nil && !errors.Is(err, unix.EEXIST) {
+			el.getLogger().Errorf("failed to resume accepting on listener(%s://%s): %v", ln.network, ln.address, err)
+		}
+	}
+	return nil
+}
+
+// reject closes the connection nfd accepted from addr by ln after writing RejectResponse,
+// and then fires OnReject.
+func (el *eventloop) reject(ln *listener, nfd int, addr net.Addr, reason RejectReason) {
+	if resp := ln.opts.RejectResponse; len(resp) > 0 {
+		_, _ = unix.Write(nfd, resp)
+	}
+	_ = unix.Close(nfd)
+
+	h := ln.handler
+	if h == nil {
+		h = el.eventHandler
+	}
+	if h, ok := h.(RejectHandler); ok {
+		h.OnReject(addr, reason)
+	}
+}
@@ -18,6 +18,7 @@ package gnet
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"strconv"
@@ -30,7 +31,6 @@ import (
 	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
 	"github.com/panjf2000/gnet/v2/pkg/logging"
 	"github.com/panjf2000/gnet/v2/pkg/math"
-	"github.com/panjf2000/gnet/v2/pkg/netpoll"
 	"github.com/panjf2000/gnet/v2/pkg/queue"
 	"github.com/panjf2000/gnet/v2/pkg/socket"
 )
@@ -111,7 +111,7 @@ func (cli *Client) Start() error {
 
 	var el0 *eventloop
 	for i := 0; i < numEventLoop; i++ {
-		p, err := netpoll.OpenPoller()
+		p, err := openPoller(cli.opts)
 		if err != nil {
 			cli.eng.closeEventLoops()
 			return err
@@ -187,7 +187,15 @@ func (cli *Client) DialContext(network, address string, ctx any) (Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return cli.EnrollContext(c, ctx)
+	config := cli.opts.TLSConfig
+	if config != nil && config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			// Make a copy to avoid polluting the shared config, as tls.Dial does.
+			config = config.Clone()
+			config.ServerName = host
+		}
+	}
+	return cli.enroll(c, ctx, config)
 }
 
 // Enroll converts a net.Conn to gnet.Conn and then adds it into the Client.
@@ -197,6 +205,10 @@ func (cli *Client) Enroll(c net.Conn) (Conn, error) {
 
 // EnrollContext is like Enroll but also accepts an empty interface ctx that can be obtained later via Conn.Context.
 func (cli *Client) EnrollContext(c net.Conn, ctx any) (Conn, error) {
+	return cli.enroll(c, ctx, cli.opts.TLSConfig)
+}
+
+func (cli *Client) enroll(c net.Conn, ctx any, tlsConfig *tls.Config) (Conn, error) {
 	defer c.Close() //nolint:errcheck
 
 	sc, ok := c.(syscall.Conn)
@@ -237,13 +249,18 @@ func (cli *Client) EnrollContext(c net.Conn, ctx any) (Conn, error) {
 	)
 	switch c.(type) {
 	case *net.UnixConn:
-		sockAddr, _, _, err = socket.GetUnixSockAddr(c.RemoteAddr().Network(), c.RemoteAddr().String())
+		network := c.RemoteAddr().Network()
+		sockAddr, _, _, err = socket.GetUnixSockAddr(network, c.RemoteAddr().String())
 		if err != nil {
 			return nil, err
 		}
+		if network == "unixgram" {
+			gc = newUDPConn(dupFD, el, nil, c.LocalAddr(), sockAddr, true)
+			break
+		}
 		ua := c.LocalAddr().(*net.UnixAddr)
 		ua.Name = c.RemoteAddr().String() + "." + strconv.Itoa(dupFD)
-		gc = newStreamConn("unix", dupFD, el, sockAddr, c.LocalAddr(), c.RemoteAddr())
+		gc = newStreamConn(network, dupFD, el, nil, sockAddr, c.LocalAddr(), c.RemoteAddr())
 	case *net.TCPConn:
 		if cli.opts.TCPNoDelay == TCPNoDelay {
 			if err = socket.SetNoDelay(dupFD, 1); err != nil {
@@ -264,18 +281,35 @@ func (cli *Client) EnrollContext(c net.Conn, ctx any) (Conn, error) {
 		if err != nil {
 			return nil, err
 		}
-		gc = newStreamConn("tcp", dupFD, el, sockAddr, c.LocalAddr(), c.RemoteAddr())
+		gc = newStreamConn("tcp", dupFD, el, nil, sockAddr, c.LocalAddr(), c.RemoteAddr())
 	case *net.UDPConn:
+		if cli.opts.UDPGRO {
+			if cli.opts.ReadBufferCap < minUDPGROBufferCap {
+				return nil, errorx.ErrTooSmallReadBuffer
+			}
+			if err = socket.SetUDPGRO(dupFD, 1); err != nil {
+				return nil, err
+			}
+		}
+		if cli.opts.UDPPacketInfo {
+			if err = socket.SetUDPPacketInfo(dupFD, 1); err != nil {
+				return nil, err
+			}
+		}
 		sockAddr, _, _, _, err = socket.GetUDPSockAddr(c.RemoteAddr().Network(), c.RemoteAddr().String())
 		if err != nil {
 			return nil, err
 		}
-		gc = newUDPConn(dupFD, el, c.LocalAddr(), sockAddr, true)
+		gc = newUDPConn(dupFD, el, nil, c.LocalAddr(), sockAddr, true)
 	default:
 		return nil, errorx.ErrUnsupportedProtocol
 	}
 	gc.SetContext(ctx)
 	gc.SetSafeContext(ctx)
+	if tlsConfig != nil && !gc.isDatagram {
+		gc.tls = newTLSConn(gc, tlsConfig, true)
+	}
+	tc := gc.tls
 
 	connOpened := make(chan struct{})
 	ccb := &connWithCallback{c: gc, cb: func() {
@@ -287,6 +321,9 @@ func (cli *Client) EnrollContext(c net.Conn, ctx any) (Conn, error) {
 		return nil, err
 	}
 	<-connOpened
+	if tc != nil && tc.handshakeErr != nil {
+		return nil, tc.handshakeErr
+	}
 
 	return gc, nil
 }
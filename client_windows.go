@@ -32,6 +32,9 @@ type Client struct {
 
 func NewClient(eh EventHandler, opts ...Option) (cli *Client, err error) {
 	options := loadOptions(opts...)
+	if options.TLSConfig != nil || options.HalfClose {
+		return nil, errorx.ErrUnsupportedOp
+	}
 	cli = &Client{opts: options}
 
 	logger, logFlusher := logging.GetDefaultLogger(), logging.GetDefaultFlusher()
@@ -0,0 +1,317 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+)
+
+// decodeTraffic decodes all the whole messages buffered in c with codec
+// and dispatches them to OnMessage, it's used in place of OnTraffic.
+// A non-nil error indicates that the connection ought to be closed with it.
+func decodeTraffic(h MessageHandler, codec Codec, c Conn) (Action, error) {
+	for {
+		buffered := c.InboundBuffered()
+		msg, err := codec.Decode(c)
+		if errors.Is(err, errorx.ErrIncompletePacket) {
+			return None, nil
+		}
+		if err != nil {
+			return Close, err
+		}
+		// Decoding the same data over and over again would never end.
+		if c.InboundBuffered() >= buffered {
+			return Close, errorx.ErrDecodeNoProgress
+		}
+
+		out, action := h.OnMessage(c, msg)
+		if out != nil {
+			if out, err = codec.Encode(c, out); err != nil {
+				return Close, err
+			}
+			if _, err = c.Write(out); err != nil {
+				return Close, err
+			}
+		}
+		if action != None {
+			return action, nil
+		}
+	}
+}
+
+// FixedLengthFrameCodec splits the inbound data into messages of a fixed length.
+type FixedLengthFrameCodec struct {
+	frameLength int
+}
+
+// NewFixedLengthFrameCodec instantiates and returns a FixedLengthFrameCodec with the given frame length.
+func NewFixedLengthFrameCodec(frameLength int) *FixedLengthFrameCodec {
+	return &FixedLengthFrameCodec{frameLength}
+}
+
+// Encode validates that the length of msg is a multiple of the frame length and returns msg as it is.
+func (cc *FixedLengthFrameCodec) Encode(_ Conn, msg []byte) ([]byte, error) {
+	if cc.frameLength <= 0 || len(msg)%cc.frameLength != 0 {
+		return nil, errorx.ErrInvalidFixedLength
+	}
+	return msg, nil
+}
+
+// Decode decodes a message of the fixed length.
+func (cc *FixedLengthFrameCodec) Decode(c Conn) ([]byte, error) {
+	if cc.frameLength <= 0 {
+		return nil, errorx.ErrInvalidFixedLength
+	}
+	if c.InboundBuffered() < cc.frameLength {
+		return nil, errorx.ErrIncompletePacket
+	}
+	return c.Next(cc.frameLength)
+}
+
+// DelimiterBasedFrameCodec splits the inbound data into messages by a delimiter.
+type DelimiterBasedFrameCodec struct {
+	delimiter      byte
+	maxFrameLength int
+}
+
+// NewDelimiterBasedFrameCodec instantiates and returns a DelimiterBasedFrameCodec with the given
+// delimiter and maxFrameLength, which is the maximum length of a message without the delimiter.
+// The decoding fails right away once more than that has been buffered without a delimiter,
+// there is no limit if it's 0.
+func NewDelimiterBasedFrameCodec(delimiter byte, maxFrameLength int) *DelimiterBasedFrameCodec {
+	return &DelimiterBasedFrameCodec{delimiter, maxFrameLength}
+}
+
+// Encode appends the delimiter to msg.
+func (cc *DelimiterBasedFrameCodec) Encode(_ Conn, msg []byte) ([]byte, error) {
+	out := make([]byte, len(msg)+1)
+	copy(out, msg)
+	out[len(msg)] = cc.delimiter
+	return out, nil
+}
+
+// Decode decodes a message up to the delimiter, the delimiter is stripped from the message.
+func (cc *DelimiterBasedFrameCodec) Decode(c Conn) ([]byte, error) {
+	buf, _ := c.Peek(-1)
+	idx := bytes.IndexByte(buf, cc.delimiter)
+	if idx < 0 {
+		if cc.maxFrameLength > 0 && len(buf) > cc.maxFrameLength {
+			return nil, errorx.ErrTooLongFrame
+		}
+		return nil, errorx.ErrIncompletePacket
+	}
+	if cc.maxFrameLength > 0 && idx > cc.maxFrameLength {
+		return nil, errorx.ErrTooLongFrame
+	}
+	msg, err := c.Next(idx + 1)
+	if err != nil {
+		return nil, err
+	}
+	return msg[:idx], nil
+}
+
+// EncoderConfig is the config of the encoder of LengthFieldBasedFrameCodec.
+type EncoderConfig struct {
+	// ByteOrder is the byte order of the length field, big endian is used if it's nil.
+	ByteOrder binary.ByteOrder
+	// LengthFieldLength is the length of the length field in bytes, 1, 2, 3, 4, or 8.
+	LengthFieldLength int
+	// LengthAdjustment is the value added to the length of the message when writing the length field.
+	LengthAdjustment int
+	// LengthIncludesLengthFieldLength indicates whether the length of the length field
+	// is added to the value of the length field.
+	LengthIncludesLengthFieldLength bool
+}
+
+// DecoderConfig is the config of the decoder of LengthFieldBasedFrameCodec.
+type DecoderConfig struct {
+	// ByteOrder is the byte order of the length field, big endian is used if it's nil.
+	ByteOrder binary.ByteOrder
+	// LengthFieldOffset is the offset of the length field in bytes.
+	LengthFieldOffset int
+	// LengthFieldLength is the length of the length field in bytes, 1, 2, 3, 4, or 8.
+	LengthFieldLength int
+	// LengthAdjustment is the value added to the value of the length field to get the
+	// length of the rest of the frame after the length field.
+	LengthAdjustment int
+	// InitialBytesToStrip is the number of bytes stripped from the beginning of the frame.
+	InitialBytesToStrip int
+	// MaxFrameLength is the maximum length of a frame including the header, a frame whose
+	// length field claims more than that fails the decoding right away rather than being
+	// buffered until it's complete. There is no limit other than math.MaxInt32 if it's 0.
+	MaxFrameLength int
+}
+
+// LengthFieldBasedFrameCodec splits the inbound data into messages by the value of the
+// length field in the header of messages, it's modeled after the LengthFieldBasedFrameDecoder
+// and LengthFieldPrepender of Netty.
+type LengthFieldBasedFrameCodec struct {
+	encoderConfig EncoderConfig
+	decoderConfig DecoderConfig
+	decoderErr    error // error of an invalid DecoderConfig, which fails every Decode
+}
+
+// NewLengthFieldBasedFrameCodec instantiates and returns a LengthFieldBasedFrameCodec
+// with the given configs. An invalid DecoderConfig fails every Decode with an error,
+// thus the connections are closed on their first inbound data.
+func NewLengthFieldBasedFrameCodec(ec EncoderConfig, dc DecoderConfig) *LengthFieldBasedFrameCodec {
+	if ec.ByteOrder == nil {
+		ec.ByteOrder = binary.BigEndian
+	}
+	if dc.ByteOrder == nil {
+		dc.ByteOrder = binary.BigEndian
+	}
+	return &LengthFieldBasedFrameCodec{encoderConfig: ec, decoderConfig: dc, decoderErr: dc.validate()}
+}
+
+// validate reports the fields of the config that can't be decoded with.
+func (dc *DecoderConfig) validate() error {
+	switch dc.LengthFieldLength {
+	case 1, 2, 3, 4, 8:
+	default:
+		return errorx.ErrUnsupportedLength
+	}
+	if dc.LengthFieldOffset < 0 || dc.InitialBytesToStrip < 0 {
+		return errorx.ErrNegativeOffset
+	}
+	return nil
+}
+
+// Encode prepends the length field to msg.
+func (cc *LengthFieldBasedFrameCodec) Encode(_ Conn, msg []byte) ([]byte, error) {
+	ec := &cc.encoderConfig
+	length := int64(len(msg)) + int64(ec.LengthAdjustment)
+	if ec.LengthIncludesLengthFieldLength {
+		length += int64(ec.LengthFieldLength)
+	}
+	if length < 0 {
+		return nil, errorx.ErrTooLessLength
+	}
+
+	var header [8]byte
+	switch ec.LengthFieldLength {
+	case 1:
+		if length > math.MaxUint8 {
+			return nil, errorx.ErrTooLargeLength
+		}
+		header[0] = byte(length)
+	case 2:
+		if length > math.MaxUint16 {
+			return nil, errorx.ErrTooLargeLength
+		}
+		ec.ByteOrder.PutUint16(header[:], uint16(length))
+	case 3:
+		if length > 1<<24-1 {
+			return nil, errorx.ErrTooLargeLength
+		}
+		putUint24(ec.ByteOrder, header[:], uint32(length))
+	case 4:
+		if length > math.MaxUint32 {
+			return nil, errorx.ErrTooLargeLength
+		}
+		ec.ByteOrder.PutUint32(header[:], uint32(length))
+	case 8:
+		ec.ByteOrder.PutUint64(header[:], uint64(length))
+	default:
+		return nil, errorx.ErrUnsupportedLength
+	}
+	out := make([]byte, 0, ec.LengthFieldLength+len(msg))
+	out = append(out, header[:ec.LengthFieldLength]...)
+	return append(out, msg...), nil
+}
+
+// Decode decodes a message by the value of the length field.
+func (cc *LengthFieldBasedFrameCodec) Decode(c Conn) ([]byte, error) {
+	if cc.decoderErr != nil {
+		return nil, cc.decoderErr
+	}
+	dc := &cc.decoderConfig
+	headerLen := dc.LengthFieldOffset + dc.LengthFieldLength
+	header, err := c.Peek(headerLen)
+	if err != nil {
+		return nil, errorx.ErrIncompletePacket
+	}
+
+	var length uint64
+	field := header[dc.LengthFieldOffset:]
+	switch dc.LengthFieldLength {
+	case 1:
+		length = uint64(field[0])
+	case 2:
+		length = uint64(dc.ByteOrder.Uint16(field))
+	case 3:
+		length = uint64(uint24(dc.ByteOrder, field))
+	case 4:
+		length = uint64(dc.ByteOrder.Uint32(field))
+	case 8:
+		length = dc.ByteOrder.Uint64(field)
+	default:
+		return nil, errorx.ErrUnsupportedLength
+	}
+	if length > math.MaxInt32 {
+		return nil, errorx.ErrTooLargeLength
+	}
+
+	frameLen := int64(headerLen) + int64(length) + int64(dc.LengthAdjustment)
+	if frameLen < int64(headerLen) || frameLen < int64(dc.InitialBytesToStrip) {
+		return nil, errorx.ErrTooLessLength
+	}
+	if frameLen > math.MaxInt32 {
+		return nil, errorx.ErrTooLargeLength
+	}
+	if dc.MaxFrameLength > 0 && frameLen > int64(dc.MaxFrameLength) {
+		return nil, errorx.ErrTooLongFrame
+	}
+	if int64(c.InboundBuffered()) < frameLen {
+		return nil, errorx.ErrIncompletePacket
+	}
+	frame, err := c.Next(int(frameLen))
+	if err != nil {
+		return nil, err
+	}
+	return frame[dc.InitialBytesToStrip:], nil
+}
+
+// lowByteFirst reports whether order puts the least significant byte first.
+func lowByteFirst(order binary.ByteOrder) bool {
+	var b [4]byte
+	order.PutUint32(b[:], 1)
+	return b[0] == 1
+}
+
+func uint24(order binary.ByteOrder, b []byte) uint32 {
+	var buf [4]byte
+	if lowByteFirst(order) {
+		copy(buf[:3], b[:3])
+	} else {
+		copy(buf[1:], b[:3])
+	}
+	return order.Uint32(buf[:])
+}
+
+func putUint24(order binary.ByteOrder, b []byte, v uint32) {
+	var buf [4]byte
+	order.PutUint32(buf[:], v)
+	if lowByteFirst(order) {
+		copy(b[:3], buf[:3])
+	} else {
+		copy(b[:3], buf[1:])
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+import "time"
+
+// ConnStats is the snapshot of statistics of a connection.
+type ConnStats struct {
+	// BytesRead is the number of bytes read from the socket, including the ciphertext of TLS.
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to the socket, including the ciphertext of TLS.
+	BytesWritten uint64
+	// Reads is the number of reads that got data from the socket.
+	Reads uint64
+	// Writes is the number of writes that put data into the socket.
+	Writes uint64
+	// OpenedAt is the time when the connection was added to its event-loop.
+	OpenedAt time.Time
+	// LastActive is the time of the latest read or write, it's OpenedAt if there is none.
+	LastActive time.Time
+	// InboundBuffered is the number of bytes that have been read but not consumed yet.
+	InboundBuffered int
+	// OutboundBuffered is the number of bytes that are pending to be written.
+	OutboundBuffered int
+	// TCPInfo is the TCP_INFO of the kernel, it's only available for TCP connections
+	// on Linux, and it's nil otherwise.
+	TCPInfo *TCPInfo
+}
+
+// TCPInfo is a subset of the kernel TCP_INFO of a TCP connection.
+type TCPInfo struct {
+	// State is the state of the connection in the TCP state machine, e.g., 1 for ESTABLISHED.
+	State uint8
+	// RTT is the smoothed round-trip time.
+	RTT time.Duration
+	// RTTVar is the variance of the round-trip time.
+	RTTVar time.Duration
+	// SndCwnd is the size of the congestion window in packets.
+	SndCwnd uint32
+	// SndSsthresh is the slow start threshold in packets.
+	SndSsthresh uint32
+	// Unacked is the number of packets that have been sent but not acknowledged yet.
+	Unacked uint32
+	// Lost is the number of packets that are considered lost.
+	Lost uint32
+	// Retransmits is the number of consecutive retransmission timeouts of the current packet.
+	Retransmits uint8
+	// TotalRetrans is the total number of retransmitted packets.
+	TotalRetrans uint32
+}
+
+// connStats holds the statistics of a connection, it's only accessed on the event-loop.
+type connStats struct {
+	bytesRead    uint64
+	bytesWritten uint64
+	reads        uint64
+	writes       uint64
+	openedAt     time.Time
+	lastActive   time.Time
+}
+
+func (s *connStats) open(now time.Time) {
+	s.openedAt = now
+	s.lastActive = s.openedAt
+}
+
+func (s *connStats) read(n int, now time.Time) {
+	if n <= 0 {
+		return
+	}
+	s.reads++
+	s.bytesRead += uint64(n)
+	s.lastActive = now
+}
+
+func (s *connStats) written(n int, now time.Time) {
+	if n <= 0 {
+		return
+	}
+	s.writes++
+	s.bytesWritten += uint64(n)
+	s.lastActive = now
+}
+
+func (s *connStats) snapshot() ConnStats {
+	return ConnStats{
+		BytesRead:    s.bytesRead,
+		BytesWritten: s.bytesWritten,
+		Reads:        s.reads,
+		Writes:       s.writes,
+		OpenedAt:     s.openedAt,
+		LastActive:   s.lastActive,
+	}
+}
@@ -18,10 +18,13 @@ package gnet
 
 import (
 	"io"
+	"os"
 
 	"golang.org/x/sys/unix"
 
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
 	"github.com/panjf2000/gnet/v2/pkg/netpoll"
+	bsPool "github.com/panjf2000/gnet/v2/pkg/pool/byteslice"
 )
 
 func (c *conn) processIO(_ int, filter netpoll.IOEvent, flags netpoll.IOFlags) (err error) {
@@ -55,3 +58,96 @@ func (c *conn) processIO(_ int, filter netpoll.IOEvent, flags netpoll.IOFlags) (
 	}
 	return
 }
+
+// tcpInfo returns nil since TCP_INFO is only supported on Linux.
+func tcpInfo(int) *TCPInfo {
+	return nil
+}
+
+// sendFile sends up to size bytes of f from *offset to the socket fd,
+// it falls back to reading the file and writing the data to the socket.
+func sendFile(fd int, f *os.File, offset *int64, size int) (n int, err error) {
+	if size > maxSendFileChunk {
+		size = maxSendFileChunk
+	}
+	buf := bsPool.Get(size)
+	defer bsPool.Put(buf)
+
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	if err1 := rc.Control(func(ffd uintptr) {
+		n, err = unix.Pread(int(ffd), buf, *offset)
+	}); err1 != nil {
+		return 0, err1
+	}
+	if n <= 0 {
+		return 0, err
+	}
+	if n, err = unix.Write(fd, buf[:n]); n > 0 {
+		*offset += int64(n)
+	}
+	return
+}
+
+// maxSendFileChunk is the size of the buffer for sending files.
+const maxSendFileChunk = 64 << 10
+
+// splice moves up to n bytes from the socket of src to dst by copying them
+// since splice(2) is only available on Linux.
+func (el *eventloop) splice(src, dst *conn, n int) (int, error) {
+	return el.spliceCopy(src, dst, n)
+}
+
+// enableZeroCopy always fails since MSG_ZEROCOPY is only available on Linux.
+func enableZeroCopy(_ int) error {
+	return errorx.ErrUnsupportedOp
+}
+
+// writeZeroCopy always falls back to the normal write since MSG_ZEROCOPY is only available on Linux.
+func (*conn) writeZeroCopy(_ *asyncWriteHook) (bool, error) {
+	return false, nil
+}
+
+// readZeroCopyCompletions has nothing to read since MSG_ZEROCOPY is only available on Linux.
+func (*conn) readZeroCopyCompletions() bool {
+	return true
+}
+
+// sendGSO sends buf to sa in datagrams of segmentSize bytes, it splits buf by itself
+// since UDP_SEGMENT is only available on Linux.
+func (c *conn) sendGSO(buf []byte, segmentSize int, sa unix.Sockaddr) (n int, err error) {
+	for n < len(buf) {
+		end := n + segmentSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		if err = unix.Sendto(c.fd, buf[n:end], 0, sa); err != nil {
+			return
+		}
+		c.stats.written(end-n, c.loop.poller.Now())
+		c.loop.metrics.addWritten(end - n)
+		n = end
+	}
+	return
+}
+
+// msgCmsgCloexec is 0 since MSG_CMSG_CLOEXEC is not available on macOS,
+// the received file descriptors are set close-on-exec after they're received.
+const msgCmsgCloexec = 0
+
+// parsePacketInfo always returns the zero value since the ancillary data
+// of datagrams is only received on Linux at the moment.
+func parsePacketInfo(_ []byte) PacketInfo {
+	return PacketInfo{}
+}
+
+// sendMsg sends buf to sa, it fails if there is any ancillary data in info
+// since it's only supported on Linux at the moment.
+func (c *conn) sendMsg(buf []byte, sa unix.Sockaddr, info *PacketInfo) (int, error) {
+	if info.LocalIP != nil || info.IfIndex != 0 || info.TOS != 0 {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	return c.sendTo(buf, sa)
+}
@@ -19,17 +19,32 @@ package gnet
 
 import (
 	"io"
+	"net"
+	"os"
+	"time"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/panjf2000/gnet/v2/pkg/netpoll"
+	bsPool "github.com/panjf2000/gnet/v2/pkg/pool/byteslice"
+	"github.com/panjf2000/gnet/v2/pkg/queue"
 )
 
 func (c *conn) processIO(_ int, ev netpoll.IOEvent, _ netpoll.IOFlags) error {
 	el := c.loop
+	// The completions of writes with MSG_ZEROCOPY are reported via EPOLLERR,
+	// it's not an error of the connection unless the socket says so.
+	if ev&unix.EPOLLERR != 0 && len(c.zeroCopyWrites) > 0 && c.readZeroCopyCompletions() {
+		if ev &^= unix.EPOLLERR; ev == 0 || !c.opened {
+			return nil
+		}
+	}
 	// First check for any unexpected non-IO events.
 	// For these events we just close the connection directly.
-	if ev&(netpoll.ErrEvents|unix.EPOLLRDHUP) != 0 && ev&netpoll.ReadWriteEvents == 0 {
+	// The EPOLLRDHUP is left to eventloop.read in the half-close mode.
+	if ev&(netpoll.ErrEvents|unix.EPOLLRDHUP) != 0 && ev&netpoll.ReadWriteEvents == 0 &&
+		(ev&netpoll.ErrEvents != 0 || !c.options().HalfClose) {
 		c.outboundBuffer.Release() // don't bother to write to a connection that is already broken
 		return el.close(c, io.EOF)
 	}
@@ -58,7 +73,7 @@ func (c *conn) processIO(_ int, ev netpoll.IOEvent, _ netpoll.IOFlags) error {
 	// Ultimately, check for EPOLLRDHUP, this event indicates that the remote has
 	// either closed connection or shut down the writing half of the connection.
 	if ev&unix.EPOLLRDHUP != 0 && c.opened {
-		if ev&unix.EPOLLIN == 0 { // unreadable EPOLLRDHUP, close the connection directly
+		if ev&unix.EPOLLIN == 0 && !c.options().HalfClose { // unreadable EPOLLRDHUP, close the connection directly
 			return el.close(c, io.EOF)
 		}
 		// Received the event of EPOLLIN|EPOLLRDHUP, but the previous eventloop.read
@@ -68,3 +83,356 @@ func (c *conn) processIO(_ int, ev netpoll.IOEvent, _ netpoll.IOFlags) error {
 	}
 	return nil
 }
+
+// tcpInfo returns the TCP_INFO of the TCP socket, or nil if it's unavailable.
+func tcpInfo(fd int) *TCPInfo {
+	info, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+	if err != nil {
+		return nil
+	}
+	return &TCPInfo{
+		State:        info.State,
+		RTT:          time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:       time.Duration(info.Rttvar) * time.Microsecond,
+		SndCwnd:      info.Snd_cwnd,
+		SndSsthresh:  info.Snd_ssthresh,
+		Unacked:      info.Unacked,
+		Lost:         info.Lost,
+		Retransmits:  info.Retransmits,
+		TotalRetrans: info.Total_retrans,
+	}
+}
+
+// sendFile sends up to size bytes of f from *offset to the socket fd with sendfile(2).
+func sendFile(fd int, f *os.File, offset *int64, size int) (n int, err error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	if err1 := rc.Control(func(ffd uintptr) {
+		n, err = unix.Sendfile(fd, int(ffd), offset, size)
+	}); err1 != nil {
+		return 0, err1
+	}
+	return
+}
+
+// maxSpliceSize is the maximum number of bytes spliced at a time, which is the default capacity of a pipe.
+const maxSpliceSize = 64 << 10
+
+// splice moves up to n bytes from the socket of src to the socket of dst through a pipe
+// with splice(2), n < 0 means no limit. It stops once dst can't take any more data right away
+// or it has been closed.
+func (el *eventloop) splice(src, dst *conn, n int) (total int, err error) {
+	if dst.hasPendingOutbound() {
+		return el.spliceCopy(src, dst, n) // the data is queued behind the pending data anyway
+	}
+	if src.pollAttachment.Submission != netpoll.SubmitNone {
+		return el.spliceCopy(src, dst, n) // the data has been received by the io_uring-based poller
+	}
+	if el.pipe == nil {
+		var p [2]int
+		if err = unix.Pipe2(p[:], unix.O_NONBLOCK|unix.O_CLOEXEC); err != nil {
+			return 0, os.NewSyscallError("pipe2", err)
+		}
+		el.pipe = p[:]
+	}
+
+	for (n < 0 || total < n) && dst.opened && !dst.hasPendingOutbound() {
+		size := maxSpliceSize
+		if n >= 0 && n-total < size {
+			size = n - total
+		}
+		m, err := unix.Splice(src.fd, nil, el.pipe[1], nil, size, unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+		if err == unix.EAGAIN || m == 0 {
+			break // the EOF is left to the next read
+		}
+		if err != nil {
+			return total, os.NewSyscallError("splice", err)
+		}
+		src.markRead(int(m))
+		moved, err := el.drainPipe(src, dst, int(m))
+		total += moved
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// drainPipe moves n bytes in the pipe to dst and returns the number of bytes moved, the
+// bytes that can't be sent right away are moved to the outbound buffer of dst. If dst
+// fails, it's closed and the bytes left in the pipe are handed back to src.
+func (el *eventloop) drainPipe(src, dst *conn, n int) (int, error) {
+	moved := n
+	for n > 0 {
+		m, err := unix.Splice(el.pipe[0], nil, dst.fd, nil, n, unix.SPLICE_F_MOVE|unix.SPLICE_F_NONBLOCK)
+		if err == unix.EAGAIN {
+			el.metrics.addWriteEAGAIN()
+			break
+		}
+		if err != nil {
+			// Empty the pipe for the subsequent splices, the data is yet to be consumed.
+			if buf, err := el.readPipe(n); err == nil {
+				_, _ = src.inboundBuffer.Write(buf)
+				bsPool.Put(buf)
+			}
+			if err = el.close(dst, os.NewSyscallError("splice", err)); err != nil {
+				return moved - n, err
+			}
+			return moved - n, net.ErrClosed
+		}
+		dst.markWritten(int(m))
+		n -= int(m)
+	}
+	if n == 0 {
+		return moved, nil
+	}
+
+	buf, err := el.readPipe(n)
+	if err != nil {
+		return moved - n, err
+	}
+	_, _ = dst.outboundBuffer.Write(buf)
+	bsPool.Put(buf)
+	dst.outboundChanged()
+	if el.engine.opts.EdgeTriggeredIO {
+		return moved, nil
+	}
+	return moved, dst.pollEvents()
+}
+
+// readPipe reads n bytes from the pipe.
+func (el *eventloop) readPipe(n int) ([]byte, error) {
+	buf := bsPool.Get(n)
+	for off := 0; off < n; {
+		m, err := unix.Read(el.pipe[0], buf[off:])
+		if err != nil {
+			bsPool.Put(buf)
+			return nil, os.NewSyscallError("read", err)
+		}
+		off += m
+	}
+	return buf, nil
+}
+
+// enableZeroCopy enables SO_ZEROCOPY on the socket fd for writes with MSG_ZEROCOPY.
+func enableZeroCopy(fd int) error {
+	return os.NewSyscallError("setsockopt", unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ZEROCOPY, 1))
+}
+
+// writeZeroCopy sends the data of hook with MSG_ZEROCOPY, it reports whether the data
+// has been sent, in which case the callback of hook is invoked once the kernel completes
+// the write. It falls back to the normal write by reporting false with a nil error.
+func (c *conn) writeZeroCopy(hook *asyncWriteHook) (bool, error) {
+	n, err := unix.SendmsgN(c.fd, hook.data, nil, nil, unix.MSG_ZEROCOPY)
+	switch err {
+	case nil:
+	case unix.EAGAIN, unix.ENOBUFS:
+		// The socket buffer is full or the kernel can't pin any more pages.
+		return false, nil
+	default:
+		_ = c.loop.close(c, os.NewSyscallError("sendmsg", err))
+		return false, err
+	}
+
+	c.markWritten(n)
+	c.zeroCopyWrites = append(c.zeroCopyWrites, &zeroCopyWrite{c.zeroCopySeq, hook.data, hook.callback})
+	c.zeroCopySeq++
+	if n == len(hook.data) {
+		return true, nil
+	}
+
+	// Failed to send all data back to the remote, buffer the leftover data for the next round.
+	_, _ = c.outboundBuffer.Write(hook.data[n:])
+	c.outboundChanged()
+	if c.loop.engine.opts.EdgeTriggeredIO {
+		return true, c.loop.poller.Trigger(queue.HighPriority, c.loop.write0, c)
+	}
+	return true, c.pollEvents()
+}
+
+// readZeroCopyCompletions reads the completion notifications of writes with MSG_ZEROCOPY
+// from the error queue of the socket and invokes the callbacks of the completed writes,
+// it reports whether there is no other error pending on the socket.
+func (c *conn) readZeroCopyCompletions() bool {
+	var oob [128]byte
+	for c.opened {
+		_, oobn, _, _, err := unix.Recvmsg(c.fd, nil, oob[:], unix.MSG_ERRQUEUE)
+		if err != nil {
+			break // the error queue has been drained
+		}
+		cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			continue
+		}
+		for _, cmsg := range cmsgs {
+			if (cmsg.Header.Level != unix.SOL_IP || cmsg.Header.Type != unix.IP_RECVERR) &&
+				(cmsg.Header.Level != unix.SOL_IPV6 || cmsg.Header.Type != unix.IPV6_RECVERR) {
+				continue
+			}
+			if len(cmsg.Data) < int(unsafe.Sizeof(unix.SockExtendedErr{})) {
+				continue
+			}
+			ee := (*unix.SockExtendedErr)(unsafe.Pointer(&cmsg.Data[0]))
+			if ee.Origin == unix.SO_EE_ORIGIN_ZEROCOPY && ee.Errno == 0 {
+				c.completeZeroCopy(ee.Info, ee.Data)
+			}
+		}
+	}
+	errno, err := unix.GetsockoptInt(c.fd, unix.SOL_SOCKET, unix.SO_ERROR)
+	return err == nil && errno == 0
+}
+
+// completeZeroCopy completes the writes with MSG_ZEROCOPY whose sequence numbers are in [lo, hi].
+func (c *conn) completeZeroCopy(lo, hi uint32) {
+	var completed []*zeroCopyWrite
+	pending := c.zeroCopyWrites[:0]
+	for _, zw := range c.zeroCopyWrites {
+		if zw.seq-lo <= hi-lo {
+			completed = append(completed, zw)
+		} else {
+			pending = append(pending, zw)
+		}
+	}
+	for i := len(pending); i < len(c.zeroCopyWrites); i++ {
+		c.zeroCopyWrites[i] = nil
+	}
+	c.zeroCopyWrites = pending
+	for _, zw := range completed {
+		if zw.callback != nil {
+			_ = zw.callback(c, nil)
+		}
+	}
+}
+
+const (
+	// maxGSOSegments is the maximum number of segments sent at a time with UDP_SEGMENT, which is UDP_MAX_SEGMENTS.
+	maxGSOSegments = 64
+	// maxGSOSize is the maximum number of bytes sent at a time with UDP_SEGMENT, which is the largest UDP payload.
+	maxGSOSize = 65507
+)
+
+// sendGSO sends buf to sa in datagrams of segmentSize bytes with UDP_SEGMENT.
+func (c *conn) sendGSO(buf []byte, segmentSize int, sa unix.Sockaddr) (n int, err error) {
+	oob := make([]byte, unix.CmsgSpace(2))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = unix.SOL_UDP
+	h.Type = unix.UDP_SEGMENT
+	h.SetLen(unix.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&oob[unix.CmsgLen(0)])) = uint16(segmentSize)
+
+	segments := maxGSOSize / segmentSize
+	if segments > maxGSOSegments {
+		segments = maxGSOSegments
+	} else if segments < 1 {
+		segments = 1
+	}
+	for n < len(buf) {
+		end := n + segments*segmentSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		if err = unix.Sendmsg(c.fd, buf[n:end], oob, sa, 0); err != nil {
+			return
+		}
+		c.stats.written(end-n, c.loop.poller.Now())
+		c.loop.metrics.addWritten(end - n)
+		n = end
+	}
+	return
+}
+
+// msgCmsgCloexec has the received file descriptors set close-on-exec atomically.
+const msgCmsgCloexec = unix.MSG_CMSG_CLOEXEC
+
+// parsePacketInfo parses the ancillary data of a received datagram.
+func parsePacketInfo(oob []byte) (info PacketInfo) {
+	cmsgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return
+	}
+	for _, cmsg := range cmsgs {
+		level, typ, data := cmsg.Header.Level, cmsg.Header.Type, cmsg.Data
+		switch {
+		case level == unix.IPPROTO_IP && typ == unix.IP_PKTINFO && len(data) >= unix.SizeofInet4Pktinfo:
+			pi := (*unix.Inet4Pktinfo)(unsafe.Pointer(&data[0]))
+			info.LocalIP = append(net.IP(nil), pi.Addr[:]...)
+			info.IfIndex = int(pi.Ifindex)
+		case level == unix.IPPROTO_IP && typ == unix.IP_TOS && len(data) >= 1:
+			info.TOS = int(data[0])
+		case level == unix.IPPROTO_IPV6 && typ == unix.IPV6_PKTINFO && len(data) >= unix.SizeofInet6Pktinfo:
+			pi := (*unix.Inet6Pktinfo)(unsafe.Pointer(&data[0]))
+			info.LocalIP = append(net.IP(nil), pi.Addr[:]...)
+			info.IfIndex = int(pi.Ifindex)
+		case level == unix.IPPROTO_IPV6 && typ == unix.IPV6_TCLASS && len(data) >= 4:
+			info.TOS = int(*(*int32)(unsafe.Pointer(&data[0])))
+		case level == unix.SOL_SOCKET && typ == unix.SO_TIMESTAMPING && len(data) >= int(unsafe.Sizeof(unix.Timespec{})):
+			// The first one of struct scm_timestamping is the software timestamp.
+			ts := (*unix.Timespec)(unsafe.Pointer(&data[0]))
+			info.Timestamp = time.Unix(ts.Unix())
+		}
+	}
+	return
+}
+
+// putCmsg puts a control message of size bytes at the beginning of oob,
+// it returns the data part of the control message.
+func putCmsg(oob []byte, level, typ int32, size int) []byte {
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = level
+	h.Type = typ
+	h.SetLen(unix.CmsgLen(size))
+	return oob[unix.CmsgLen(0):unix.CmsgLen(size)]
+}
+
+// sendMsg sends buf to sa with the source address, the interface and the TOS in info.
+func (c *conn) sendMsg(buf []byte, sa unix.Sockaddr, info *PacketInfo) (int, error) {
+	// Decide the protocol of the control messages by the destination, the kernel
+	// takes the IPv4 path for IPv4-mapped IPv6 addresses on a dual-stack socket.
+	var ipv4 bool
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		ipv4 = true
+	case *unix.SockaddrInet6:
+		ipv4 = net.IP(sa.Addr[:]).To4() != nil
+	case nil:
+		if addr, ok := c.remoteAddr.(*net.UDPAddr); ok {
+			ipv4 = addr.IP.To4() != nil
+		}
+	}
+
+	oob := make([]byte, unix.CmsgSpace(unix.SizeofInet6Pktinfo)+unix.CmsgSpace(4))
+	off := 0
+	if info.LocalIP != nil || info.IfIndex != 0 {
+		if ipv4 {
+			pi := (*unix.Inet4Pktinfo)(unsafe.Pointer(&putCmsg(oob[off:], unix.IPPROTO_IP, unix.IP_PKTINFO, unix.SizeofInet4Pktinfo)[0]))
+			pi.Ifindex = int32(info.IfIndex)
+			if ip := info.LocalIP.To4(); ip != nil {
+				copy(pi.Spec_dst[:], ip)
+			}
+			off += unix.CmsgSpace(unix.SizeofInet4Pktinfo)
+		} else {
+			pi := (*unix.Inet6Pktinfo)(unsafe.Pointer(&putCmsg(oob[off:], unix.IPPROTO_IPV6, unix.IPV6_PKTINFO, unix.SizeofInet6Pktinfo)[0]))
+			pi.Ifindex = uint32(info.IfIndex)
+			if ip := info.LocalIP.To16(); ip != nil {
+				copy(pi.Addr[:], ip)
+			}
+			off += unix.CmsgSpace(unix.SizeofInet6Pktinfo)
+		}
+	}
+	if info.TOS != 0 {
+		level, typ := int32(unix.IPPROTO_IP), int32(unix.IP_TOS)
+		if !ipv4 {
+			level, typ = unix.IPPROTO_IPV6, unix.IPV6_TCLASS
+		}
+		*(*int32)(unsafe.Pointer(&putCmsg(oob[off:], level, typ, 4)[0])) = int32(info.TOS)
+		off += unix.CmsgSpace(4)
+	}
+
+	if err := unix.Sendmsg(c.fd, buf, oob[:off], sa, 0); err != nil {
+		return 0, err
+	}
+	c.loop.metrics.addWritten(len(buf))
+	return len(buf), nil
+}
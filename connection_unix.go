@@ -17,9 +17,12 @@
 package gnet
 
 import (
+	"bytes"
+	"crypto/tls"
 	"io"
 	"net"
 	"os"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -37,52 +40,84 @@ import (
 )
 
 type conn struct {
-	fd             int                    // file descriptor
-	gfd            gfd.GFD                // gnet file descriptor
-	ctx            any                    // user-defined context
-	safeCtx        atomic.Pointer[any]    // safe user-defined context
-	remote         unix.Sockaddr          // remote socket address
-	proto          string                 // protocol name: "tcp", "udp", or "unix".
-	localAddr      net.Addr               // local addr
-	remoteAddr     net.Addr               // remote addr
-	loop           *eventloop             // connected event-loop
-	outboundBuffer elastic.Buffer         // buffer for data that is eligible to be sent to the remote
-	pollAttachment netpoll.PollAttachment // connection attachment for poller
-	inboundBuffer  elastic.RingBuffer     // buffer for leftover data from the remote
-	buffer         []byte                 // buffer for the latest bytes
-	cache          []byte                 // temporary cache for the inbound data
-	isDatagram     bool                   // UDP protocol
-	opened         bool                   // connection opened event fired
-	isEOF          bool                   // whether the connection has reached EOF
-}
-
-func newStreamConn(proto string, fd int, el *eventloop, sa unix.Sockaddr, localAddr, remoteAddr net.Addr) (c *conn) {
+	fd              int                    // file descriptor
+	gfd             gfd.GFD                // gnet file descriptor
+	ctx             any                    // user-defined context
+	safeCtx         atomic.Pointer[any]    // safe user-defined context
+	remote          unix.Sockaddr          // remote socket address
+	proto           string                 // protocol name: "tcp", "udp", "unix", "unixgram", or "unixpacket".
+	localAddr       net.Addr               // local addr
+	remoteAddr      net.Addr               // remote addr
+	loop            *eventloop             // connected event-loop
+	ln              *listener              // listener that accepted the connection, nil if there is none
+	outboundBuffer  elastic.Buffer         // buffer for data that is eligible to be sent to the remote
+	pollAttachment  netpoll.PollAttachment // connection attachment for poller
+	inboundBuffer   elastic.RingBuffer     // buffer for leftover data from the remote
+	buffer          []byte                 // buffer for the latest bytes
+	cache           []byte                 // temporary cache for the inbound data
+	readTimer       *netpoll.Timer         // timer for the read deadline
+	writeTimer      *netpoll.Timer         // timer for the write deadline
+	readDeadline    time.Time              // read deadline set before the connection is opened
+	writeDeadline   time.Time              // write deadline set before the connection is opened
+	idleTimer       *netpoll.Timer         // timer for the idle timeout
+	stats           connStats              // statistics of the connection
+	tls             *tlsConn               // TLS record layer, nil if TLS is not enabled
+	isDatagram      bool                   // UDP or unixgram protocol
+	isPacket        bool                   // unixpacket protocol, which preserves message boundaries
+	opened          bool                   // connection opened event fired
+	isEOF           bool                   // whether the connection has reached EOF
+	backpressured   bool                   // whether the outbound buffer has reached the high watermark
+	readPause       uint8                  // reasons why reading is paused, zero if it's not paused
+	writeClosed     bool                   // whether CloseWrite has been called
+	files           []*fileTransfer        // files and file descriptors pending to be sent to the remote
+	outboundDrained uint64                 // number of bytes that have been drained from the outbound buffer
+	zeroCopy        bool                   // whether SO_ZEROCOPY is enabled on the socket
+	zeroCopySeq     uint32                 // sequence number of the next write with MSG_ZEROCOPY
+	zeroCopyWrites  []*zeroCopyWrite       // writes with MSG_ZEROCOPY that haven't been completed by the kernel
+	session         bool                   // whether it's a UDP session of a listener
+	packetInfo      PacketInfo             // ancillary data of the datagram being handled
+	fds             []int                  // file descriptors received by SCM_RIGHTS that haven't been taken
+	proxy           *proxyState            // state of the pending PROXY protocol header, nil if it's not pending
+	proxyHeader     *ProxyHeader           // PROXY protocol header received at the beginning
+	admitted        bool                   // whether the connection is counted for the connection limits
+}
+
+func newStreamConn(proto string, fd int, el *eventloop, ln *listener, sa unix.Sockaddr, localAddr, remoteAddr net.Addr) (c *conn) {
 	c = &conn{
 		fd:             fd,
 		proto:          proto,
 		remote:         sa,
 		loop:           el,
+		ln:             ln,
 		localAddr:      localAddr,
 		remoteAddr:     remoteAddr,
+		isPacket:       proto == "unixpacket",
 		pollAttachment: netpoll.PollAttachment{FD: fd},
 	}
 	c.pollAttachment.Callback = c.processIO
-	c.outboundBuffer.Reset(el.engine.opts.WriteBufferCap)
+	c.outboundBuffer.Reset(c.options().WriteBufferCap)
 	return
 }
 
-func newUDPConn(fd int, el *eventloop, localAddr net.Addr, sa unix.Sockaddr, connected bool) (c *conn) {
+func newUDPConn(fd int, el *eventloop, ln *listener, localAddr net.Addr, sa unix.Sockaddr, connected bool) (c *conn) {
 	c = &conn{
 		fd:             fd,
 		proto:          "udp",
 		gfd:            gfd.NewGFD(fd, el.idx, 0, 0),
 		remote:         sa,
 		loop:           el,
+		ln:             ln,
 		localAddr:      localAddr,
 		remoteAddr:     socket.SockaddrToUDPAddr(sa),
 		isDatagram:     true,
 		pollAttachment: netpoll.PollAttachment{FD: fd, Callback: el.readUDP},
 	}
+	// The datagrams of unixgram are read in the same way as UDP except for batching.
+	if sa, ok := sa.(*unix.SockaddrUnix); ok {
+		c.proto = "unixgram"
+		c.remoteAddr = socket.SockaddrToUnixAddr(sa, "unixgram")
+		c.pollAttachment.Callback = el.readDatagram
+	}
 	if connected {
 		c.remote = nil
 	}
@@ -90,8 +125,21 @@ func newUDPConn(fd int, el *eventloop, localAddr net.Addr, sa unix.Sockaddr, con
 }
 
 func (c *conn) release() {
+	if c.admitted {
+		c.admitted = false
+		c.loop.engine.release(c.remote)
+	}
 	c.opened = false
 	c.isEOF = false
+	c.backpressured = false
+	c.readPause = 0
+	c.writeClosed = false
+	c.outboundDrained = 0
+	c.zeroCopy = false
+	c.zeroCopySeq = 0
+	c.packetInfo = PacketInfo{}
+	closeFDs(c.fds)
+	c.fds = nil
 	c.ctx = nil
 	c.safeCtx.Store(nil)
 	c.buffer = nil
@@ -109,6 +157,35 @@ func (c *conn) release() {
 	}
 	c.localAddr = nil
 	c.remoteAddr = nil
+	if c.readTimer != nil {
+		c.loop.poller.DelTimer(c.readTimer)
+		c.readTimer = nil
+	}
+	if c.writeTimer != nil {
+		c.loop.poller.DelTimer(c.writeTimer)
+		c.writeTimer = nil
+	}
+	c.readDeadline = time.Time{}
+	c.writeDeadline = time.Time{}
+	if c.idleTimer != nil {
+		c.loop.poller.DelTimer(c.idleTimer)
+		c.idleTimer = nil
+	}
+	if c.proxy != nil {
+		if c.proxy.timer != nil {
+			c.loop.poller.DelTimer(c.proxy.timer)
+		}
+		c.proxy = nil
+	}
+	c.proxyHeader = nil
+	if c.tls != nil {
+		if c.tls.timer != nil {
+			c.loop.poller.DelTimer(c.tls.timer)
+			c.tls.timer = nil
+		}
+		c.tls.abort(net.ErrClosed)
+		c.tls = nil
+	}
 	if !c.isDatagram {
 		c.remote = nil
 		c.inboundBuffer.Done()
@@ -117,19 +194,32 @@ func (c *conn) release() {
 }
 
 func (c *conn) open(buf []byte) error {
-	if c.isDatagram && c.remote == nil {
-		return unix.Send(c.fd, buf, 0)
+	if c.isDatagram {
+		_, err := c.sendTo(buf, nil)
+		return err
+	}
+
+	if c.tls != nil {
+		_, err := c.tls.conn.Write(buf)
+		return err
+	}
+	if c.isPacket {
+		_, err := c.write(buf)
+		return err
 	}
 
 	for {
-		n, err := unix.Write(c.fd, buf)
+		n, err := c.loop.poller.Write(c.fd, buf)
 		if err != nil {
 			if err == unix.EAGAIN {
 				_, _ = c.outboundBuffer.Write(buf)
+				c.loop.metrics.addWriteEAGAIN()
+				c.outboundChanged()
 				break
 			}
 			return err
 		}
+		c.markWritten(n)
 		buf = buf[n:]
 		if len(buf) == 0 {
 			break
@@ -140,14 +230,24 @@ func (c *conn) open(buf []byte) error {
 }
 
 func (c *conn) write(data []byte) (n int, err error) {
+	if c.writeClosed {
+		return 0, errorx.ErrWriteClosed
+	}
+	if c.isPacket {
+		if len(data) == 0 {
+			return 0, nil
+		}
+		return c.writeMessage(nil, data)
+	}
 	isET := c.loop.engine.opts.EdgeTriggeredIO
 	n = len(data)
 	// If there is pending data in outbound buffer,
 	// the current data ought to be appended to the
 	// outbound buffer for maintaining the sequence
 	// of network packets.
-	if !c.outboundBuffer.IsEmpty() {
+	if c.hasPendingOutbound() {
 		_, _ = c.outboundBuffer.Write(data)
+		c.outboundChanged()
 		return
 	}
 
@@ -159,18 +259,21 @@ func (c *conn) write(data []byte) (n int, err error) {
 
 	var sent int
 loop:
-	if sent, err = unix.Write(c.fd, data); err != nil {
+	if sent, err = c.loop.poller.Write(c.fd, data); err != nil {
 		// A temporary error occurs, append the data to outbound buffer,
 		// writing it back to the remote in the next round for LT mode.
 		if err == unix.EAGAIN {
 			_, err = c.outboundBuffer.Write(data)
+			c.loop.metrics.addWriteEAGAIN()
+			c.outboundChanged()
 			if !isET {
-				err = c.loop.poller.ModReadWrite(&c.pollAttachment, isET)
+				err = c.pollEvents()
 			}
 			return
 		}
 		return 0, err
 	}
+	c.markWritten(sent)
 	data = data[sent:]
 	if isET && len(data) > 0 {
 		goto loop
@@ -178,13 +281,21 @@ loop:
 	// Failed to send all data back to the remote, buffer the leftover data for the next round.
 	if len(data) > 0 {
 		_, _ = c.outboundBuffer.Write(data)
-		err = c.loop.poller.ModReadWrite(&c.pollAttachment, isET)
+		c.outboundChanged()
+		err = c.pollEvents()
 	}
 
 	return
 }
 
 func (c *conn) writev(bs [][]byte) (n int, err error) {
+	if c.writeClosed {
+		return 0, errorx.ErrWriteClosed
+	}
+	if c.isPacket {
+		// Send bs as a single message rather than a message per buffer.
+		return c.write(bytes.Join(bs, nil))
+	}
 	isET := c.loop.engine.opts.EdgeTriggeredIO
 
 	for _, b := range bs {
@@ -195,8 +306,9 @@ func (c *conn) writev(bs [][]byte) (n int, err error) {
 	// the current data ought to be appended to the
 	// outbound buffer for maintaining the sequence
 	// of network packets.
-	if !c.outboundBuffer.IsEmpty() {
+	if c.hasPendingOutbound() {
 		_, _ = c.outboundBuffer.Writev(bs)
+		c.outboundChanged()
 		return
 	}
 
@@ -209,18 +321,21 @@ func (c *conn) writev(bs [][]byte) (n int, err error) {
 	remaining := n
 	var sent int
 loop:
-	if sent, err = gio.Writev(c.fd, bs); err != nil {
+	if sent, err = c.loop.poller.Writev(c.fd, bs); err != nil {
 		// A temporary error occurs, append the data to outbound buffer,
 		// writing it back to the remote in the next round for LT mode.
 		if err == unix.EAGAIN {
 			_, err = c.outboundBuffer.Writev(bs)
+			c.loop.metrics.addWriteEAGAIN()
+			c.outboundChanged()
 			if !isET {
-				err = c.loop.poller.ModReadWrite(&c.pollAttachment, isET)
+				err = c.pollEvents()
 			}
 			return
 		}
 		return 0, err
 	}
+	c.markWritten(sent)
 	pos := len(bs)
 	if remaining -= sent; remaining > 0 {
 		for i := range bs {
@@ -241,12 +356,141 @@ loop:
 	// Failed to send all data back to the remote, buffer the leftover data for the next round.
 	if remaining > 0 {
 		_, _ = c.outboundBuffer.Writev(bs)
-		err = c.loop.poller.ModReadWrite(&c.pollAttachment, isET)
+		c.outboundChanged()
+		err = c.pollEvents()
 	}
 
 	return
 }
 
+// Reasons why reading from a connection is paused.
+const (
+	readPausedByUser uint8 = 1 << iota
+	readPausedByBackpressure
+	readPausedByEOF // the remote has shut down its writing side in the half-close mode
+)
+
+// hasPendingOutbound reports whether there is any data or file pending to be sent.
+func (c *conn) hasPendingOutbound() bool {
+	return !c.outboundBuffer.IsEmpty() || len(c.files) > 0
+}
+
+// pollEvents registers the events of c to the poller in accordance with whether
+// reading from c is paused and whether there is pending data to write to c.
+func (c *conn) pollEvents() error {
+	isET := c.loop.engine.opts.EdgeTriggeredIO
+	pa := &c.pollAttachment
+	writable := isET || c.hasPendingOutbound()
+	switch {
+	case c.readPause == 0 && writable:
+		return c.loop.poller.ModReadWrite(pa, isET)
+	case c.readPause == 0:
+		return c.loop.poller.ModRead(pa, false)
+	case writable:
+		return c.loop.poller.ModWrite(pa, isET)
+	default:
+		return c.loop.poller.ModNone(pa)
+	}
+}
+
+// pauseReading stops reading from c for the given reason.
+func (c *conn) pauseReading(reason uint8) error {
+	paused := c.readPause != 0
+	c.readPause |= reason
+	if paused {
+		return nil
+	}
+	return c.pollEvents()
+}
+
+// resumeReading resumes reading from c if there is no other reason for pausing it.
+func (c *conn) resumeReading(reason uint8) error {
+	if c.readPause&reason == 0 {
+		return nil
+	}
+	c.readPause &^= reason
+	if c.readPause != 0 {
+		return nil
+	}
+	if err := c.pollEvents(); err != nil {
+		return err
+	}
+	// The data that has been read but not handled yet won't come along
+	// with any readable events, so hand it over to the event handler.
+	if c.inboundBuffer.IsEmpty() && c.tls == nil {
+		return nil
+	}
+	return c.loop.poller.Trigger(queue.HighPriority, c.loop.readPending, c)
+}
+
+func (c *conn) toggleRead(a any) (err error) {
+	if !c.opened {
+		return nil
+	}
+	if a.(bool) {
+		err = c.pauseReading(readPausedByUser)
+	} else {
+		err = c.resumeReading(readPausedByUser)
+	}
+	if err != nil {
+		return c.loop.close(c, err)
+	}
+	return nil
+}
+
+// outboundChanged is invoked whenever the outbound buffer grows or shrinks,
+// it puts c under backpressure or relieves it when the watermarks are crossed.
+func (c *conn) outboundChanged() {
+	buffered := c.outboundBuffer.Buffered()
+	c.loop.metrics.observeOutbound(buffered)
+
+	opts := c.options()
+	high := opts.OutboundHighWatermark
+	if high <= 0 {
+		return
+	}
+	low := opts.OutboundLowWatermark
+	if low >= high {
+		low = high / 2
+	}
+	switch {
+	case !c.backpressured && buffered >= high:
+		c.setBackpressure(true)
+	case c.backpressured && buffered <= low:
+		c.setBackpressure(false)
+	}
+}
+
+func (c *conn) setBackpressure(paused bool) {
+	el := c.loop
+	c.backpressured = paused
+	var err error
+	if !paused {
+		err = c.resumeReading(readPausedByBackpressure)
+	} else if c.options().PauseReadOnBackpressure {
+		err = c.pauseReading(readPausedByBackpressure)
+	}
+	if err != nil {
+		el.getLogger().Errorf("failed to toggle reading from fd=%d in event-loop(%d): %v", c.fd, el.idx, err)
+	}
+
+	h, ok := c.handler().(BackpressureHandler)
+	if !ok {
+		return
+	}
+	// Fire the event asynchronously as the watermarks are mostly crossed
+	// in the middle of writes, which are likely made within other events.
+	err = el.poller.Trigger(queue.HighPriority, func(_ any) error {
+		if !c.opened || el.connections.getConn(c.fd) != c {
+			return nil // ignore stale connections
+		}
+		return el.handleAction(c, h.OnBackpressure(c, paused))
+	}, nil)
+	if err != nil {
+		el.getLogger().Errorf("failed to enqueue the backpressure event of fd=%d in event-loop(%d): %v", c.fd, el.idx, err)
+	}
+}
+
 type asyncWriteHook struct {
 	callback AsyncCallback
 	data     []byte
@@ -264,10 +508,31 @@ func (c *conn) asyncWrite(a any) (err error) {
 		return net.ErrClosed
 	}
 
+	if c.tls != nil {
+		_, err = c.tls.conn.Write(hook.data)
+		return
+	}
+	if c.zeroCopy && len(hook.data) >= c.options().ZeroCopyWriteThreshold && !c.hasPendingOutbound() {
+		var sent bool
+		if sent, err = c.writeZeroCopy(hook); sent {
+			hook.callback = nil // it's invoked once the kernel completes the write
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
 	_, err = c.write(hook.data)
 	return
 }
 
+// zeroCopyWrite is a write with MSG_ZEROCOPY, data is held until the kernel completes the write.
+type zeroCopyWrite struct {
+	seq      uint32
+	data     []byte
+	callback AsyncCallback
+}
+
 type asyncWritevHook struct {
 	callback AsyncCallback
 	data     [][]byte
@@ -285,6 +550,10 @@ func (c *conn) asyncWritev(a any) (err error) {
 		return net.ErrClosed
 	}
 
+	if c.tls != nil {
+		_, err = c.writevTLS(hook.data)
+		return
+	}
 	_, err = c.writev(hook.data)
 	return
 }
@@ -293,7 +562,11 @@ func (c *conn) sendTo(buf []byte, addr unix.Sockaddr) (n int, err error) {
 	defer func() {
 		if err != nil {
 			n = 0
+			return
 		}
+		// Don't touch the stats of c here as sendTo may be invoked by
+		// AsyncWrite outside the event-loop for UDP connections.
+		c.loop.metrics.addWritten(n)
 	}()
 
 	if addr != nil {
@@ -305,6 +578,18 @@ func (c *conn) sendTo(buf []byte, addr unix.Sockaddr) (n int, err error) {
 	return len(buf), unix.Sendto(c.fd, buf, 0, c.remote) // unconnected UDP socket of server
 }
 
+// markRead records n bytes read from the socket.
+func (c *conn) markRead(n int) {
+	c.stats.read(n, c.loop.poller.Now())
+	c.loop.metrics.addRead(n)
+}
+
+// markWritten records n bytes written to the socket.
+func (c *conn) markWritten(n int) {
+	c.stats.written(n, c.loop.poller.Now())
+	c.loop.metrics.addWritten(n)
+}
+
 func (c *conn) resetBuffer() {
 	c.buffer = c.buffer[:0]
 	c.inboundBuffer.Reset()
@@ -407,7 +692,12 @@ func (c *conn) Discard(n int) (int, error) {
 
 func (c *conn) Write(p []byte) (int, error) {
 	if c.isDatagram {
-		return c.sendTo(p, nil)
+		n, err := c.sendTo(p, nil)
+		c.stats.written(n, c.loop.poller.Now())
+		return n, err
+	}
+	if c.tls != nil {
+		return c.tls.conn.Write(p)
 	}
 	return c.write(p)
 }
@@ -422,17 +712,170 @@ func (c *conn) SendTo(p []byte, addr net.Addr) (int, error) {
 		return 0, errorx.ErrInvalidNetworkAddress
 	}
 
-	return c.sendTo(p, sa)
+	n, err := c.sendTo(p, sa)
+	c.stats.written(n, c.loop.poller.Now())
+	return n, err
+}
+
+// maxBatchSize is the maximum number of messages sent by a single sendmmsg(2), which is UIO_MAXIOV.
+const maxBatchSize = 1024
+
+func (c *conn) SendToBatch(bufs [][]byte, addrs []net.Addr) (int, error) {
+	if !c.isDatagram {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	if len(addrs) != len(bufs) {
+		return 0, errorx.ErrInvalidNetworkAddress
+	}
+	sas := make([]unix.Sockaddr, len(addrs))
+	for i, addr := range addrs {
+		if sas[i] = socket.NetAddrToSockaddr(addr); sas[i] == nil {
+			return 0, errorx.ErrInvalidNetworkAddress
+		}
+	}
+	return c.sendBatch(bufs, sas)
+}
+
+// sendBatch sends bufs[i] to sas[i] with sendmmsg(2) and returns the number of messages sent.
+func (c *conn) sendBatch(bufs [][]byte, sas []unix.Sockaddr) (int, error) {
+	size := len(bufs)
+	if size > maxBatchSize {
+		size = maxBatchSize
+	}
+	batch := gio.NewMsgBatch(size)
+	var n int
+	for n < len(bufs) {
+		m, err := batch.Sendmmsg(c.fd, bufs[n:], sas[n:])
+		for _, buf := range bufs[n : n+m] {
+			c.stats.written(len(buf), c.loop.poller.Now())
+			c.loop.metrics.addWritten(len(buf))
+		}
+		n += m
+		if err != nil || m == 0 {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (c *conn) SendSegments(buf []byte, segmentSize int, addr net.Addr) (int, error) {
+	if !c.isDatagram {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	if segmentSize < 0 {
+		return 0, errorx.ErrNegativeSize
+	}
+	sa := socket.NetAddrToSockaddr(addr)
+	if sa == nil {
+		return 0, errorx.ErrInvalidNetworkAddress
+	}
+
+	if segmentSize == 0 || segmentSize >= len(buf) {
+		n, err := c.sendTo(buf, sa)
+		c.stats.written(n, c.loop.poller.Now())
+		return n, err
+	}
+	if c.options().UDPGSO {
+		n, err := c.sendGSO(buf, segmentSize, sa)
+		if err != unix.EIO {
+			return n, err
+		}
+		// UDP_SEGMENT fails with EIO when the device can't checksum the segments,
+		// e.g., the checksum offloading is off, send the rest in separate datagrams.
+		m, err := c.sendSplit(buf[n:], segmentSize, sa)
+		return n + m, err
+	}
+	return c.sendSplit(buf, segmentSize, sa)
+}
+
+// sendSplit splits buf into datagrams of segmentSize bytes and sends them to sa with sendmmsg(2).
+func (c *conn) sendSplit(buf []byte, segmentSize int, sa unix.Sockaddr) (int, error) {
+	count := (len(buf) + segmentSize - 1) / segmentSize
+	segments := make([][]byte, 0, count)
+	sas := make([]unix.Sockaddr, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * segmentSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		segments = append(segments, buf[i*segmentSize:end])
+		sas[i] = sa
+	}
+	m, err := c.sendBatch(segments, sas)
+	n := m * segmentSize
+	if n > len(buf) {
+		n = len(buf)
+	}
+	return n, err
+}
+
+func (c *conn) SendMsg(buf []byte, addr net.Addr, info *PacketInfo) (int, error) {
+	if !c.isDatagram {
+		return 0, errorx.ErrUnsupportedOp
+	}
+
+	sa := c.remote
+	if addr != nil {
+		if sa = socket.NetAddrToSockaddr(addr); sa == nil {
+			return 0, errorx.ErrInvalidNetworkAddress
+		}
+	}
+
+	var (
+		n   int
+		err error
+	)
+	if info != nil && c.proto != "udp" {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	if info == nil {
+		n, err = c.sendTo(buf, sa)
+	} else {
+		n, err = c.sendMsg(buf, sa, info)
+	}
+	c.stats.written(n, c.loop.poller.Now())
+	return n, err
 }
 
 func (c *conn) Writev(bs [][]byte) (int, error) {
 	if c.isDatagram {
 		return 0, errorx.ErrUnsupportedOp
 	}
+	if c.tls != nil {
+		return c.writevTLS(bs)
+	}
 	return c.writev(bs)
 }
 
+// writevTLS encrypts bs into TLS records one by one.
+func (c *conn) writevTLS(bs [][]byte) (n int, err error) {
+	var m int
+	for _, b := range bs {
+		m, err = c.tls.conn.Write(b)
+		n += m
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
 func (c *conn) ReadFrom(r io.Reader) (int64, error) {
+	if c.writeClosed {
+		return 0, errorx.ErrWriteClosed
+	}
+	if c.tls != nil {
+		return io.Copy(c.tls.conn, r)
+	}
+	if c.isPacket {
+		// Everything read from r makes up a single message.
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		n, err := c.write(data)
+		return int64(n), err
+	}
 	return c.outboundBuffer.ReadFrom(r)
 }
 
@@ -547,20 +990,442 @@ func (c *conn) Close() error {
 	}, nil)
 }
 
+func (c *conn) CloseWrite() error {
+	if c.isDatagram {
+		return errorx.ErrUnsupportedOp
+	}
+	return c.loop.poller.Trigger(queue.LowPriority, c.closeWrite, nil)
+}
+
+func (c *conn) closeWrite(_ any) error {
+	if !c.opened || c.writeClosed {
+		return nil
+	}
+	if c.tls != nil {
+		_ = c.tls.conn.CloseWrite() // send close_notify
+	}
+	c.writeClosed = true
+	if c.hasPendingOutbound() {
+		return nil // shut down after the pending data is sent
+	}
+	return c.loop.shutdownWrite(c)
+}
+
+// maxSendFileSize is the maximum number of bytes sent from a file at a time.
+const maxSendFileSize = 4 << 20
+
+// fileTransfer is a file pending to be sent, or data pending to be sent along with
+// the file descriptors passed by SCM_RIGHTS if f is nil, which is also how the messages
+// of unixpacket are queued.
+type fileTransfer struct {
+	f         *os.File
+	offset    int64
+	remaining int64
+	at        uint64 // the file is sent after the outbound buffer has drained this many bytes
+	callback  AsyncCallback
+	fds       []int  // duplicated file descriptors that haven't been passed yet
+	data      []byte // data that carries fds
+}
+
+func (c *conn) SendFile(f *os.File, offset, count int64, callback AsyncCallback) error {
+	if c.isDatagram || c.isPacket {
+		return errorx.ErrUnsupportedOp
+	}
+	if offset < 0 || count < 0 {
+		return errorx.ErrNegativeSize
+	}
+	ft := &fileTransfer{f: f, offset: offset, remaining: count, callback: callback}
+	return c.loop.poller.Trigger(queue.HighPriority, c.enqueueFile, ft)
+}
+
+func (c *conn) enqueueFile(a any) error {
+	ft := a.(*fileTransfer)
+	var err error
+	switch {
+	case !c.opened:
+		err = net.ErrClosed
+	case c.tls != nil:
+		err = errorx.ErrUnsupportedOp
+	case c.writeClosed:
+		err = errorx.ErrWriteClosed
+	case ft.remaining > 0:
+		ft.at = c.outboundDrained + uint64(c.outboundBuffer.Buffered())
+		c.files = append(c.files, ft)
+		if len(c.files) > 1 || !c.outboundBuffer.IsEmpty() {
+			return nil // the file will be sent after the pending data
+		}
+		if err = c.loop.write(c); err != nil || !c.opened {
+			return err
+		}
+		if c.hasPendingOutbound() && !c.loop.engine.opts.EdgeTriggeredIO {
+			return c.pollEvents()
+		}
+		return nil
+	}
+	if ft.callback != nil {
+		_ = ft.callback(c, err)
+	}
+	return nil
+}
+
+// maxRecvFDs is the maximum number of file descriptors received by a single read,
+// which is SCM_MAX_FD of Linux.
+const maxRecvFDs = 253
+
+// recv reads from the socket of c into buf, the file descriptors passed by SCM_RIGHTS
+// are collected when UnixRights is enabled. A unixpacket message that doesn't fit in
+// buf is reported as errorx.ErrMessageTruncated.
+func (c *conn) recv(buf []byte) (int, error) {
+	rights := c.isUnixConn() && c.options().UnixRights
+	if !rights && !c.isPacket {
+		return c.loop.poller.Read(c.fd, buf)
+	}
+
+	el := c.loop
+	var oob []byte
+	if rights {
+		if el.rightsBuffer == nil {
+			el.rightsBuffer = make([]byte, unix.CmsgSpace(maxRecvFDs*4))
+		}
+		oob = el.rightsBuffer
+	}
+	n, oobn, flags, _, err := unix.Recvmsg(c.fd, buf, oob, msgCmsgCloexec)
+	if err != nil {
+		return n, err
+	}
+	if oobn > 0 {
+		c.collectRights(oob[:oobn])
+	}
+	if c.isPacket && flags&unix.MSG_TRUNC != 0 {
+		return n, errorx.ErrMessageTruncated
+	}
+	return n, nil
+}
+
+// collectRights appends the file descriptors carried by the control messages in oob to c.fds.
+func (c *conn) collectRights(oob []byte) {
+	cmsgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return
+	}
+	for i := range cmsgs {
+		fds, err := unix.ParseUnixRights(&cmsgs[i])
+		if err != nil {
+			continue
+		}
+		if msgCmsgCloexec == 0 {
+			for _, fd := range fds {
+				unix.CloseOnExec(fd)
+			}
+		}
+		c.fds = append(c.fds, fds...)
+	}
+}
+
+func (c *conn) ReceivedFDs() []int {
+	fds := c.fds
+	c.fds = nil
+	return fds
+}
+
+func (c *conn) SendFDs(fds []int, data []byte) (n int, err error) {
+	if !c.isUnixConn() || c.tls != nil {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	if len(data) == 0 {
+		return 0, errorx.ErrEmptyData
+	}
+	if c.writeClosed {
+		return 0, errorx.ErrWriteClosed
+	}
+	return c.writeMessage(fds, data)
+}
+
+// writeMessage sends data along with the file descriptors in a single sendmsg(2),
+// which makes up a whole message for unixpacket.
+func (c *conn) writeMessage(fds []int, data []byte) (n int, err error) {
+	isET := c.loop.engine.opts.EdgeTriggeredIO
+	n = len(data)
+	// Queue the file descriptors behind the pending data for maintaining the sequence.
+	if c.hasPendingOutbound() {
+		return n, c.enqueueFDs(fds, data)
+	}
+
+	sent, err := unix.SendmsgN(c.fd, data, unix.UnixRights(fds...), nil, 0)
+	if err != nil {
+		if err == unix.EAGAIN {
+			c.loop.metrics.addWriteEAGAIN()
+			if err = c.enqueueFDs(fds, data); err == nil && !isET {
+				err = c.pollEvents()
+			}
+			return
+		}
+		_ = c.loop.close(c, os.NewSyscallError("sendmsg", err))
+		return 0, err
+	}
+	c.markWritten(sent)
+	// The file descriptors have been passed, buffer the leftover data for the next round.
+	if sent < len(data) {
+		_, _ = c.outboundBuffer.Write(data[sent:])
+		c.outboundChanged()
+		err = c.pollEvents()
+	}
+	return
+}
+
+// enqueueFDs queues the file descriptors along with data to be sent after the pending data,
+// the file descriptors are duplicated so that the caller can close them right away.
+func (c *conn) enqueueFDs(fds []int, data []byte) error {
+	ft := &fileTransfer{
+		at:   c.outboundDrained + uint64(c.outboundBuffer.Buffered()),
+		data: append([]byte(nil), data...),
+	}
+	for _, fd := range fds {
+		nfd, err := unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+		if err != nil {
+			closeFDs(ft.fds)
+			return os.NewSyscallError("fcntl", err)
+		}
+		ft.fds = append(ft.fds, nfd)
+	}
+	c.files = append(c.files, ft)
+	return nil
+}
+
+func closeFDs(fds []int) {
+	for _, fd := range fds {
+		_ = unix.Close(fd)
+	}
+}
+
+// isUnixConn reports whether c is a connection-oriented Unix domain socket,
+// which is capable of passing file descriptors and peer credentials.
+func (c *conn) isUnixConn() bool {
+	return c.proto == "unix" || c.proto == "unixpacket"
+}
+
+func (c *conn) PeerCredentials() (PeerCredentials, error) {
+	if !c.isUnixConn() {
+		return PeerCredentials{}, errorx.ErrUnsupportedOp
+	}
+	pid, uid, gid, err := socket.GetPeerCredentials(c.fd)
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+	return PeerCredentials{PID: pid, UID: uid, GID: gid}, nil
+}
+
+func (c *conn) Splice(dst Conn, n int) (int, error) {
+	d, ok := dst.(*conn)
+	if !ok || c.isDatagram || d.isDatagram || c.isPacket || d.isPacket || c.tls != nil || d.tls != nil {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	if d.loop != c.loop {
+		return 0, errorx.ErrNotSameEventLoop
+	}
+	if !d.opened {
+		return 0, net.ErrClosed
+	}
+	if d.writeClosed {
+		return 0, errorx.ErrWriteClosed
+	}
+
+	// Move the data that has been read but not consumed yet in the first place.
+	var moved int
+	if buffered := c.InboundBuffered(); buffered > 0 {
+		if n >= 0 && buffered > n {
+			buffered = n
+		}
+		buf, _ := c.Peek(buffered)
+		if _, err := d.write(buf); err != nil {
+			// dst has been closed, leave the data to the connection.
+			return 0, net.ErrClosed
+		}
+		_, _ = c.Discard(buffered)
+		moved = buffered
+	}
+	if n >= 0 {
+		if n -= moved; n == 0 {
+			return moved, nil
+		}
+	}
+	m, err := c.loop.splice(c, d, n)
+	return moved + m, err
+}
+
+// spliceCopy moves up to n bytes from the socket of src to dst by copying them,
+// n < 0 means no limit. It stops once dst can't take any more data right away
+// or it has been closed.
+func (el *eventloop) spliceCopy(src, dst *conn, n int) (total int, err error) {
+	size := maxSendFileSize
+	if n >= 0 && n < size {
+		size = n
+	}
+	buf := bsPool.Get(size)
+	defer bsPool.Put(buf)
+	for (n < 0 || total < n) && dst.opened && !dst.hasPendingOutbound() {
+		if n >= 0 && n-total < len(buf) {
+			buf = buf[:n-total]
+		}
+		m, err := el.poller.Read(src.fd, buf)
+		if err == unix.EAGAIN || m == 0 {
+			break // the EOF is left to the next read
+		}
+		if err != nil {
+			return total, os.NewSyscallError("read", err)
+		}
+		src.markRead(m)
+		if _, err = dst.write(buf[:m]); err != nil {
+			// Leave the data to src, it's yet to be consumed.
+			_, _ = src.inboundBuffer.Write(buf[:m])
+			return total, net.ErrClosed
+		}
+		total += m
+	}
+	return total, nil
+}
+
+func (c *conn) ConnectionState() (tls.ConnectionState, bool) {
+	if c.tls == nil {
+		return tls.ConnectionState{}, false
+	}
+	return c.tls.conn.ConnectionState(), true
+}
+
 func (c *conn) EventLoop() EventLoop {
 	return c.loop
 }
 
-func (*conn) SetDeadline(_ time.Time) error {
-	return errorx.ErrUnsupportedOp
+func (c *conn) Stats() ConnStats {
+	s := c.stats.snapshot()
+	s.InboundBuffered = c.InboundBuffered()
+	s.OutboundBuffered = c.OutboundBuffered()
+	if strings.HasPrefix(c.proto, "tcp") {
+		s.TCPInfo = tcpInfo(c.fd)
+	}
+	return s
+}
+
+func (c *conn) PacketInfo() PacketInfo {
+	return c.packetInfo
+}
+
+func (c *conn) Listener() string {
+	if c.ln == nil {
+		return ""
+	}
+	return c.ln.protoAddr
+}
+
+// handler returns the event handler of the listener that accepted the connection,
+// or the one of the event-loop if there is none.
+func (c *conn) handler() EventHandler {
+	if c.ln != nil && c.ln.handler != nil {
+		return c.ln.handler
+	}
+	return c.loop.eventHandler
+}
+
+// options returns the options of the listener that accepted the connection,
+// or the ones of the engine if there is none.
+func (c *conn) options() *Options {
+	if c.ln != nil {
+		return c.ln.opts
+	}
+	return c.loop.engine.opts
+}
+
+type deadlineHook struct {
+	read, write bool
+	t           time.Time
+}
+
+func (c *conn) setDeadline(a any) error {
+	hook := a.(*deadlineHook)
+	if !c.opened {
+		if c.proxy == nil && c.tls == nil {
+			return net.ErrClosed
+		}
+		// The PROXY protocol header or TLS handshake is pending,
+		// the deadline is armed once the connection is opened.
+		if hook.read {
+			c.readDeadline = hook.t
+		}
+		if hook.write {
+			c.writeDeadline = hook.t
+		}
+		return nil
+	}
+	if hook.read {
+		c.readTimer = c.resetDeadlineTimer(c.readTimer, hook.t, c.loop.readDeadlineExceeded)
+	}
+	if hook.write {
+		c.writeTimer = c.resetDeadlineTimer(c.writeTimer, hook.t, c.loop.writeDeadlineExceeded)
+	}
+	return nil
+}
+
+// armDeadlines arms the deadlines that have been set before the connection is opened.
+func (c *conn) armDeadlines() {
+	if !c.readDeadline.IsZero() {
+		c.readTimer = c.resetDeadlineTimer(c.readTimer, c.readDeadline, c.loop.readDeadlineExceeded)
+		c.readDeadline = time.Time{}
+	}
+	if !c.writeDeadline.IsZero() {
+		c.writeTimer = c.resetDeadlineTimer(c.writeTimer, c.writeDeadline, c.loop.writeDeadlineExceeded)
+		c.writeDeadline = time.Time{}
+	}
 }
 
-func (*conn) SetReadDeadline(_ time.Time) error {
-	return errorx.ErrUnsupportedOp
+func (c *conn) resetDeadlineTimer(t *netpoll.Timer, deadline time.Time, fn queue.Func) *netpoll.Timer {
+	if deadline.IsZero() {
+		if t != nil {
+			c.loop.poller.DelTimer(t)
+		}
+		return t
+	}
+	if t == nil {
+		t = netpoll.NewTimer(time.Until(deadline), 0, fn, c)
+		c.loop.poller.AddTimer(t)
+		return t
+	}
+	c.loop.poller.ResetTimer(t, time.Until(deadline))
+	return t
 }
 
-func (*conn) SetWriteDeadline(_ time.Time) error {
-	return errorx.ErrUnsupportedOp
+func (c *conn) SetDeadline(t time.Time) error {
+	if c.isDatagram {
+		return errorx.ErrUnsupportedOp
+	}
+	return c.loop.poller.Trigger(queue.HighPriority, c.setDeadline, &deadlineHook{read: true, write: true, t: t})
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	if c.isDatagram {
+		return errorx.ErrUnsupportedOp
+	}
+	return c.loop.poller.Trigger(queue.HighPriority, c.setDeadline, &deadlineHook{read: true, t: t})
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	if c.isDatagram {
+		return errorx.ErrUnsupportedOp
+	}
+	return c.loop.poller.Trigger(queue.HighPriority, c.setDeadline, &deadlineHook{write: true, t: t})
+}
+
+func (c *conn) PauseRead() error {
+	if c.isDatagram {
+		return errorx.ErrUnsupportedOp
+	}
+	return c.loop.poller.Trigger(queue.HighPriority, c.toggleRead, true)
+}
+
+func (c *conn) ResumeRead() error {
+	if c.isDatagram {
+		return errorx.ErrUnsupportedOp
+	}
+	return c.loop.poller.Trigger(queue.HighPriority, c.toggleRead, false)
 }
 
 func (c *conn) SafeContext() (ctx any) {
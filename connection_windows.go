@@ -15,6 +15,7 @@
 package gnet
 
 import (
+	"crypto/tls"
 	"errors"
 	"io"
 	"net"
@@ -56,12 +57,14 @@ type conn struct {
 	ctx           any                 // user-defined context
 	safeCtx       atomic.Pointer[any] // safe user-defined context
 	loop          *eventloop          // owner event-loop
+	ln            *listener           // listener that accepted the connection, nil if there is none
 	buffer        *bbPool.ByteBuffer  // reuse memory of inbound data as a temporary buffer
 	cache         []byte              // temporary cache for the inbound data
 	rawConn       net.Conn            // original connection
 	localAddr     net.Addr            // local server addr
 	remoteAddr    net.Addr            // remote addr
 	inboundBuffer elastic.RingBuffer  // buffer for data from the remote
+	stats         connStats           // statistics of the connection
 }
 
 func packTCPConn(c *conn, buf []byte) *tcpConn {
@@ -222,14 +225,17 @@ func (c *conn) Discard(n int) (int, error) {
 	return n, nil
 }
 
-func (c *conn) Write(p []byte) (int, error) {
+func (c *conn) Write(p []byte) (n int, err error) {
 	if c.rawConn == nil && c.pc == nil {
 		return 0, net.ErrClosed
 	}
 	if c.rawConn != nil {
-		return c.rawConn.Write(p)
+		n, err = c.rawConn.Write(p)
+	} else {
+		n, err = c.pc.WriteTo(p, c.remoteAddr)
 	}
-	return c.pc.WriteTo(p, c.remoteAddr)
+	c.stats.written(n, time.Now())
+	return
 }
 
 func (c *conn) SendTo(p []byte, addr net.Addr) (int, error) {
@@ -241,7 +247,81 @@ func (c *conn) SendTo(p []byte, addr net.Addr) (int, error) {
 		return 0, errorx.ErrInvalidNetworkAddress
 	}
 
-	return c.pc.WriteTo(p, addr)
+	n, err := c.pc.WriteTo(p, addr)
+	c.stats.written(n, time.Now())
+	return n, err
+}
+
+func (c *conn) SendToBatch(bufs [][]byte, addrs []net.Addr) (n int, err error) {
+	if c.pc == nil {
+		return 0, errorx.ErrUnsupportedOp
+	}
+
+	if len(addrs) != len(bufs) {
+		return 0, errorx.ErrInvalidNetworkAddress
+	}
+
+	for ; n < len(bufs); n++ {
+		if addrs[n] == nil {
+			return n, errorx.ErrInvalidNetworkAddress
+		}
+		var m int
+		if m, err = c.pc.WriteTo(bufs[n], addrs[n]); err != nil {
+			return
+		}
+		c.stats.written(m, time.Now())
+	}
+	return
+}
+
+func (c *conn) SendMsg(buf []byte, addr net.Addr, info *PacketInfo) (n int, err error) {
+	if c.pc == nil {
+		return 0, errorx.ErrUnsupportedOp
+	}
+
+	if info != nil && (info.LocalIP != nil || info.IfIndex != 0 || info.TOS != 0) {
+		return 0, errorx.ErrUnsupportedOp
+	}
+	if addr == nil {
+		return c.Write(buf)
+	}
+	return c.SendTo(buf, addr)
+}
+
+func (*conn) SendFDs(_ []int, _ []byte) (int, error) {
+	return 0, errorx.ErrUnsupportedOp
+}
+
+func (c *conn) SendSegments(buf []byte, segmentSize int, addr net.Addr) (n int, err error) {
+	if c.pc == nil {
+		return 0, errorx.ErrUnsupportedOp
+	}
+
+	if segmentSize < 0 {
+		return 0, errorx.ErrNegativeSize
+	}
+	if addr == nil {
+		return 0, errorx.ErrInvalidNetworkAddress
+	}
+	if segmentSize == 0 || segmentSize >= len(buf) {
+		n, err = c.pc.WriteTo(buf, addr)
+		c.stats.written(n, time.Now())
+		return
+	}
+
+	for n < len(buf) {
+		end := n + segmentSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		var m int
+		if m, err = c.pc.WriteTo(buf[n:end], addr); err != nil {
+			return
+		}
+		c.stats.written(m, time.Now())
+		n = end
+	}
+	return
 }
 
 func (c *conn) Writev(bs [][]byte) (int, error) {
@@ -255,7 +335,9 @@ func (c *conn) Writev(bs [][]byte) (int, error) {
 		for i := range bs {
 			_, _ = bb.Write(bs[i])
 		}
-		return c.rawConn.Write(bb.Bytes())
+		n, err := c.rawConn.Write(bb.Bytes())
+		c.stats.written(n, time.Now())
+		return n, err
 	}
 	return 0, net.ErrClosed
 }
@@ -506,6 +588,44 @@ func (c *conn) AsyncWritev(bs [][]byte, cb AsyncCallback) error {
 	})
 }
 
+func (c *conn) SendFile(f *os.File, offset, count int64, cb AsyncCallback) (err error) {
+	if c.pc != nil {
+		return errorx.ErrUnsupportedOp
+	}
+	if offset < 0 || count < 0 {
+		return errorx.ErrNegativeSize
+	}
+
+	fn := func() error {
+		var err error
+		if c.rawConn == nil {
+			err = net.ErrClosed
+		} else {
+			var n int64
+			n, err = io.Copy(c.rawConn, io.NewSectionReader(f, offset, count))
+			c.stats.written(int(n), time.Now())
+			if err == nil && n < count {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		if cb != nil {
+			_ = cb(c, err)
+		}
+		return err
+	}
+
+	select {
+	case c.loop.ch <- fn:
+	default:
+		// If the event-loop channel is full, asynchronize this operation to avoid blocking the eventloop.
+		err = goroutine.DefaultWorkerPool.Submit(func() {
+			c.loop.ch <- fn
+		})
+	}
+
+	return
+}
+
 func (c *conn) Wake(cb AsyncCallback) (err error) {
 	wakeFn := func() (err error) {
 		err = c.loop.wake(c)
@@ -569,6 +689,53 @@ func (c *conn) EventLoop() EventLoop {
 	return c.loop
 }
 
+func (c *conn) Stats() ConnStats {
+	s := c.stats.snapshot()
+	s.InboundBuffered = c.InboundBuffered()
+	return s
+}
+
+func (*conn) PacketInfo() PacketInfo {
+	return PacketInfo{}
+}
+
+func (*conn) ProxyHeader() *ProxyHeader {
+	return nil
+}
+
+func (*conn) ReceivedFDs() []int {
+	return nil
+}
+
+func (*conn) PeerCredentials() (PeerCredentials, error) {
+	return PeerCredentials{}, errorx.ErrUnsupportedOp
+}
+
+func (c *conn) Listener() string {
+	if c.ln == nil {
+		return ""
+	}
+	return c.ln.protoAddr
+}
+
+// handler returns the event handler of the listener that accepted the connection,
+// or the one of the event-loop if there is none.
+func (c *conn) handler() EventHandler {
+	if c.ln != nil && c.ln.handler != nil {
+		return c.ln.handler
+	}
+	return c.loop.eventHandler
+}
+
+// options returns the options of the listener that accepted the connection,
+// or the ones of the engine if there is none.
+func (c *conn) options() *Options {
+	if c.ln != nil {
+		return c.ln.opts
+	}
+	return c.loop.eng.opts
+}
+
 func (*conn) SetDeadline(_ time.Time) error {
 	return errorx.ErrUnsupportedOp
 }
@@ -581,6 +748,26 @@ func (*conn) SetWriteDeadline(_ time.Time) error {
 	return errorx.ErrUnsupportedOp
 }
 
+func (*conn) PauseRead() error {
+	return errorx.ErrUnsupportedOp
+}
+
+func (*conn) ResumeRead() error {
+	return errorx.ErrUnsupportedOp
+}
+
+func (*conn) CloseWrite() error {
+	return errorx.ErrUnsupportedOp
+}
+
+func (*conn) Splice(_ Conn, _ int) (int, error) {
+	return 0, errorx.ErrUnsupportedOp
+}
+
+func (*conn) ConnectionState() (tls.ConnectionState, bool) {
+	return tls.ConnectionState{}, false
+}
+
 func (c *conn) SafeContext() (ctx any) {
 	if p := c.safeCtx.Load(); p != nil {
 		return *p
@@ -19,7 +19,10 @@ package gnet
 import (
 	"context"
 	"errors"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -33,11 +36,17 @@ import (
 )
 
 type engine struct {
-	listeners    map[int]*listener // listeners for accepting incoming connections
-	opts         *Options          // options with engine
-	ingress      *eventloop        // main event-loop that monitors all listeners
-	eventLoops   loadBalancer      // event-loops for handling events
-	inShutdown   atomic.Bool       // whether the engine is in shutdown
+	listeners    map[int]*listener     // listeners for accepting incoming connections
+	mu           sync.RWMutex          // protects listeners and adopted from being modified at runtime
+	adopted      map[*os.File]struct{} // InheritedListeners that have been adopted by listeners
+	opts         *Options              // options with engine
+	ingress      *eventloop            // main event-loop that monitors all listeners
+	eventLoops   loadBalancer          // event-loops for handling events
+	inShutdown   atomic.Bool           // whether the engine is in shutdown
+	inDrain      atomic.Bool           // whether the engine is draining connections
+	admission    admission             // accepted connections counted for the connection limits
+	sessions     atomic.Int32          // number of the open UDP sessions for MaxUDPSessions
+	handshakes   tlsHandshakes         // TLS handshakes in progress for the handshake limits
 	turnOff      context.CancelFunc
 	eventHandler EventHandler // user eventHandler
 	concurrency  struct {
@@ -69,7 +78,7 @@ func (eng *engine) closeEventLoops() {
 		return true
 	})
 	if eng.ingress != nil {
-		for _, ln := range eng.listeners {
+		for _, ln := range eng.ingress.listeners {
 			ln.close()
 		}
 		err := eng.ingress.poller.Close()
@@ -81,20 +90,21 @@ func (eng *engine) closeEventLoops() {
 
 func (eng *engine) runEventLoops(ctx context.Context, numEventLoop int) error {
 	var el0 *eventloop
-	lns := eng.listeners
 	// Create loops locally and bind the listeners.
 	for i := 0; i < numEventLoop; i++ {
-		if i > 0 {
-			lns = make(map[int]*listener, len(eng.listeners))
+		lns := make(map[int]*listener, len(eng.listeners))
+		if i == 0 {
+			lns = cloneListeners(eng.listeners)
+		} else {
 			for _, l := range eng.listeners {
-				ln, err := initListener(l.network, l.address, eng.opts)
+				ln, err := l.clone()
 				if err != nil {
 					return err
 				}
 				lns[ln.fd] = ln
 			}
 		}
-		p, err := netpoll.OpenPoller()
+		p, err := openPoller(eng.opts)
 		if err != nil {
 			return err
 		}
@@ -102,9 +112,12 @@ func (eng *engine) runEventLoops(ctx context.Context, numEventLoop int) error {
 		el.listeners = lns
 		el.engine = eng
 		el.poller = p
-		el.buffer = make([]byte, eng.opts.ReadBufferCap)
+		el.buffer = make([]byte, eng.readBufferCap())
 		el.connections.init()
 		el.eventHandler = eng.eventHandler
+		if eng.opts.Metrics {
+			el.metrics = new(eventloopMetrics)
+		}
 		for _, ln := range lns {
 			if err = el.poller.AddRead(ln.packPollAttachment(el.accept), false); err != nil {
 				return err
@@ -136,17 +149,20 @@ func (eng *engine) runEventLoops(ctx context.Context, numEventLoop int) error {
 
 func (eng *engine) activateReactors(ctx context.Context, numEventLoop int) error {
 	for i := 0; i < numEventLoop; i++ {
-		p, err := netpoll.OpenPoller()
+		p, err := openPoller(eng.opts)
 		if err != nil {
 			return err
 		}
 		el := new(eventloop)
-		el.listeners = eng.listeners
+		el.listeners = cloneListeners(eng.listeners)
 		el.engine = eng
 		el.poller = p
-		el.buffer = make([]byte, eng.opts.ReadBufferCap)
+		el.buffer = make([]byte, eng.readBufferCap())
 		el.connections.init()
 		el.eventHandler = eng.eventHandler
+		if eng.opts.Metrics {
+			el.metrics = new(eventloopMetrics)
+		}
 		eng.eventLoops.register(el)
 	}
 
@@ -156,12 +172,12 @@ func (eng *engine) activateReactors(ctx context.Context, numEventLoop int) error
 		return true
 	})
 
-	p, err := netpoll.OpenPoller()
+	p, err := openPoller(eng.opts)
 	if err != nil {
 		return err
 	}
 	el := new(eventloop)
-	el.listeners = eng.listeners
+	el.listeners = cloneListeners(eng.listeners)
 	el.idx = -1
 	el.engine = eng
 	el.poller = p
@@ -187,12 +203,376 @@ func (eng *engine) activateReactors(ctx context.Context, numEventLoop int) error
 	return nil
 }
 
-func (eng *engine) start(ctx context.Context, numEventLoop int) error {
+func (eng *engine) start(ctx context.Context, numEventLoop int) (err error) {
 	if eng.opts.ReusePort {
-		return eng.runEventLoops(ctx, numEventLoop)
+		err = eng.runEventLoops(ctx, numEventLoop)
+	} else {
+		err = eng.activateReactors(ctx, numEventLoop)
+	}
+	if err == nil && eng.opts.MetricsSink != nil {
+		eng.concurrency.Go(func() error {
+			eng.exportMetrics(ctx)
+			return nil
+		})
+	}
+	return
+}
+
+// metrics returns the snapshot of metrics of all event-loops.
+func (eng *engine) metrics() ([]EventLoopMetrics, error) {
+	if !eng.opts.Metrics {
+		return nil, errorx.ErrUnsupportedOp
+	}
+
+	metrics := make([]EventLoopMetrics, 0, eng.eventLoops.len())
+	eng.eventLoops.iterate(func(i int, el *eventloop) bool {
+		m := el.metrics.snapshot()
+		m.Index = i
+		m.Active = int(el.countConn())
+		m.UrgentTaskQueueDepth, m.TaskQueueDepth = el.poller.TaskQueueLengths()
+		metrics = append(metrics, m)
+		return true
+	})
+	return metrics, nil
+}
+
+// exportMetrics pushes the snapshot of metrics to the MetricsSink periodically until ctx is done.
+func (eng *engine) exportMetrics(ctx context.Context) {
+	interval := eng.opts.MetricsInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if metrics, err := eng.metrics(); err == nil {
+			eng.opts.MetricsSink.Collect(metrics)
+		}
+	}
+}
+
+// drain stops accepting new connections and then notifies the event-loops of draining.
+func (eng *engine) drain() (err error) {
+	if !eng.inDrain.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			// Let the next call start over, the event-loops that have been
+			// notified won't fire OnDraining again.
+			eng.inDrain.Store(false)
+		}
+	}()
+
+	// Make sure no more connections are accepted before the connections are notified.
+	acceptors := eng.acceptors()
+	stopped := make(chan struct{}, len(acceptors))
+	for _, el := range acceptors {
+		el := el
+		err = el.poller.Trigger(queue.HighPriority, func(a any) error {
+			defer func() { stopped <- struct{}{} }()
+			return el.stopAccepting(a)
+		}, nil)
+		if err != nil {
+			return
+		}
+	}
+	for range acceptors {
+		select {
+		case <-stopped:
+		case <-eng.concurrency.ctx.Done():
+			return errorx.ErrEngineInShutdown
+		}
+	}
+
+	eng.eventLoops.iterate(func(i int, el *eventloop) bool {
+		if err = el.poller.Trigger(queue.HighPriority, el.drain, nil); err != nil {
+			eng.opts.Logger.Errorf("failed to enqueue drain signal of high-priority for event-loop(%d): %v", i, err)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// readBufferCap returns the size of the read buffers of event-loops, which fits all listeners.
+func (eng *engine) readBufferCap() int {
+	rbc := eng.opts.ReadBufferCap
+	for _, ln := range eng.listeners {
+		if ln.opts.ReadBufferCap > rbc {
+			rbc = ln.opts.ReadBufferCap
+		}
+	}
+	return rbc
+}
+
+// acceptors returns the event-loops that monitor the listeners.
+func (eng *engine) acceptors() (els []*eventloop) {
+	if eng.ingress != nil {
+		return []*eventloop{eng.ingress}
+	}
+	eng.eventLoops.iterate(func(_ int, el *eventloop) bool {
+		els = append(els, el)
+		return true
+	})
+	return
+}
+
+// growReadBuffers enlarges the read buffers of the event-loops, which are sized when
+// the engine starts, to fit rbc, the ReadBufferCap of a listener added at runtime.
+func (eng *engine) growReadBuffers(rbc int) (err error) {
+	eng.eventLoops.iterate(func(_ int, el *eventloop) bool {
+		err = eng.runOn(el, func() error {
+			el.growBuffer(rbc)
+			return nil
+		})
+		return err == nil
+	})
+	return
+}
+
+// runOn runs fn on the given event-loop and waits for it to return.
+func (eng *engine) runOn(el *eventloop, fn func() error) error {
+	errCh := make(chan error, 1)
+	err := el.poller.Trigger(queue.HighPriority, func(_ any) error {
+		errCh <- fn()
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-errCh:
+		return err
+	case <-eng.concurrency.ctx.Done():
+		return errorx.ErrEngineInShutdown
+	}
+}
+
+// listenerTask is the work of adding or removing a listener on an event-loop.
+type listenerTask struct {
+	el *eventloop
+	ln *listener
+	fn func() error
+}
+
+// dispatch runs the tasks on their event-loops one after another and waits for them,
+// it stops at the first failed task and returns the number of tasks that have succeeded.
+func (eng *engine) dispatch(tasks []listenerTask) (int, error) {
+	for i, t := range tasks {
+		if err := eng.runOn(t.el, t.fn); err != nil {
+			return i, err
+		}
+	}
+	return len(tasks), nil
+}
+
+// addListener opens a listener on the given address and registers it with the
+// main event-loop, or with every event-loop under ReusePort.
+func (eng *engine) addListener(protoAddr, network, address string, opts []Option) error {
+	if (strings.HasPrefix(network, "udp") || network == "unixgram") && !eng.opts.ReusePort {
+		return errorx.ErrUnsupportedOp
+	}
+	if eng.inDrain.Load() {
+		return errorx.ErrEngineInShutdown
+	}
+
+	lnOpts := listenerOptions(eng.opts, opts)
+	if err := checkListenerOptions(network, lnOpts); err != nil {
+		return err
+	}
+	if err := eng.growReadBuffers(lnOpts.ReadBufferCap); err != nil {
+		return err
+	}
+
+	// Open the listeners of the event-loops with the lock held, but leave the registering
+	// to the event-loops after releasing it, since the event-loops might be acquiring it.
+	eng.mu.Lock()
+	for _, ln := range eng.listeners {
+		if ln.is(network, address) {
+			eng.mu.Unlock()
+			return errorx.ErrDuplicateListener
+		}
+	}
+	ln, err := initListener(network, address, lnOpts, eng.adopted)
+	if err != nil {
+		delete(eng.adopted, ln.file) // leave the inherited socket to the next attempt
+		eng.mu.Unlock()
+		ln.close()
+		return err
+	}
+	ln.protoAddr = protoAddr
+
+	var tasks []listenerTask
+	if el := eng.ingress; el != nil {
+		tasks = append(tasks, listenerTask{el, ln, func() error { return el.addListener(ln, el.accept0, true) }})
+	} else {
+		eng.eventLoops.iterate(func(i int, el *eventloop) bool {
+			l := ln
+			if i > 0 {
+				// SO_REUSEPORT is not supported for Unix domain sockets,
+				// so they are only monitored by the first event-loop.
+				if strings.HasPrefix(ln.network, "unix") {
+					return true
+				}
+				if l, err = ln.clone(); err != nil {
+					l.close()
+					return false
+				}
+			}
+			tasks = append(tasks, listenerTask{el, l, func() error { return el.addListener(l, el.accept, false) }})
+			return true
+		})
+	}
+	if err != nil {
+		delete(eng.adopted, ln.file)
+		eng.mu.Unlock()
+		for _, t := range tasks {
+			t.ln.close()
+		}
+		return err
+	}
+	// The event-loops are yet to start if there are no tasks, they'll pick up the new listener.
+	eng.listeners[ln.fd] = ln
+	eng.mu.Unlock()
+
+	n, err := eng.dispatch(tasks)
+	if err == nil {
+		return nil
+	}
+
+	eng.mu.Lock()
+	delete(eng.listeners, ln.fd)
+	delete(eng.adopted, ln.file)
+	eng.mu.Unlock()
+	for _, t := range tasks[:n] {
+		t := t
+		_ = eng.runOn(t.el, func() error { return t.el.removeListener(ln.network, ln.address) })
+	}
+	for _, t := range tasks[n:] {
+		t.ln.close()
+	}
+	return err
+}
+
+// removeListener removes the listener on the given address from the event-loops and closes it.
+func (eng *engine) removeListener(network, address string) error {
+	eng.mu.Lock()
+	fd := -1
+	for _, ln := range eng.listeners {
+		if ln.is(network, address) {
+			fd = ln.fd
+			break
+		}
+	}
+	if fd < 0 {
+		eng.mu.Unlock()
+		return errorx.ErrInvalidNetworkAddress
+	}
+	if len(eng.listeners) == 1 {
+		eng.mu.Unlock()
+		return errorx.ErrUnsupportedOp
+	}
+	ln := eng.listeners[fd]
+	delete(eng.listeners, fd)
+	eng.mu.Unlock()
+
+	// Each event-loop closes its own listener after removing it from the poller.
+	var tasks []listenerTask
+	for _, el := range eng.acceptors() {
+		el := el
+		tasks = append(tasks, listenerTask{el, ln, func() error { return el.removeListener(ln.network, ln.address) }})
+	}
+	_, err := eng.dispatch(tasks)
+	return err
+}
+
+// handoff starts the current executable with the listening sockets passed down, one for each
+// listener: the copies opened by the other event-loops under ReusePort are left out, or else
+// those the new process doesn't adopt would stay in the reuseport group without being served.
+// For the same reason, the stream listeners are closed here once the new process has started.
+func (eng *engine) handoff() (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
 	}
 
-	return eng.activateReactors(ctx, numEventLoop)
+	p, lns, err := eng.startHandoff(exe, wd)
+	if err != nil {
+		return nil, err
+	}
+
+	// The connections queued up on the closed copies are reset, the rest are accepted
+	// by the new process on its duplicates of the listening sockets. The datagram sockets
+	// are kept for the UDP sessions of this process.
+	var tasks []listenerTask
+	for _, el := range eng.acceptors() {
+		el := el
+		for _, ln := range lns {
+			if ln.isDatagram() {
+				continue
+			}
+			ln := ln
+			tasks = append(tasks, listenerTask{el, ln, func() error { return el.removeListener(ln.network, ln.address) }})
+		}
+	}
+	if _, err = eng.dispatch(tasks); err != nil {
+		eng.opts.Logger.Errorf("failed to close the listeners that have been handed off: %v", err)
+	}
+	return p, nil
+}
+
+// startHandoff starts the new process of handoff with a duplicate of each listener,
+// which are returned along with the process.
+func (eng *engine) startHandoff(exe, wd string) (*os.Process, []*listener, error) {
+	eng.mu.RLock()
+	defer eng.mu.RUnlock()
+	lns := make([]*listener, 0, len(eng.listeners))
+	for _, ln := range eng.listeners {
+		lns = append(lns, ln)
+	}
+
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	defer func() {
+		for _, f := range files[listenFDsStart:] {
+			_ = f.Close()
+		}
+	}()
+	for _, ln := range lns {
+		fd, err := ln.dup()
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, os.NewFile(uintptr(fd), ln.network+"://"+ln.address))
+	}
+
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envListenFDs+"=") && !strings.HasPrefix(kv, envListenPID+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, envListenFDs+"="+strconv.Itoa(len(lns)))
+
+	p, err := os.StartProcess(exe, os.Args, &os.ProcAttr{Dir: wd, Env: env, Files: files})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, el := range eng.acceptors() {
+		for _, ln := range el.listeners {
+			ln.handedOff = true
+		}
+	}
+	return p, lns, nil
 }
 
 func (eng *engine) stop(ctx context.Context, s Engine) {
@@ -228,18 +608,27 @@ func (eng *engine) stop(ctx context.Context, s Engine) {
 }
 
 func run(eventHandler EventHandler, listeners []*listener, options *Options, addrs []string) error {
+	if options.MetricsSink != nil {
+		options.Metrics = true
+	}
+
 	numEventLoop := determineEventLoops(options)
 	logging.Infof("Launching gnet with %d event-loops, listening on: %s",
 		numEventLoop, strings.Join(addrs, " | "))
 
 	lns := make(map[int]*listener, len(listeners))
+	adopted := make(map[*os.File]struct{})
 	for _, ln := range listeners {
 		lns[ln.fd] = ln
+		if ln.file != nil {
+			adopted[ln.file] = struct{}{}
+		}
 	}
 	rootCtx, shutdown := context.WithCancel(context.Background())
 	eg, ctx := errgroup.WithContext(rootCtx)
 	eng := engine{
 		listeners:    lns,
+		adopted:      adopted,
 		opts:         options,
 		turnOff:      shutdown,
 		eventHandler: eventHandler,
@@ -278,6 +667,14 @@ func run(eventHandler EventHandler, listeners []*listener, options *Options, add
 	return nil
 }
 
+func cloneListeners(lns map[int]*listener) map[int]*listener {
+	m := make(map[int]*listener, len(lns))
+	for fd, ln := range lns {
+		m[fd] = ln
+	}
+	return m
+}
+
 func setKeepAlive(fd int, enabled bool, idle, intvl time.Duration, cnt int) error {
 	if intvl == 0 {
 		intvl = idle / 5
@@ -303,3 +700,16 @@ func (eng *engine) sendCmd(cmd *asyncCmd, urgent bool) error {
 	return el.poller.Trigger(el.execCmd, cmd)
 }
 */
+
+// openPoller opens a poller for an event-loop, the io_uring-based poller
+// is preferred if it's enabled, and epoll/kqueue is used as the fallback.
+func openPoller(opts *Options) (*netpoll.Poller, error) {
+	if opts.IOURing {
+		p, err := netpoll.OpenIOURingPoller()
+		if err == nil {
+			return p, nil
+		}
+		opts.Logger.Warnf("failed to open io_uring poller, falling back to the default poller: %v", err)
+	}
+	return netpoll.OpenPoller()
+}
@@ -17,7 +17,9 @@ package gnet
 import (
 	"context"
 	"errors"
+	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"golang.org/x/sync/errgroup"
@@ -28,6 +30,7 @@ import (
 
 type engine struct {
 	listeners     []*listener
+	mu            sync.RWMutex // protects listeners
 	opts          *Options     // options with engine
 	eventLoops    loadBalancer // event-loops for handling events
 	inShutdown    atomic.Bool  // whether the engine is in shutdown
@@ -91,11 +94,11 @@ func (eng *engine) start(ctx context.Context, numEventLoop int) error {
 		l := ln
 		if l.pc != nil {
 			eng.concurrency.Go(func() error {
-				return eng.ListenUDP(l.pc)
+				return eng.ListenUDP(l)
 			})
 		} else {
 			eng.concurrency.Go(func() error {
-				return eng.listenStream(l.ln)
+				return eng.listenStream(l)
 			})
 		}
 	}
@@ -103,6 +106,26 @@ func (eng *engine) start(ctx context.Context, numEventLoop int) error {
 	return nil
 }
 
+func (eng *engine) drain() error {
+	return errorx.ErrUnsupportedOp
+}
+
+func (eng *engine) metrics() ([]EventLoopMetrics, error) {
+	return nil, errorx.ErrUnsupportedOp
+}
+
+func (eng *engine) handoff() (*os.Process, error) {
+	return nil, errorx.ErrUnsupportedOp
+}
+
+func (eng *engine) addListener(_, _, _ string, _ []Option) error {
+	return errorx.ErrUnsupportedOp
+}
+
+func (eng *engine) removeListener(_, _ string) error {
+	return errorx.ErrUnsupportedOp
+}
+
 func (eng *engine) stop(ctx context.Context, engine Engine) {
 	<-ctx.Done()
 
@@ -118,6 +141,13 @@ func (eng *engine) stop(ctx context.Context, engine Engine) {
 }
 
 func run(eventHandler EventHandler, listeners []*listener, options *Options, addrs []string) error {
+	if options.TLSConfig != nil || len(options.InheritedListeners) > 0 ||
+		options.Metrics || options.MetricsSink != nil || options.HalfClose ||
+		options.ProxyProtocol != ProxyProtocolOff || options.MaxConnections > 0 ||
+		options.MaxConnectionsPerIP > 0 {
+		return errorx.ErrUnsupportedOp
+	}
+
 	numEventLoop := determineEventLoops(options)
 	logging.Infof("Launching gnet with %d event-loops, listening on: %s",
 		numEventLoop, strings.Join(addrs, " | "))
@@ -25,6 +25,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -40,13 +41,22 @@ import (
 )
 
 type eventloop struct {
-	listeners    map[int]*listener // listeners
-	idx          int               // loop index in the engine loops list
-	engine       *engine           // engine in loop
-	poller       *netpoll.Poller   // epoll or kqueue
-	buffer       []byte            // read packet buffer whose capacity is set by user, default value is 64KB
-	connections  connMatrix        // loop connections storage
-	eventHandler EventHandler      // user eventHandler
+	listeners    map[int]*listener       // listeners
+	idx          int                     // loop index in the engine loops list
+	engine       *engine                 // engine in loop
+	poller       *netpoll.Poller         // epoll or kqueue
+	buffer       []byte                  // read packet buffer whose capacity is set by user, default value is 64KB
+	connections  connMatrix              // loop connections storage
+	eventHandler EventHandler            // user eventHandler
+	draining     bool                    // whether the engine is draining connections
+	acceptPaused bool                    // whether accepting is paused for MaxConnections
+	metrics      *eventloopMetrics       // metrics of the event-loop, nil if metrics are disabled
+	pipe         []int                   // pipe for splicing data between connections, nil until it's needed
+	udpBatch     *gio.MsgBatch           // batch for reading datagrams, nil until it's needed
+	udpBuffers   [][]byte                // buffers of udpBatch
+	udpSessions  map[udpSessionKey]*conn // UDP sessions of listeners, nil until it's needed
+	sessions     atomic.Int32            // number of UDP sessions, which is counted as connections
+	rightsBuffer []byte                  // buffer for the control messages of SCM_RIGHTS, nil until it's needed
 }
 
 func (el *eventloop) Register(ctx context.Context, addr net.Addr) (<-chan RegisteredResult, error) {
@@ -81,8 +91,81 @@ func (el *eventloop) Execute(ctx context.Context, runnable Runnable) error {
 	}, nil)
 }
 
-func (el *eventloop) Schedule(context.Context, Runnable, time.Duration) error {
-	return errorx.ErrUnsupportedOp
+func (el *eventloop) Schedule(ctx context.Context, runnable Runnable, delay time.Duration) error {
+	_, err := el.ScheduleTimer(ctx, runnable, delay, 0)
+	return err
+}
+
+func (el *eventloop) ScheduleTimer(ctx context.Context, runnable Runnable, delay, period time.Duration) (Timer, error) {
+	if el.engine.isShutdown() {
+		return nil, errorx.ErrEngineInShutdown
+	}
+	if runnable == nil {
+		return nil, errorx.ErrNilRunnable
+	}
+	// The poller removes the timer by itself once ctx is done, see netpoll.NewContextTimer.
+	var t *netpoll.Timer
+	t = netpoll.NewContextTimer(ctx, delay, period, func(any) error {
+		if ctx != nil && ctx.Err() != nil {
+			el.poller.DelTimer(t)
+			return nil
+		}
+		err := runnable.Run(ctx)
+		if err != nil && period > 0 {
+			el.poller.DelTimer(t)
+		}
+		return err
+	}, nil)
+	if err := el.poller.Trigger(queue.LowPriority, el.addTimer, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (el *eventloop) AddListener(protoAddr string, callback ListenerCallback, opts ...Option) error {
+	if el.engine.isShutdown() {
+		return errorx.ErrEngineInShutdown
+	}
+	network, addr, err := parseProtoAddr(protoAddr)
+	if err != nil {
+		return err
+	}
+	return el.updateListener(func() error {
+		return el.engine.addListener(protoAddr, network, addr, opts)
+	}, callback)
+}
+
+func (el *eventloop) RemoveListener(protoAddr string, callback ListenerCallback) error {
+	if el.engine.isShutdown() {
+		return errorx.ErrEngineInShutdown
+	}
+	network, addr, err := parseProtoAddr(protoAddr)
+	if err != nil {
+		return err
+	}
+	return el.updateListener(func() error {
+		return el.engine.removeListener(network, addr)
+	}, callback)
+}
+
+// updateListener runs fn on a worker goroutine since fn waits for the event-loops,
+// and then passes the result to callback on the event-loop.
+func (el *eventloop) updateListener(fn func() error, callback ListenerCallback) error {
+	return goroutine.DefaultWorkerPool.Submit(func() {
+		err := fn()
+		if callback == nil {
+			return
+		}
+		_ = el.poller.Trigger(queue.HighPriority, func(any) error {
+			callback(err)
+			return nil
+		}, nil)
+	})
+}
+
+func (el *eventloop) addTimer(a any) error {
+	el.poller.AddTimer(a.(*netpoll.Timer))
+	return nil
 }
 
 func (el *eventloop) Close(c Conn) error {
@@ -94,7 +177,87 @@ func (el *eventloop) getLogger() logging.Logger {
 }
 
 func (el *eventloop) countConn() int32 {
-	return el.connections.loadCount()
+	return el.connections.loadCount() + el.sessions.Load()
+}
+
+// stopAccepting removes the stream-oriented listeners from the poller.
+func (el *eventloop) stopAccepting(_ any) error {
+	for _, ln := range el.listeners {
+		if ln.isDatagram() {
+			continue
+		}
+		// The listener might have been removed from the poller by pauseAccepting.
+		if err := el.poller.Detach(ln.fd); err != nil && !errors.Is(err, unix.ENOENT) {
+			el.getLogger().Errorf("failed to stop accepting on listener(%s://%s): %v", ln.network, ln.address, err)
+		}
+	}
+	return nil
+}
+
+// addListener starts accepting connections on the given listener.
+func (el *eventloop) addListener(ln *listener, handler netpoll.PollEventHandler, edgeTriggered bool) error {
+	if err := el.poller.AddRead(ln.packPollAttachment(handler), edgeTriggered); err != nil {
+		return err
+	}
+	el.listeners[ln.fd] = ln
+	return nil
+}
+
+// removeListener stops accepting connections on the listener bound to the given address and closes it.
+func (el *eventloop) removeListener(network, address string) error {
+	for fd, ln := range el.listeners {
+		if ln.network != network || ln.address != address {
+			continue
+		}
+		// The listener might have been removed from the poller by stopAccepting.
+		if err := el.poller.Detach(fd); err != nil && !errors.Is(err, unix.ENOENT) {
+			el.getLogger().Errorf("failed to remove listener(%s://%s) from poller: %v", network, address, err)
+		}
+		if el.poller.IOURing() {
+			// Drop the sockets accepted by the io_uring-based poller but not taken yet.
+			_ = el.poller.Delete(fd)
+		}
+		delete(el.listeners, fd)
+		for key, c := range el.udpSessions {
+			if key.fd == fd {
+				_ = el.close(c, nil)
+			}
+		}
+		ln.close()
+	}
+	return nil
+}
+
+// drain fires OnDraining on the open connections.
+func (el *eventloop) drain(_ any) (err error) {
+	if el.draining {
+		return nil
+	}
+	el.draining = true
+	el.connections.iterate(func(c *conn) bool {
+		if !c.opened {
+			return true
+		}
+		err = el.notifyDraining(c)
+		return !errors.Is(err, errorx.ErrEngineShutdown)
+	})
+	if errors.Is(err, errorx.ErrEngineShutdown) {
+		return
+	}
+	for _, c := range el.udpSessions {
+		if err = el.notifyDraining(c); errors.Is(err, errorx.ErrEngineShutdown) {
+			return
+		}
+	}
+	return
+}
+
+func (el *eventloop) notifyDraining(c *conn) error {
+	h, ok := c.handler().(DrainHandler)
+	if !ok {
+		return nil
+	}
+	return el.handleAction(c, h.OnDraining(c))
 }
 
 func (el *eventloop) closeConns() {
@@ -103,6 +266,14 @@ func (el *eventloop) closeConns() {
 		_ = el.close(c, nil)
 		return true
 	})
+	for _, c := range el.udpSessions {
+		_ = el.close(c, nil)
+	}
+	if el.pipe != nil {
+		_ = unix.Close(el.pipe[0])
+		_ = unix.Close(el.pipe[1])
+		el.pipe = nil
+	}
 }
 
 type connWithCallback struct {
@@ -156,28 +327,33 @@ func (el *eventloop) enroll(c net.Conn, addr net.Addr, ctx any) (resCh chan Regi
 		)
 		switch c.(type) {
 		case *net.UnixConn:
-			sockAddr, _, _, err = socket.GetUnixSockAddr(c.RemoteAddr().Network(), c.RemoteAddr().String())
+			network := c.RemoteAddr().Network()
+			sockAddr, _, _, err = socket.GetUnixSockAddr(network, c.RemoteAddr().String())
 			if err != nil {
 				resCh <- RegisteredResult{Err: err}
 				return
 			}
+			if network == "unixgram" {
+				gc = newUDPConn(dupFD, el, nil, c.LocalAddr(), sockAddr, true)
+				break
+			}
 			ua := c.LocalAddr().(*net.UnixAddr)
 			ua.Name = c.RemoteAddr().String() + "." + strconv.Itoa(dupFD)
-			gc = newStreamConn("unix", dupFD, el, sockAddr, c.LocalAddr(), c.RemoteAddr())
+			gc = newStreamConn(network, dupFD, el, nil, sockAddr, c.LocalAddr(), c.RemoteAddr())
 		case *net.TCPConn:
 			sockAddr, _, _, _, err = socket.GetTCPSockAddr(c.RemoteAddr().Network(), c.RemoteAddr().String())
 			if err != nil {
 				resCh <- RegisteredResult{Err: err}
 				return
 			}
-			gc = newStreamConn("tcp", dupFD, el, sockAddr, c.LocalAddr(), c.RemoteAddr())
+			gc = newStreamConn("tcp", dupFD, el, nil, sockAddr, c.LocalAddr(), c.RemoteAddr())
 		case *net.UDPConn:
 			sockAddr, _, _, _, err = socket.GetUDPSockAddr(c.RemoteAddr().Network(), c.RemoteAddr().String())
 			if err != nil {
 				resCh <- RegisteredResult{Err: err}
 				return
 			}
-			gc = newUDPConn(dupFD, el, c.LocalAddr(), sockAddr, true)
+			gc = newUDPConn(dupFD, el, nil, c.LocalAddr(), sockAddr, true)
 		default:
 			resCh <- RegisteredResult{Err: fmt.Errorf("unknown type of conn: %T", c)}
 			return
@@ -207,32 +383,63 @@ func (el *eventloop) register(a any) error {
 	if !ok {
 		ccb := a.(*connWithCallback)
 		c = ccb.c
-		defer ccb.cb()
+		if c.tls != nil {
+			// Hold the callback until the TLS handshake completes.
+			c.tls.done = ccb.cb
+		} else {
+			defer ccb.cb()
+		}
 	}
 	return el.register0(c)
 }
 
 func (el *eventloop) register0(c *conn) error {
+	if el.poller.IOURing() && !c.isDatagram && !c.isPacket && !(c.isUnixConn() && c.options().UnixRights) {
+		// Leave the receiving and sending of the stream socket to the io_uring-based poller.
+		c.pollAttachment.Submission = netpoll.SubmitStream
+	}
 	addEvents := el.poller.AddRead
 	if el.engine.opts.EdgeTriggeredIO {
 		addEvents = el.poller.AddReadWrite
 	}
 	if err := addEvents(&c.pollAttachment, el.engine.opts.EdgeTriggeredIO); err != nil {
+		if c.tls != nil {
+			c.tls.finish(err)
+		}
 		_ = unix.Close(c.fd)
 		c.release()
 		return err
 	}
 	el.connections.addConn(c, el.idx)
+	el.metrics.addAccepted()
+	c.stats.open(el.poller.Now())
 	if c.isDatagram && c.remote != nil {
 		return nil
 	}
+	if c.proxy != nil {
+		return el.startProxy(c)
+	}
+	if c.tls != nil {
+		return el.startHandshake(c)
+	}
 	return el.open(c)
 }
 
 func (el *eventloop) open(c *conn) error {
 	c.opened = true
+	c.armDeadlines()
+
+	if c.options().ZeroCopyWriteThreshold > 0 && strings.HasPrefix(c.proto, "tcp") &&
+		c.pollAttachment.Submission == netpoll.SubmitNone {
+		c.zeroCopy = enableZeroCopy(c.fd) == nil
+	}
 
-	out, action := el.eventHandler.OnOpen(c)
+	if timeout := c.options().IdleTimeout; timeout > 0 && !c.isDatagram {
+		c.idleTimer = netpoll.NewTimer(timeout, 0, el.checkIdle, c)
+		el.poller.AddTimer(c.idleTimer)
+	}
+
+	out, action := c.handler().OnOpen(c)
 	if out != nil {
 		if err := c.open(out); err != nil {
 			return err
@@ -240,50 +447,98 @@ func (el *eventloop) open(c *conn) error {
 	}
 
 	if !c.outboundBuffer.IsEmpty() && !el.engine.opts.EdgeTriggeredIO {
-		if err := el.poller.ModReadWrite(&c.pollAttachment, false); err != nil {
+		if err := c.pollEvents(); err != nil {
 			return err
 		}
 	}
 
-	return el.handleAction(c, action)
+	if err := el.handleAction(c, action); err != nil || !c.opened {
+		return err
+	}
+
+	if el.draining && !c.isDatagram {
+		return el.notifyDraining(c)
+	}
+	return nil
 }
 
 func (el *eventloop) read0(a any) error {
 	return el.read(a.(*conn))
 }
 
+// growBuffer enlarges the read buffer to rbc if it's smaller than that.
+func (el *eventloop) growBuffer(rbc int) {
+	if rbc <= len(el.buffer) {
+		return
+	}
+	el.buffer = make([]byte, rbc)
+	// The buffers of the batch are carved out of the read buffer, allocate them again.
+	el.udpBatch, el.udpBuffers = nil, nil
+}
+
+// readBuffer returns the buffer for reading from c, which is capped by the ReadBufferCap of c.
+func (el *eventloop) readBuffer(c *conn) []byte {
+	if rbc := c.options().ReadBufferCap; rbc < len(el.buffer) {
+		return el.buffer[:rbc]
+	}
+	return el.buffer
+}
+
 func (el *eventloop) read(c *conn) error {
-	if !c.opened {
+	if (!c.opened && c.tls == nil && c.proxy == nil) || c.readPause != 0 {
 		return nil
 	}
 
 	var recv int
 	isET := el.engine.opts.EdgeTriggeredIO
 	chunk := el.engine.opts.EdgeTriggeredIOChunk
+	buf := el.readBuffer(c)
 loop:
-	n, err := unix.Read(c.fd, el.buffer)
+	n, err := c.recv(buf)
 	if err != nil || n == 0 {
 		if err == unix.EAGAIN {
+			el.metrics.addReadEAGAIN()
 			return nil
 		}
+		if err == errorx.ErrMessageTruncated {
+			return el.close(c, err)
+		}
 		if n == 0 {
+			if c.options().HalfClose && c.proxy == nil {
+				return el.readClosed(c)
+			}
 			err = io.EOF
 		}
 		return el.close(c, os.NewSyscallError("read", err))
 	}
 	recv += n
+	c.markRead(n)
 
-	c.buffer = el.buffer[:n]
-	action := el.eventHandler.OnTraffic(c)
-	switch action {
-	case None:
-	case Close:
-		return el.close(c, nil)
-	case Shutdown:
-		return errorx.ErrEngineShutdown
+	if c.proxy != nil {
+		if err = el.readProxy(c, buf[:n]); err != nil || (!c.opened && c.tls == nil && c.proxy == nil) {
+			return err
+		}
+	} else if c.tls != nil {
+		if err = el.readTLS(c, buf[:n]); err != nil || c.tls == nil {
+			return err
+		}
+	} else {
+		c.buffer = buf[:n]
+		action, err := el.onTraffic(c)
+		switch action {
+		case None:
+		case Close:
+			return el.close(c, err)
+		case Shutdown:
+			return errorx.ErrEngineShutdown
+		}
+		_, _ = c.inboundBuffer.Write(c.buffer)
+		c.buffer = c.buffer[:0]
+	}
+
+	if c.readPause != 0 {
+		return nil // reading has been paused during OnTraffic
 	}
-	_, _ = c.inboundBuffer.Write(c.buffer)
-	c.buffer = c.buffer[:0]
 
 	if c.isEOF || (isET && recv < chunk) {
 		goto loop
@@ -293,13 +548,31 @@ loop:
 	// we need to set up threshold for the maximum read bytes per connection
 	// on each event-loop. If the threshold is reached and there are still
 	// unread data in the socket buffer, we must issue another read event manually.
-	if isET && n == len(el.buffer) {
+	if isET && n == len(buf) {
 		return el.poller.Trigger(queue.LowPriority, el.read0, c)
 	}
 
 	return nil
 }
 
+// readPending hands the data that has been read but not handled yet
+// over to the event handler after reading from c is resumed.
+func (el *eventloop) readPending(a any) error {
+	c := a.(*conn)
+	if !c.opened || c.readPause != 0 || el.connections.getConn(c.fd) != c {
+		return nil
+	}
+	if !c.inboundBuffer.IsEmpty() {
+		if err := el.wake(c); err != nil || !c.opened || c.readPause != 0 {
+			return err
+		}
+	}
+	if c.tls != nil {
+		return el.readTLS(c, nil)
+	}
+	return nil
+}
+
 func (el *eventloop) write0(a any) error {
 	return el.write(a.(*conn))
 }
@@ -308,7 +581,7 @@ func (el *eventloop) write0(a any) error {
 const iovMax = 1024
 
 func (el *eventloop) write(c *conn) error {
-	if c.outboundBuffer.IsEmpty() {
+	if !c.hasPendingOutbound() {
 		return nil
 	}
 
@@ -320,66 +593,215 @@ func (el *eventloop) write(c *conn) error {
 		err  error
 	)
 loop:
-	iov, _ := c.outboundBuffer.Peek(-1)
-	if len(iov) > 1 {
-		if len(iov) > iovMax {
-			iov = iov[:iovMax]
+	if len(c.files) > 0 && c.files[0].at == c.outboundDrained {
+		if c.files[0].f == nil {
+			n, err = el.sendFDs(c)
+		} else {
+			n, err = el.sendFile(c)
 		}
-		n, err = gio.Writev(c.fd, iov)
 	} else {
-		n, err = unix.Write(c.fd, iov[0])
+		n, err = el.writeOutbound(c)
 	}
-	_, _ = c.outboundBuffer.Discard(n)
 	switch err {
 	case nil:
 	case unix.EAGAIN:
+		el.metrics.addWriteEAGAIN()
 		return nil
 	default:
 		return el.close(c, os.NewSyscallError("write", err))
 	}
 	sent += n
+	c.markWritten(n)
 
-	if isET && !c.outboundBuffer.IsEmpty() && sent < chunk {
+	if isET && c.hasPendingOutbound() && sent < chunk {
 		goto loop
 	}
 
+	if c.writeClosed && !c.hasPendingOutbound() {
+		if err = el.shutdownWrite(c); err != nil || !c.opened {
+			return err
+		}
+	}
+
 	// All data have been sent, it's no need to monitor the writable events for LT mode,
 	// remove the writable event from poller to help the future event-loops if necessary.
-	if !isET && c.outboundBuffer.IsEmpty() {
-		return el.poller.ModRead(&c.pollAttachment, false)
+	if !isET && !c.hasPendingOutbound() {
+		return c.pollEvents()
 	}
 
 	// To prevent infinite writing in ET mode and starving other events,
 	// we need to set up threshold for the maximum write bytes per connection
 	// on each event-loop. If the threshold is reached and there are still
 	// pending data to write, we must issue another write event manually.
-	if isET && !c.outboundBuffer.IsEmpty() {
+	if isET && c.hasPendingOutbound() {
 		return el.poller.Trigger(queue.HighPriority, el.write0, c)
 	}
 
 	return nil
 }
 
+// writeOutbound writes the data in the outbound buffer of c ahead of the pending files.
+func (el *eventloop) writeOutbound(c *conn) (n int, err error) {
+	limit := -1
+	if len(c.files) > 0 {
+		limit = int(c.files[0].at - c.outboundDrained)
+	}
+	iov, _ := c.outboundBuffer.Peek(limit)
+	if len(iov) > 1 {
+		if len(iov) > iovMax {
+			iov = iov[:iovMax]
+		}
+		n, err = el.poller.Writev(c.fd, iov)
+	} else {
+		n, err = el.poller.Write(c.fd, iov[0])
+	}
+	_, _ = c.outboundBuffer.Discard(n)
+	if n > 0 {
+		c.outboundDrained += uint64(n)
+		c.outboundChanged()
+	}
+	return
+}
+
+// sendFile sends the first pending file of c, the callback of the file
+// is invoked once the file is sent completely.
+func (el *eventloop) sendFile(c *conn) (int, error) {
+	ft := c.files[0]
+	size := ft.remaining
+	if size > maxSendFileSize {
+		size = maxSendFileSize
+	}
+	n, err := sendFile(c.fd, ft.f, &ft.offset, int(size))
+	if n > 0 {
+		ft.remaining -= int64(n)
+	}
+	if err != nil {
+		return n, err
+	}
+	if n == 0 && ft.remaining > 0 {
+		return 0, io.ErrUnexpectedEOF // the file is shorter than expected
+	}
+	if ft.remaining == 0 {
+		c.files[0] = nil
+		c.files = c.files[1:]
+		if ft.callback != nil {
+			_ = ft.callback(c, nil)
+		}
+	}
+	return n, nil
+}
+
+// sendFDs sends the data of the first pending transfer of c along with its file descriptors,
+// the duplicated file descriptors are closed once they're passed to the remote.
+func (el *eventloop) sendFDs(c *conn) (int, error) {
+	ft := c.files[0]
+	var oob []byte
+	if len(ft.fds) > 0 {
+		oob = unix.UnixRights(ft.fds...)
+	}
+	n, err := unix.SendmsgN(c.fd, ft.data, oob, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	closeFDs(ft.fds)
+	ft.fds = nil
+	if ft.data = ft.data[n:]; len(ft.data) == 0 {
+		c.files[0] = nil
+		c.files = c.files[1:]
+	}
+	return n, nil
+}
+
+// readClosed handles the EOF of c in the half-close mode.
+func (el *eventloop) readClosed(c *conn) error {
+	if err := c.pauseReading(readPausedByEOF); err != nil {
+		return el.close(c, err)
+	}
+	if h, ok := c.handler().(HalfCloseHandler); ok {
+		if err := el.handleAction(c, h.OnReadClosed(c)); err != nil || !c.opened {
+			return err
+		}
+	}
+	if c.writeClosed && !c.hasPendingOutbound() {
+		return el.close(c, nil) // both sides have been shut down
+	}
+	return nil
+}
+
+// shutdownWrite shuts down the writing side of c after its outbound buffer is drained.
+func (el *eventloop) shutdownWrite(c *conn) error {
+	if err := unix.Shutdown(c.fd, unix.SHUT_WR); err != nil {
+		return el.close(c, os.NewSyscallError("shutdown", err))
+	}
+	if c.readPause&readPausedByEOF != 0 {
+		return el.close(c, nil) // both sides have been shut down
+	}
+	return nil
+}
+
 func (el *eventloop) close(c *conn, err error) error {
-	if !c.opened || el.connections.getConn(c.fd) == nil {
+	if c.session {
+		return el.closeSession(c, err)
+	}
+
+	// OnOpen hasn't been fired for a connection whose PROXY protocol header or TLS handshake
+	// is pending, so neither is OnClose.
+	pending := !c.opened && (c.proxy != nil || c.tls != nil)
+	if (!c.opened && !pending) || el.connections.getConn(c.fd) == nil {
 		return nil // ignore stale connections
 	}
 
 	el.connections.delConn(c)
-	action := el.eventHandler.OnClose(c, err)
+	el.metrics.addClosed()
+	action := None
+	if pending {
+		if c.proxy == nil {
+			el.abortHandshake(c, err)
+		}
+	} else {
+		action = c.handler().OnClose(c, err)
+		if c.tls != nil {
+			_ = c.tls.conn.CloseWrite() // send close_notify alert
+		}
+	}
 
-	// Send residual data in buffer back to the remote before actually closing the connection.
-	for !c.outboundBuffer.IsEmpty() {
-		iov, _ := c.outboundBuffer.Peek(0)
+	// Send residual data in buffer back to the remote before actually closing the connection,
+	// the data behind the pending files is dropped along with the files.
+	residual := c.outboundBuffer.Buffered()
+	if len(c.files) > 0 && c.files[0].at-c.outboundDrained < uint64(residual) {
+		residual = int(c.files[0].at - c.outboundDrained)
+	}
+	for residual > 0 {
+		iov, _ := c.outboundBuffer.Peek(residual)
 		if len(iov) > iovMax {
 			iov = iov[:iovMax]
 		}
-		n, err := gio.Writev(c.fd, iov)
+		n, err := el.poller.Writev(c.fd, iov)
 		if err != nil {
 			break
 		}
 		_, _ = c.outboundBuffer.Discard(n)
+		c.markWritten(n)
+		residual -= n
+	}
+	for _, ft := range c.files {
+		closeFDs(ft.fds)
+		if ft.callback != nil {
+			_ = ft.callback(c, net.ErrClosed)
+		}
 	}
+	c.files = nil
+	// Complete the zero-copy writes whose completions have arrived, the rest are reported with
+	// net.ErrClosed though the kernel may still be sending their data after the socket is closed.
+	if len(c.zeroCopyWrites) > 0 {
+		_ = c.readZeroCopyCompletions()
+	}
+	for _, zw := range c.zeroCopyWrites {
+		if zw.callback != nil {
+			_ = zw.callback(c, net.ErrClosed)
+		}
+	}
+	c.zeroCopyWrites = nil
 
 	c.release()
 
@@ -404,15 +826,71 @@ func (el *eventloop) close(c *conn, err error) error {
 }
 
 func (el *eventloop) wake(c *conn) error {
-	if !c.opened || el.connections.getConn(c.fd) == nil {
+	if !c.opened || (c.session && !el.isSession(c)) || (!c.session && el.connections.getConn(c.fd) == nil) {
 		return nil // ignore stale connections
 	}
 
-	action := el.eventHandler.OnTraffic(c)
+	action, err := el.onTraffic(c)
+	if err != nil {
+		return el.close(c, err)
+	}
 
 	return el.handleAction(c, action)
 }
 
+// onTraffic dispatches the inbound data of c to OnMessage with the codec if the codec
+// is set and the event handler implements MessageHandler, otherwise to OnTraffic.
+func (el *eventloop) onTraffic(c *conn) (Action, error) {
+	if el.metrics != nil {
+		defer el.metrics.observeTraffic(time.Now())
+	}
+	if h, ok := c.handler().(MessageHandler); ok && c.options().Codec != nil {
+		return decodeTraffic(h, c.options().Codec, c)
+	}
+	return c.handler().OnTraffic(c), nil
+}
+
+func (el *eventloop) readDeadlineExceeded(a any) error {
+	return el.deadlineExceeded(a.(*conn), errorx.ErrReadDeadlineExceeded)
+}
+
+func (el *eventloop) writeDeadlineExceeded(a any) error {
+	c := a.(*conn)
+	if !c.hasPendingOutbound() {
+		return nil
+	}
+	return el.deadlineExceeded(c, errorx.ErrWriteDeadlineExceeded)
+}
+
+func (el *eventloop) deadlineExceeded(c *conn, err error) error {
+	if !c.opened || el.connections.getConn(c.fd) != c {
+		return nil // ignore stale connections
+	}
+
+	if h, ok := c.handler().(DeadlineHandler); ok {
+		return el.handleAction(c, h.OnDeadline(c, err))
+	}
+
+	if errors.Is(err, errorx.ErrWriteDeadlineExceeded) {
+		c.outboundBuffer.Release() // don't bother to write to a connection that is not draining
+	}
+	return el.close(c, err)
+}
+
+func (el *eventloop) checkIdle(a any) error {
+	c := a.(*conn)
+	if !c.opened || el.connections.getConn(c.fd) != c {
+		return nil // ignore stale connections
+	}
+
+	timeout := c.options().IdleTimeout
+	if idle := time.Since(c.stats.lastActive); idle < timeout {
+		el.poller.ResetTimer(c.idleTimer, timeout-idle)
+		return nil
+	}
+	return el.close(c, errorx.ErrIdleTimeout)
+}
+
 func (el *eventloop) ticker(ctx context.Context) {
 	var (
 		action Action
@@ -448,25 +926,102 @@ func (el *eventloop) ticker(ctx context.Context) {
 	}
 }
 
-func (el *eventloop) readUDP(fd int, _ netpoll.IOEvent, _ netpoll.IOFlags) error {
+func (el *eventloop) readUDP(fd int, ev netpoll.IOEvent, flags netpoll.IOFlags) error {
+	if el.engine.opts.UDPBatchSize > 1 || el.engine.opts.UDPGRO || el.engine.opts.UDPPacketInfo {
+		return el.readUDPBatch(fd)
+	}
+	return el.readDatagram(fd, ev, flags)
+}
+
+// readDatagram reads a datagram from fd, it reads the unixgram sockets as well as the UDP ones.
+func (el *eventloop) readDatagram(fd int, _ netpoll.IOEvent, _ netpoll.IOFlags) error {
 	n, sa, err := unix.Recvfrom(fd, el.buffer, 0)
 	if err != nil {
 		if err == unix.EAGAIN {
+			el.metrics.addReadEAGAIN()
 			return nil
 		}
-		return fmt.Errorf("failed to read UDP packet from fd=%d in event-loop(%d), %v",
+		return fmt.Errorf("failed to read datagram from fd=%d in event-loop(%d), %v",
 			fd, el.idx, os.NewSyscallError("recvfrom", err))
 	}
+	return el.handleDatagram(fd, el.buffer[:n], sa, PacketInfo{})
+}
+
+// readUDPBatch reads up to UDPBatchSize datagrams from fd at a time along with
+// their ancillary data, the datagrams coalesced by UDP_GRO are split before
+// they're handled.
+func (el *eventloop) readUDPBatch(fd int) error {
+	if el.udpBatch == nil {
+		size := el.engine.opts.UDPBatchSize
+		if size < 1 {
+			size = 1
+		}
+		buf := make([]byte, size*len(el.buffer))
+		el.udpBatch = gio.NewMsgBatch(size)
+		el.udpBuffers = make([][]byte, size)
+		for i := range el.udpBuffers {
+			el.udpBuffers[i] = buf[i*len(el.buffer) : (i+1)*len(el.buffer)]
+		}
+	}
+	n, err := el.udpBatch.Recvmmsg(fd, el.udpBuffers)
+	if err != nil {
+		if err == unix.EAGAIN {
+			el.metrics.addReadEAGAIN()
+			return nil
+		}
+		return fmt.Errorf("failed to read UDP packets from fd=%d in event-loop(%d), %v",
+			fd, el.idx, os.NewSyscallError("recvmmsg", err))
+	}
+	for i := 0; i < n; i++ {
+		data, sa := el.udpBuffers[i][:el.udpBatch.Len(i)], el.udpBatch.Addr(i)
+		var info PacketInfo
+		if el.engine.opts.UDPPacketInfo {
+			info = parsePacketInfo(el.udpBatch.Control(i))
+		}
+		segment := el.udpBatch.SegmentSize(i)
+		if segment <= 0 {
+			segment = len(data)
+		}
+		for {
+			end := segment
+			if end > len(data) {
+				end = len(data)
+			}
+			if err = el.handleDatagram(fd, data[:end], sa, info); err != nil {
+				return err
+			}
+			if data = data[end:]; len(data) == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// handleDatagram hands the datagram read from fd over to the event handler.
+func (el *eventloop) handleDatagram(fd int, data []byte, sa unix.Sockaddr, info PacketInfo) error {
 	var c *conn
 	if ln, ok := el.listeners[fd]; ok {
-		c = newUDPConn(fd, el, ln.addr, sa, false)
+		if ln.opts.UDPSessionTimeout > 0 && ln.network == "udp" {
+			return el.readSession(fd, ln, data, sa, info)
+		}
+		c = newUDPConn(fd, el, ln, ln.addr, sa, false)
+		c.stats.open(el.poller.Now())
 	} else {
 		c = el.connections.getConn(fd)
 	}
-	c.buffer = el.buffer[:n]
-	action := el.eventHandler.OnTraffic(c)
+	c.markRead(len(data))
+	c.buffer = data
+	c.packetInfo = info
+	action, err := el.onTraffic(c)
 	if c.remote != nil {
+		// There is no connection to close for a single datagram, it's dropped on error.
+		if err != nil {
+			el.getLogger().Warnf("failed to handle the datagram from %s: %v", c.remoteAddr, err)
+		}
 		c.release()
+	} else if err != nil {
+		return el.close(c, err)
 	}
 	if action == Shutdown {
 		return errorx.ErrEngineShutdown
@@ -474,6 +1029,104 @@ func (el *eventloop) readUDP(fd int, _ netpoll.IOEvent, _ netpoll.IOFlags) error
 	return nil
 }
 
+// udpSessionKey identifies a UDP session by the listener and the remote address.
+type udpSessionKey struct {
+	fd   int
+	ip   [16]byte
+	port int
+	zone uint32
+}
+
+func newUDPSessionKey(fd int, sa unix.Sockaddr) (key udpSessionKey) {
+	key.fd = fd
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		key.ip[10], key.ip[11] = 0xff, 0xff // IPv4-mapped IPv6 address
+		copy(key.ip[12:], sa.Addr[:])
+		key.port = sa.Port
+	case *unix.SockaddrInet6:
+		key.ip, key.port, key.zone = sa.Addr, sa.Port, sa.ZoneId
+	}
+	return
+}
+
+// readSession hands the datagram over to the UDP session of the remote,
+// the session is opened on the first datagram from the remote.
+func (el *eventloop) readSession(fd int, ln *listener, data []byte, sa unix.Sockaddr, info PacketInfo) error {
+	key := newUDPSessionKey(fd, sa)
+	c := el.udpSessions[key]
+	if c == nil {
+		eng := el.engine
+		if n := eng.sessions.Add(1); eng.opts.MaxUDPSessions > 0 && n > int32(eng.opts.MaxUDPSessions) {
+			eng.sessions.Add(-1)
+			return nil // drop the datagram from the remote without a session
+		}
+		el.sessions.Add(1)
+		c = newUDPConn(fd, el, ln, ln.addr, sa, false)
+		c.session = true
+		if el.udpSessions == nil {
+			el.udpSessions = make(map[udpSessionKey]*conn)
+		}
+		el.udpSessions[key] = c
+		el.metrics.addAccepted()
+		c.stats.open(el.poller.Now())
+		c.idleTimer = netpoll.NewTimer(ln.opts.UDPSessionTimeout, 0, el.checkSession, c)
+		el.poller.AddTimer(c.idleTimer)
+		if err := el.open(c); err != nil || !c.opened {
+			if err != nil && !errors.Is(err, errorx.ErrEngineShutdown) {
+				// Don't leave the session that failed to open behind in the map and the counts.
+				_ = el.closeSession(c, err)
+			}
+			return err
+		}
+	}
+
+	c.markRead(len(data))
+	c.buffer = data
+	c.packetInfo = info
+	action, err := el.onTraffic(c)
+	c.buffer = nil
+	if err != nil {
+		return el.close(c, err)
+	}
+	return el.handleAction(c, action)
+}
+
+// isSession reports whether c is an open UDP session.
+func (el *eventloop) isSession(c *conn) bool {
+	return c.opened && el.udpSessions[newUDPSessionKey(c.fd, c.remote)] == c
+}
+
+// checkSession closes the UDP session that has been idle for too long.
+func (el *eventloop) checkSession(a any) error {
+	c := a.(*conn)
+	if !el.isSession(c) {
+		return nil // ignore stale sessions
+	}
+
+	timeout := c.options().UDPSessionTimeout
+	if idle := time.Since(c.stats.lastActive); idle < timeout {
+		el.poller.ResetTimer(c.idleTimer, timeout-idle)
+		return nil
+	}
+	return el.close(c, errorx.ErrIdleTimeout)
+}
+
+// closeSession closes the UDP session without closing the socket of the listener.
+func (el *eventloop) closeSession(c *conn, err error) error {
+	if !el.isSession(c) {
+		return nil // ignore stale sessions
+	}
+
+	delete(el.udpSessions, newUDPSessionKey(c.fd, c.remote))
+	el.sessions.Add(-1)
+	el.engine.sessions.Add(-1)
+	el.metrics.addClosed()
+	action := c.handler().OnClose(c, err)
+	c.release()
+	return el.handleAction(c, action)
+}
+
 func (el *eventloop) handleAction(c *conn, action Action) error {
 	switch action {
 	case None:
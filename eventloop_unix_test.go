@@ -35,7 +35,7 @@ func (lb *sourceAddrHashLoadBalancer) register(el *eventloop) {
 
 func registerInitConn(el *eventloop) {
 	for i := 0; i < int(atomic.LoadInt32(&nowEventLoopInitConn)); i++ {
-		c := newStreamConn("tcp", i, el, &unix.SockaddrInet4{}, &net.TCPAddr{}, &net.TCPAddr{})
+		c := newStreamConn("tcp", i, el, nil, &unix.SockaddrInet4{}, &net.TCPAddr{}, &net.TCPAddr{})
 		el.connections.addConn(c, el.idx)
 	}
 }
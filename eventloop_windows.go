@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -71,7 +72,77 @@ func (el *eventloop) Execute(ctx context.Context, runnable Runnable) error {
 	})
 }
 
-func (el *eventloop) Schedule(context.Context, Runnable, time.Duration) error {
+func (el *eventloop) Schedule(ctx context.Context, runnable Runnable, delay time.Duration) error {
+	_, err := el.ScheduleTimer(ctx, runnable, delay, 0)
+	return err
+}
+
+type timer struct {
+	mu      sync.Mutex
+	t       *time.Timer
+	stopped bool
+}
+
+func (t *timer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.stopped = true
+	if t.t != nil {
+		t.t.Stop()
+	}
+	return true
+}
+
+func (el *eventloop) ScheduleTimer(ctx context.Context, runnable Runnable, delay, period time.Duration) (Timer, error) {
+	if el.eng.isShutdown() {
+		return nil, errorx.ErrEngineInShutdown
+	}
+	if runnable == nil {
+		return nil, errorx.ErrNilRunnable
+	}
+	t := new(timer)
+	run := func() error {
+		t.mu.Lock()
+		if t.stopped {
+			t.mu.Unlock()
+			return nil
+		}
+		t.stopped = period <= 0 || (ctx != nil && ctx.Err() != nil)
+		t.mu.Unlock()
+		if ctx != nil && ctx.Err() != nil {
+			return nil
+		}
+		err := runnable.Run(ctx)
+		if period > 0 {
+			t.mu.Lock()
+			if err != nil {
+				t.stopped = true
+			} else if !t.stopped {
+				t.t.Reset(period)
+			}
+			t.mu.Unlock()
+		}
+		return err
+	}
+	t.mu.Lock()
+	t.t = time.AfterFunc(delay, func() {
+		select {
+		case el.ch <- run:
+		case <-el.eng.concurrency.ctx.Done():
+		}
+	})
+	t.mu.Unlock()
+	return t, nil
+}
+
+func (el *eventloop) AddListener(_ string, _ ListenerCallback, _ ...Option) error {
+	return errorx.ErrUnsupportedOp
+}
+
+func (el *eventloop) RemoveListener(_ string, _ ListenerCallback) error {
 	return errorx.ErrUnsupportedOp
 }
 
@@ -193,10 +264,11 @@ func (el *eventloop) open(oc *openConn) error {
 	c := oc.c
 	el.connections[c] = struct{}{}
 	el.incConn(1)
+	c.stats.open(time.Now())
 
-	out, action := el.eventHandler.OnOpen(c)
+	out, action := c.handler().OnOpen(c)
 	if out != nil {
-		if _, err := c.rawConn.Write(out); err != nil {
+		if _, err := c.Write(out); err != nil {
 			return err
 		}
 	}
@@ -208,11 +280,12 @@ func (el *eventloop) read(c *conn) error {
 	if _, ok := el.connections[c]; !ok {
 		return nil // ignore stale wakes.
 	}
-	action := el.eventHandler.OnTraffic(c)
+	c.stats.read(c.buffer.Len(), time.Now())
+	action, err := el.onTraffic(c)
 	switch action {
 	case None:
 	case Close:
-		return el.close(c, nil)
+		return el.close(c, err)
 	case Shutdown:
 		return errorx.ErrEngineShutdown
 	}
@@ -223,7 +296,8 @@ func (el *eventloop) read(c *conn) error {
 }
 
 func (el *eventloop) readUDP(c *conn) error {
-	action := el.eventHandler.OnTraffic(c)
+	c.stats.read(c.buffer.Len(), time.Now())
+	action, _ := el.onTraffic(c)
 	if action == Shutdown {
 		return errorx.ErrEngineShutdown
 	}
@@ -275,10 +349,22 @@ func (el *eventloop) wake(c *conn) error {
 	if _, ok := el.connections[c]; !ok {
 		return nil // ignore stale wakes.
 	}
-	action := el.eventHandler.OnTraffic(c)
+	action, err := el.onTraffic(c)
+	if err != nil {
+		return el.close(c, err)
+	}
 	return el.handleAction(c, action)
 }
 
+// onTraffic dispatches the inbound data of c to OnMessage with the codec if the codec
+// is set and the event handler implements MessageHandler, otherwise to OnTraffic.
+func (el *eventloop) onTraffic(c *conn) (Action, error) {
+	if h, ok := c.handler().(MessageHandler); ok && c.options().Codec != nil {
+		return decodeTraffic(h, c.options().Codec, c)
+	}
+	return c.handler().OnTraffic(c), nil
+}
+
 func (el *eventloop) close(c *conn, err error) error {
 	if _, ok := el.connections[c]; c.rawConn == nil || !ok {
 		return nil // ignore stale wakes.
@@ -286,7 +372,7 @@ func (el *eventloop) close(c *conn, err error) error {
 
 	delete(el.connections, c)
 	el.incConn(-1)
-	action := el.eventHandler.OnClose(c, err)
+	action := c.handler().OnClose(c, err)
 	err = c.rawConn.Close()
 	c.release()
 	if err != nil {
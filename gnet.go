@@ -20,9 +20,11 @@ package gnet
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"net/url"
+	"os"
 	"path"
 	"runtime"
 	"strings"
@@ -50,6 +52,29 @@ const (
 	Shutdown
 )
 
+// RejectReason is the reason why an accepted connection is rejected, see RejectHandler.
+type RejectReason int
+
+const (
+	// RejectMaxConnections indicates that MaxConnections has been reached.
+	RejectMaxConnections RejectReason = iota + 1
+
+	// RejectMaxConnectionsPerIP indicates that MaxConnectionsPerIP has been reached
+	// by the source IP of the connection.
+	RejectMaxConnectionsPerIP
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectMaxConnections:
+		return "max connections reached"
+	case RejectMaxConnectionsPerIP:
+		return "max connections per IP reached"
+	default:
+		return "unknown reason"
+	}
+}
+
 // Engine represents an engine context which provides some functions.
 type Engine struct {
 	// eng is the internal engine struct.
@@ -58,7 +83,13 @@ type Engine struct {
 
 // Validate checks whether the engine is available.
 func (e Engine) Validate() error {
-	if e.eng == nil || len(e.eng.listeners) == 0 {
+	if e.eng == nil {
+		return errorx.ErrEmptyEngine
+	}
+	e.eng.mu.RLock()
+	n := len(e.eng.listeners)
+	e.eng.mu.RUnlock()
+	if n == 0 {
 		return errorx.ErrEmptyEngine
 	}
 	if e.eng.isShutdown() {
@@ -67,7 +98,8 @@ func (e Engine) Validate() error {
 	return nil
 }
 
-// CountConnections counts the number of currently active connections and returns it.
+// CountConnections counts the number of currently active connections and returns it,
+// UDP sessions are counted as connections.
 func (e Engine) CountConnections() (count int) {
 	if e.Validate() != nil {
 		return -1
@@ -80,6 +112,17 @@ func (e Engine) CountConnections() (count int) {
 	return
 }
 
+// Metrics returns the snapshot of metrics of all event-loops, ordered by their indexes.
+// It returns errors.ErrUnsupportedOp if the engine is not started with WithMetrics
+// or WithMetricsSink.
+func (e Engine) Metrics() ([]EventLoopMetrics, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e.eng.metrics()
+}
+
 // Register registers the new connection to the event-loop that is chosen
 // based off of the algorithm set by WithLoadBalancing.
 // You should call either of the NewNetConnContext or NewNetAddrContext
@@ -121,6 +164,9 @@ func (e Engine) Dup() (fd int, err error) {
 		return -1, err
 	}
 
+	e.eng.mu.RLock()
+	defer e.eng.mu.RUnlock()
+
 	if len(e.eng.listeners) > 1 {
 		return -1, errorx.ErrUnsupportedOp
 	}
@@ -139,6 +185,9 @@ func (e Engine) DupListener(network, addr string) (int, error) {
 		return -1, err
 	}
 
+	e.eng.mu.RLock()
+	defer e.eng.mu.RUnlock()
+
 	for _, ln := range e.eng.listeners {
 		if ln.network == network && ln.address == addr {
 			return ln.dup()
@@ -148,6 +197,50 @@ func (e Engine) DupListener(network, addr string) (int, error) {
 	return -1, errorx.ErrInvalidNetworkAddress
 }
 
+// AddListener starts listening on the given network address while this Engine is running,
+// the new listener is registered with the main event-loop, or with every event-loop if
+// ReusePort is enabled, in which case a Unix domain socket is only registered with the
+// first event-loop. The given options apply to the listener and its connections as the
+// Options of ListenerConfig do, they default to the Options of this Engine. The read buffers
+// of the event-loops are enlarged if the ReadBufferCap of the listener exceeds them.
+//
+// It waits for the event-loops to register the listener, thus it must not be invoked on an
+// event-loop, e.g. from EventHandler.OnTraffic, use EventLoop.AddListener there instead.
+//
+// Note that UDP and unixgram addresses can only be added when ReusePort is enabled,
+// and no listeners can be added once the Engine starts draining.
+func (e Engine) AddListener(protoAddr string, opts ...Option) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	network, addr, err := parseProtoAddr(protoAddr)
+	if err != nil {
+		return err
+	}
+
+	return e.eng.addListener(protoAddr, network, addr, opts)
+}
+
+// RemoveListener stops listening on the given network address and closes the listener
+// while this Engine is running, the connections accepted from it are not affected.
+// The last listener of an Engine can't be removed, use Stop or Drain instead.
+//
+// It waits for the event-loops to remove the listener, thus it must not be invoked on an
+// event-loop, use EventLoop.RemoveListener there instead.
+func (e Engine) RemoveListener(protoAddr string) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	network, addr, err := parseProtoAddr(protoAddr)
+	if err != nil {
+		return err
+	}
+
+	return e.eng.removeListener(network, addr)
+}
+
 // Stop gracefully shuts down this Engine without interrupting any active event-loops,
 // it waits indefinitely for connections and event-loops to be closed and then shuts down.
 func (e Engine) Stop(ctx context.Context) error {
@@ -171,6 +264,68 @@ func (e Engine) Stop(ctx context.Context) error {
 	}
 }
 
+// Drain gracefully shuts down this Engine by draining connections: it stops accepting new
+// connections, fires OnDraining on the connections if the EventHandler implements DrainHandler,
+// and waits until all connections are closed by themselves or the timeout elapses, after which
+// the remaining connections are closed forcibly and the Engine is shut down as Stop does.
+// CountConnections reports the number of connections yet to be closed during the draining.
+//
+// If ctx is done before the draining completes, the Engine is shut down in the same way,
+// but Drain returns ctx.Err() without waiting for the shutdown to complete. A failed Drain
+// that returns any other error can be retried, or followed by Stop.
+//
+// Note that datagram-oriented listeners keep serving until the Engine is shut down, the UDP
+// sessions are notified with OnDraining and waited for as connections, including the ones
+// opened during the draining.
+func (e Engine) Drain(ctx context.Context, timeout time.Duration) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	if err := e.eng.drain(); err != nil {
+		return err
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for e.CountConnections() > 0 {
+		select {
+		case <-ctx.Done():
+			// Don't leave the Engine half-drained.
+			e.eng.shutdown(nil)
+			return ctx.Err()
+		case <-deadline.C:
+			return e.Stop(ctx)
+		case <-ticker.C:
+		}
+	}
+	return e.Stop(ctx)
+}
+
+// Handoff hands the listeners of this Engine over to a new process for a zero-downtime restart:
+// it starts the current executable with the same arguments and environment, passing down the
+// listening sockets as file descriptors starting from 3 along with the LISTEN_FDS environment
+// variable, which the new process picks up with InheritedListeners. Only one socket is passed
+// down for each listener under ReusePort, and this Engine stops listening on the stream-oriented
+// sockets once the new process has started, so the incoming connections all go to the new process.
+// This Engine is then drained as Drain does with the given timeout.
+//
+// The new process is returned even if the draining fails.
+func (e Engine) Handoff(ctx context.Context, timeout time.Duration) (*os.Process, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	p, err := e.eng.handoff()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, e.Drain(ctx, timeout)
+}
+
 /*
 type asyncCmdType uint8
 
@@ -254,6 +409,14 @@ type Reader interface {
 
 	// InboundBuffered returns the number of bytes that can be read from the current buffer.
 	InboundBuffered() int
+
+	// ReceivedFDs returns the file descriptors passed by SCM_RIGHTS along with the data
+	// that has been read since the last call, it's not concurrency-safe, you must invoke
+	// it within OnTraffic. The caller takes the ownership of the file descriptors and is
+	// responsible for closing them, the ones that are never taken are closed along with
+	// the connection. It's only available for unix and unixpacket sockets with UnixRights
+	// enabled, otherwise it always returns nil.
+	ReceivedFDs() []int
 }
 
 // Writer is an interface that consists of a number of methods for writing that Conn must implement.
@@ -268,6 +431,38 @@ type Writer interface {
 	// address over the UDP socket, otherwise you should use Conn.Write() instead.
 	SendTo(buf []byte, addr net.Addr) (n int, err error)
 
+	// SendToBatch transmits bufs[i] to addrs[i] for each i, it's not concurrency-safe.
+	// The messages are sent by a single sendmmsg(2) on Linux, which is much cheaper than
+	// calling SendTo for each of them when fanning out messages. It returns the number of
+	// messages sent, the rest are not sent if it's less than len(bufs). Like SendTo, it is
+	// available only for UDP sockets, an ErrUnsupportedOp will be returned when it is
+	// called on a non-UDP socket.
+	SendToBatch(bufs [][]byte, addrs []net.Addr) (n int, err error)
+
+	// SendSegments splits buf into datagrams of segmentSize bytes, except for the last one
+	// that can be shorter, and transmits them to the given address, it's not concurrency-safe.
+	// The datagrams are split by the kernel or the NIC with UDP_SEGMENT on Linux if UDPGSO
+	// is enabled, otherwise, they're split by gnet and sent as SendToBatch does. It returns
+	// the number of bytes sent. Like SendTo, it is available only for UDP sockets, an
+	// ErrUnsupportedOp will be returned when it is called on a non-UDP socket.
+	SendSegments(buf []byte, segmentSize int, addr net.Addr) (n int, err error)
+
+	// SendMsg transmits a message to the given address with the ancillary data in info,
+	// e.g., the source address and the TOS of the datagram, it's not concurrency-safe.
+	// The message is sent to the remote of the connection if addr is nil, and it's sent
+	// as SendTo does if info is nil. Setting the ancillary data is only available on Linux
+	// at the moment, an ErrUnsupportedOp will be returned on other platforms. Like SendTo,
+	// it is available only for UDP sockets.
+	SendMsg(buf []byte, addr net.Addr, info *PacketInfo) (n int, err error)
+
+	// SendFDs passes the file descriptors to the remote by SCM_RIGHTS along with data,
+	// which must not be empty, it's not concurrency-safe. Like Write, it's ordered after
+	// the pending data and n is always len(data) if it succeeds. The file descriptors are
+	// duplicated if they can't be sent right away, the caller can therefore close them
+	// after it returns. It is available only for unix and unixpacket sockets without TLS,
+	// an ErrUnsupportedOp will be returned otherwise.
+	SendFDs(fds []int, data []byte) (n int, err error)
+
 	// Writev writes multiple byte slices to remote synchronously, it's not concurrency-safe,
 	// you must invoke it within any method in EventHandler.
 	Writev(bs [][]byte) (n int, err error)
@@ -283,6 +478,8 @@ type Writer interface {
 	// AsyncWrite writes bytes to remote asynchronously, it's concurrency-safe,
 	// you don't have to invoke it within any method in EventHandler,
 	// usually you would call it in an individual goroutine.
+	// The callback is deferred until the kernel finishes with buf when it's sent with
+	// MSG_ZEROCOPY, see ZeroCopyWriteThreshold.
 	//
 	// Note that it will go synchronously with UDP, so it is needless to call
 	// this asynchronous method, we may disable this method for UDP and just
@@ -305,6 +502,12 @@ type Writer interface {
 // it blocks the event-loop.
 type AsyncCallback func(c Conn, err error) error
 
+// ListenerCallback is a callback that will be invoked with the result of
+// EventLoop.AddListener or EventLoop.RemoveListener.
+// This callback will be executed in event-loop, thus it must not block, otherwise,
+// it blocks the event-loop.
+type ListenerCallback func(err error)
+
 // Socket is a set of functions which manipulate the underlying file descriptor of a connection.
 //
 // Note that the methods in this interface are concurrency-safe for concurrent use,
@@ -391,6 +594,14 @@ func (fn RunnableFunc) Run(ctx context.Context) error {
 	return fn(ctx)
 }
 
+// Timer is the handle of a runnable scheduled on an event-loop.
+type Timer interface {
+	// Stop cancels the scheduled runnable, it's concurrency-safe.
+	// It returns false if the runnable has already been executed
+	// or the Timer has already been stopped.
+	Stop() bool
+}
+
 // RegisteredResult is the result of a Register call.
 type RegisteredResult struct {
 	Conn Conn
@@ -411,8 +622,24 @@ type EventLoop interface {
 	// Schedule is like Execute, but it allows you to specify when the runnable is executed.
 	// In other words, the runnable will be executed when the delay duration is reached,
 	// it's concurrency-safe.
-	// TODO(panjf2000): not supported yet, implement this.
+	// The runnable will be discarded if ctx is done before the delay duration is reached.
 	Schedule(ctx context.Context, runnable Runnable, delay time.Duration) error
+	// ScheduleTimer is like Schedule, but it returns a Timer that can be used to cancel
+	// the execution, it's concurrency-safe.
+	// If period is greater than 0, the runnable will be executed repeatedly every period
+	// after the first execution, until ctx is done, the runnable returns a non-nil error,
+	// or the Timer is stopped.
+	ScheduleTimer(ctx context.Context, runnable Runnable, delay, period time.Duration) (Timer, error)
+	// AddListener is like Engine.AddListener, but it can be invoked on the event-loop,
+	// it's concurrency-safe. Instead of waiting for the event-loops to register the listener,
+	// it returns right away and the result is passed to callback on the current event-loop,
+	// callback can be nil.
+	AddListener(protoAddr string, callback ListenerCallback, opts ...Option) error
+	// RemoveListener is like Engine.RemoveListener, but it can be invoked on the event-loop,
+	// it's concurrency-safe. Instead of waiting for the event-loops to remove the listener,
+	// it returns right away and the result is passed to callback on the current event-loop,
+	// callback can be nil.
+	RemoveListener(protoAddr string, callback ListenerCallback) error
 
 	// Close closes the given Conn that belongs to the current event-loop.
 	// It must be called on the same event-loop that the connection belongs to.
@@ -469,14 +696,107 @@ type Conn interface {
 	// Close closes the current connection, implements net.Conn, it's concurrency-safe.
 	Close() error
 
-	// SetDeadline implements net.Conn.
+	// SetDeadline implements net.Conn, it's equivalent to calling both SetReadDeadline
+	// and SetWriteDeadline, it's concurrency-safe. A deadline set while the PROXY protocol
+	// header or TLS handshake is pending takes effect once the connection is opened.
 	SetDeadline(time.Time) error
 
-	// SetReadDeadline implements net.Conn.
+	// SetReadDeadline implements net.Conn, it's concurrency-safe.
+	// The connection is closed with errors.ErrReadDeadlineExceeded when the deadline
+	// is reached, unless the EventHandler implements DeadlineHandler. The deadline is
+	// absolute and it isn't extended by incoming data, call SetReadDeadline again in
+	// OnTraffic to extend it. A zero value for t disables the deadline.
+	//
+	// It is only available for stream-oriented connections on UNIX-like platforms.
 	SetReadDeadline(time.Time) error
 
-	// SetWriteDeadline implements net.Conn.
+	// SetWriteDeadline implements net.Conn, it's concurrency-safe.
+	// The connection is closed with errors.ErrWriteDeadlineExceeded if the outbound
+	// buffer has not been drained when the deadline is reached, unless the EventHandler
+	// implements DeadlineHandler. A zero value for t disables the deadline.
+	//
+	// It is only available for stream-oriented connections on UNIX-like platforms.
 	SetWriteDeadline(time.Time) error
+
+	// PauseRead stops reading from the connection, it's concurrency-safe, and it takes effect
+	// asynchronously on the event-loop of the connection. OnTraffic won't be fired until
+	// ResumeRead is called, the data sent by the remote piles up in the socket buffer of
+	// the kernel and eventually the remote is throttled by the flow control of TCP.
+	//
+	// It is only available for stream-oriented connections on UNIX-like platforms.
+	PauseRead() error
+
+	// ResumeRead resumes reading from the connection paused by PauseRead, it's concurrency-safe.
+	// OnTraffic fires right after reading is resumed if there is data that has been read
+	// but not consumed yet.
+	//
+	// It is only available for stream-oriented connections on UNIX-like platforms.
+	ResumeRead() error
+
+	// CloseWrite shuts down the writing side of the connection after all the pending data
+	// in the outbound buffer is sent, it's concurrency-safe. The remote will read EOF while
+	// it can still send data to the connection, writing to the connection afterward fails
+	// with errors.ErrWriteClosed. The connection is closed once the remote shuts down
+	// its writing side as well, see also HalfClose.
+	//
+	// It is only available for stream-oriented connections on UNIX-like platforms.
+	CloseWrite() error
+
+	// SendFile sends count bytes of f starting from offset to remote asynchronously, it's
+	// concurrency-safe. Like AsyncWrite, it takes effect on the event-loop of the connection,
+	// the file is sent after the data that is pending in the outbound buffer by then, and it
+	// stays in order with AsyncWrite and other calls of SendFile. The callback is invoked once
+	// the file is sent completely or the connection is closed. The file is sent by sendfile(2)
+	// on Linux without copying it into the user space, f must stay open until the callback
+	// is invoked.
+	//
+	// It is only available for stream-oriented connections without TLS.
+	SendFile(f *os.File, offset, count int64, callback AsyncCallback) error
+
+	// Splice moves up to n bytes from the connection to dst, it's not concurrency-safe,
+	// you must invoke it within any method in EventHandler. A negative n means no limit.
+	// The data that has been read but not consumed yet goes first, then it moves the data
+	// from the socket of the connection until there is no more data available or dst can't
+	// take any more data right away, and it returns the number of bytes moved. The data is
+	// moved by splice(2) on Linux without copying it into the user space. If dst fails
+	// in the middle, dst is closed, net.ErrClosed is returned, and the data that has been
+	// taken from the socket but not moved is left in the inbound buffer of the connection.
+	//
+	// The connection and dst must belong to the same event-loop, otherwise it returns
+	// errors.ErrNotSameEventLoop. It is only available for stream-oriented connections
+	// without TLS on UNIX-like platforms.
+	Splice(dst Conn, n int) (int, error)
+
+	// ConnectionState returns basic TLS details about the connection, it's not concurrency-safe,
+	// you must invoke it within any method in EventHandler.
+	// The returned bool is false if TLS is not enabled on the connection.
+	ConnectionState() (state tls.ConnectionState, ok bool)
+
+	// Stats returns the statistics of the connection, it's not concurrency-safe,
+	// you must invoke it within any method in EventHandler. It's still available
+	// in OnClose, which makes it a good place to log the statistics.
+	Stats() ConnStats
+
+	// PacketInfo returns the ancillary data of the datagram being handled by OnTraffic,
+	// it's not concurrency-safe, you must invoke it within OnTraffic. It's only available
+	// for UDP sockets with UDPPacketInfo enabled, otherwise the zero value is returned.
+	PacketInfo() PacketInfo
+
+	// PeerCredentials returns the credentials of the peer process of a unix or unixpacket socket,
+	// it's not concurrency-safe, you must invoke it within any method in EventHandler.
+	// It's not available on all platforms, an ErrUnsupportedOp will be returned for
+	// the platforms that don't support it and for the other sockets.
+	PeerCredentials() (PeerCredentials, error)
+
+	// ProxyHeader returns the PROXY protocol header received at the beginning of the connection,
+	// it's nil if ProxyProtocol is disabled or the connection didn't start with a header.
+	ProxyHeader() *ProxyHeader
+
+	// Listener returns the network address of the listener that the connection came from,
+	// in the form passed to Run, Rotate, RotateListeners, or Engine.AddListener, e.g.,
+	// "tcp://:9000". It returns an empty string for the connections that don't come from
+	// any listener, such as the connections of Client. It's concurrency-safe.
+	Listener() string
 }
 
 type (
@@ -513,6 +833,93 @@ type (
 		OnTick() (delay time.Duration, action Action)
 	}
 
+	// DeadlineHandler is an optional interface that can be implemented by EventHandler
+	// to take over the handling of exceeded deadlines of connections.
+	DeadlineHandler interface {
+		// OnDeadline fires when a deadline set by Conn.SetDeadline, Conn.SetReadDeadline
+		// or Conn.SetWriteDeadline is exceeded, err is either errors.ErrReadDeadlineExceeded
+		// or errors.ErrWriteDeadlineExceeded, both of which wrap os.ErrDeadlineExceeded.
+		// The connection stays open unless the returned action is Close.
+		OnDeadline(c Conn, err error) (action Action)
+	}
+
+	// HandshakeHandler is an optional interface that can be implemented by EventHandler
+	// to get notified when the TLS handshake of a connection completes.
+	HandshakeHandler interface {
+		// OnHandshake fires when the TLS handshake of a connection completes, right before
+		// OnOpen. If err is not nil, the handshake failed or the connection was closed before
+		// the handshake completed, the connection will be closed without firing OnOpen and
+		// OnClose. Returning Close closes the connection without firing OnOpen and OnClose
+		// as well.
+		OnHandshake(c Conn, err error) (action Action)
+	}
+
+	// BackpressureHandler is an optional interface that can be implemented by EventHandler
+	// to get notified when a connection gets under backpressure or out of it.
+	BackpressureHandler interface {
+		// OnBackpressure fires with paused=true when the outbound buffer of a connection
+		// reaches OutboundHighWatermark, it's the signal to stop writing to the connection.
+		// It fires with paused=false when the outbound buffer is drained down to
+		// OutboundLowWatermark afterward, then it's safe to resume writing.
+		// Reading from the connection is paused in the meantime if PauseReadOnBackpressure
+		// is set. Note that this event is fired asynchronously after the watermark is crossed.
+		OnBackpressure(c Conn, paused bool) (action Action)
+	}
+
+	// HalfCloseHandler is an optional interface that can be implemented by EventHandler
+	// to get notified when the remote shuts down the writing side of a connection.
+	HalfCloseHandler interface {
+		// OnReadClosed fires when EOF is read from a connection with HalfClose enabled,
+		// all the data sent by the remote has been handed over to OnTraffic by then.
+		// The connection stays open for writing unless the returned action is Close.
+		OnReadClosed(c Conn) (action Action)
+	}
+
+	// MessageHandler is an optional interface that can be implemented by EventHandler
+	// to receive the messages decoded by the Codec set via WithCodec, in which case
+	// OnMessage takes over OnTraffic.
+	MessageHandler interface {
+		// OnMessage fires for every message decoded from the inbound data of a connection.
+		// Parameter msg is only valid within OnMessage, make a copy of it if you need it
+		// after OnMessage returns. Parameter out, if not nil, is encoded by the Codec and
+		// then written to the connection.
+		OnMessage(c Conn, msg []byte) (out []byte, action Action)
+	}
+
+	// DrainHandler is an optional interface that can be implemented by EventHandler
+	// to get notified when the engine starts draining connections via Engine.Drain.
+	DrainHandler interface {
+		// OnDraining fires on every open connection when the engine starts draining,
+		// as well as on the connections opened afterward, it's the place to tell the
+		// remote to go away, e.g. sending a GOAWAY frame. Returning Close closes the
+		// connection right away.
+		OnDraining(c Conn) (action Action)
+	}
+
+	// RejectHandler is an optional interface that can be implemented by EventHandler
+	// to get notified when an accepted connection is rejected by the connection limits.
+	RejectHandler interface {
+		// OnReject fires on the event-loop that accepted the connection from addr, after
+		// the connection has been closed for reason without firing OnOpen and OnClose.
+		// It's not fired when the listeners stop accepting for MaxConnections under the
+		// RejectPause policy, as no connection is accepted then.
+		OnReject(addr net.Addr, reason RejectReason)
+	}
+
+	// Codec splits the inbound data of connections into messages and frames the outbound messages.
+	Codec interface {
+		// Decode decodes a message from the inbound data of c, it must consume the data of
+		// the message from c and return errors.ErrIncompletePacket if there is not enough
+		// data for a whole message. Any other error closes the connection.
+		// The data is consumed with Conn.Next or Conn.Discard, a message decoded without
+		// consuming anything closes the connection with errors.ErrDecodeNoProgress, since
+		// it would be decoded again and again.
+		Decode(c Conn) ([]byte, error)
+
+		// Encode frames msg that is about to be written to c.
+		Encode(c Conn, msg []byte) ([]byte, error)
+	}
+
 	// BuiltinEventEngine is a built-in implementation of EventHandler which feeds
 	// each method with an empty implementation, you can embed it within your custom
 	// struct when you don't intend to implement the entire EventHandler.
@@ -556,7 +963,7 @@ func (*BuiltinEventEngine) OnTick() (delay time.Duration, action Action) {
 // MaxStreamBufferCap is the default buffer size for each stream-oriented connection(TCP/Unix).
 var MaxStreamBufferCap = 64 * 1024 // 64KB
 
-func createListeners(addrs []string, opts ...Option) ([]*listener, *Options, error) {
+func createListeners(configs []ListenerConfig, opts ...Option) ([]*listener, *Options, error) {
 	options := loadOptions(opts...)
 
 	logger, logFlusher := logging.GetDefaultLogger(), logging.GetDefaultFlusher()
@@ -588,33 +995,16 @@ func createListeners(addrs []string, opts ...Option) ([]*listener, *Options, err
 		options.EdgeTriggeredIOChunk = 1 << 20 // 1MB
 	}
 
-	rbc := options.ReadBufferCap
-	switch {
-	case rbc <= 0:
-		options.ReadBufferCap = MaxStreamBufferCap
-	case rbc <= ring.DefaultBufferSize:
-		options.ReadBufferCap = ring.DefaultBufferSize
-	default:
-		options.ReadBufferCap = math.CeilToPowerOfTwo(rbc)
-	}
-	wbc := options.WriteBufferCap
-	switch {
-	case wbc <= 0:
-		options.WriteBufferCap = MaxStreamBufferCap
-	case wbc <= ring.DefaultBufferSize:
-		options.WriteBufferCap = ring.DefaultBufferSize
-	default:
-		options.WriteBufferCap = math.CeilToPowerOfTwo(wbc)
-	}
+	normalizeBufferCaps(options)
 
 	var hasUDP, hasUnix bool
-	for _, addr := range addrs {
-		proto, _, err := parseProtoAddr(addr)
+	for _, config := range configs {
+		proto, _, err := parseProtoAddr(config.ProtoAddr)
 		if err != nil {
 			return nil, nil, err
 		}
-		hasUDP = hasUDP || strings.HasPrefix(proto, "udp")
-		hasUnix = hasUnix || proto == "unix"
+		hasUDP = hasUDP || strings.HasPrefix(proto, "udp") || proto == "unixgram"
+		hasUnix = hasUnix || strings.HasPrefix(proto, "unix")
 	}
 
 	// SO_REUSEPORT enables duplicate address and port bindings across various
@@ -650,60 +1040,164 @@ func createListeners(addrs []string, opts ...Option) ([]*listener, *Options, err
 	}
 
 	// If there is UDP address in the list, we have no choice but to enable SO_REUSEPORT anyway,
-	// also disable edge-triggered I/O for UDP by default.
+	// also disable edge-triggered I/O for UDP by default. The same goes for unixgram, which
+	// is read from in the same way as UDP.
 	if hasUDP {
 		options.ReusePort = true
 		options.EdgeTriggeredIO = false
 	}
 
-	listeners := make([]*listener, len(addrs))
-	for i, a := range addrs {
-		proto, addr, err := parseProtoAddr(a)
+	listeners := make([]*listener, len(configs))
+	adopted := make(map[*os.File]struct{})
+	for i, config := range configs {
+		proto, addr, err := parseProtoAddr(config.ProtoAddr)
 		if err != nil {
 			return nil, nil, err
 		}
-		ln, err := initListener(proto, addr, options)
+		lnOpts := listenerOptions(options, config.Options)
+		if err = checkListenerOptions(proto, lnOpts); err != nil {
+			return nil, nil, err
+		}
+		ln, err := initListener(proto, addr, lnOpts, adopted)
 		if err != nil {
 			return nil, nil, err
 		}
+		ln.protoAddr, ln.handler = config.ProtoAddr, config.Handler
 		listeners[i] = ln
 	}
 
 	return listeners, options, nil
 }
 
+func normalizeBufferCaps(options *Options) {
+	rbc := options.ReadBufferCap
+	switch {
+	case rbc <= 0:
+		options.ReadBufferCap = MaxStreamBufferCap
+	case rbc <= ring.DefaultBufferSize:
+		options.ReadBufferCap = ring.DefaultBufferSize
+	default:
+		options.ReadBufferCap = math.CeilToPowerOfTwo(rbc)
+	}
+	wbc := options.WriteBufferCap
+	switch {
+	case wbc <= 0:
+		options.WriteBufferCap = MaxStreamBufferCap
+	case wbc <= ring.DefaultBufferSize:
+		options.WriteBufferCap = ring.DefaultBufferSize
+	default:
+		options.WriteBufferCap = math.CeilToPowerOfTwo(wbc)
+	}
+}
+
+// listenerOptions returns the Options of a listener, which are the Options of the engine
+// overridden by opts, except for the engine-wide ones that can't vary between listeners.
+func listenerOptions(base *Options, opts []Option) *Options {
+	if len(opts) == 0 {
+		return base
+	}
+
+	options := *base
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.LB = base.LB
+	options.ReusePort = base.ReusePort
+	options.Multicore = base.Multicore
+	options.NumEventLoop = base.NumEventLoop
+	options.LockOSThread = base.LockOSThread
+	options.Ticker = base.Ticker
+	options.LogPath = base.LogPath
+	options.LogLevel = base.LogLevel
+	options.Logger = base.Logger
+	options.EdgeTriggeredIO = base.EdgeTriggeredIO
+	options.EdgeTriggeredIOChunk = base.EdgeTriggeredIOChunk
+	options.IOURing = base.IOURing
+	options.UDPBatchSize = base.UDPBatchSize
+	options.UDPGRO = base.UDPGRO
+	options.UDPGSO = base.UDPGSO
+	options.UDPPacketInfo = base.UDPPacketInfo
+	options.MaxUDPSessions = base.MaxUDPSessions
+	options.InheritedListeners = base.InheritedListeners
+	options.Metrics = base.Metrics
+	options.MetricsSink = base.MetricsSink
+	options.MetricsInterval = base.MetricsInterval
+	options.MaxConnections = base.MaxConnections
+	options.MaxConnectionsPerIP = base.MaxConnectionsPerIP
+	options.MaxTLSHandshakes = base.MaxTLSHandshakes
+	options.MaxTLSHandshakesPerIP = base.MaxTLSHandshakesPerIP
+	options.RejectPolicy = base.RejectPolicy
+	normalizeBufferCaps(&options)
+	return &options
+}
+
 // Run starts handling events on the specified address.
 //
 // Address should use a scheme prefix and be formatted
 // like `tcp://192.168.0.10:9851` or `unix://socket`.
 // Valid network schemes:
 //
-//	tcp   - bind to both IPv4 and IPv6
-//	tcp4  - IPv4
-//	tcp6  - IPv6
-//	udp   - bind to both IPv4 and IPv6
-//	udp4  - IPv4
-//	udp6  - IPv6
-//	unix  - Unix Domain Socket
+//	tcp        - bind to both IPv4 and IPv6
+//	tcp4       - IPv4
+//	tcp6       - IPv6
+//	udp        - bind to both IPv4 and IPv6
+//	udp4       - IPv4
+//	udp6       - IPv6
+//	unix       - Unix Domain Socket
+//	unixgram   - datagram-oriented Unix Domain Socket
+//	unixpacket - message-oriented Unix Domain Socket
+//
+// Each OnTraffic of a unixpacket connection is handed exactly one message, provided that
+// the message is fully consumed there: any bytes left behind are merged with the following
+// messages and their boundaries are lost. A message larger than ReadBufferCap closes the
+// connection with errors.ErrMessageTruncated. Each Write to it is sent as a single message,
+// Writev joins the buffers into one message. The datagrams of unixgram are handled in the
+// same way as UDP, note that the replies can only be sent to the peers that are bound to
+// a path.
 //
 // The "tcp" network scheme is assumed when one is not specified.
 func Run(eventHandler EventHandler, protoAddr string, opts ...Option) error {
-	listeners, options, err := createListeners([]string{protoAddr}, opts...)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		for _, ln := range listeners {
-			ln.close()
-		}
-		logging.Cleanup()
-	}()
-	return run(eventHandler, listeners, options, []string{protoAddr})
+	return RotateListeners(eventHandler, []ListenerConfig{{ProtoAddr: protoAddr}}, opts...)
 }
 
 // Rotate is like Run but accepts multiple network addresses.
 func Rotate(eventHandler EventHandler, addrs []string, opts ...Option) error {
-	listeners, options, err := createListeners(addrs, opts...)
+	configs := make([]ListenerConfig, len(addrs))
+	for i, addr := range addrs {
+		configs[i].ProtoAddr = addr
+	}
+	return RotateListeners(eventHandler, configs, opts...)
+}
+
+// ListenerConfig is the configuration of a listener for RotateListeners.
+type ListenerConfig struct {
+	// ProtoAddr is the network address to listen on, see Run for its format.
+	ProtoAddr string
+
+	// Handler handles the events of the connections accepted by this listener,
+	// the EventHandler passed to RotateListeners is used if it's nil.
+	// OnBoot, OnShutdown, and OnTick are only fired on the EventHandler
+	// passed to RotateListeners.
+	Handler EventHandler
+
+	// Options override the options of the engine for this listener, which only take effect
+	// on the listening socket and its connections: ReuseAddr, MulticastInterfaceIndex,
+	// BindToDevice, ReadBufferCap, WriteBufferCap, OutboundHighWatermark,
+	// OutboundLowWatermark, PauseReadOnBackpressure, ZeroCopyWriteThreshold, TCPKeepAlive,
+	// TCPKeepInterval, TCPKeepCount, TCPNoDelay, SocketRecvBuffer, SocketSendBuffer,
+	// IdleTimeout, UDPSessionTimeout, HalfClose, UnixRights, TLSConfig, TLSHandshakeTimeout,
+	// ProxyProtocol, ProxyProtocolTrustedCIDRs, ProxyProtocolTimeout, RejectResponse,
+	// and Codec. The rest are engine-wide.
+	//
+	// Note that ReadBufferCap can't exceed the largest ReadBufferCap among the engine
+	// and the listeners passed to RotateListeners.
+	Options []Option
+}
+
+// RotateListeners is like Rotate, but it allows each listener to have
+// its own EventHandler and Options, see ListenerConfig for details.
+func RotateListeners(eventHandler EventHandler, configs []ListenerConfig, opts ...Option) error {
+	listeners, options, err := createListeners(configs, opts...)
 	if err != nil {
 		return err
 	}
@@ -713,9 +1207,34 @@ func Rotate(eventHandler EventHandler, addrs []string, opts ...Option) error {
 		}
 		logging.Cleanup()
 	}()
+	addrs := make([]string, len(configs))
+	for i, config := range configs {
+		addrs[i] = config.ProtoAddr
+	}
 	return run(eventHandler, listeners, options, addrs)
 }
 
+// InheritedListeners returns the listening sockets passed down to the current process
+// by Engine.Handoff or the socket activation of systemd, namely the file descriptors
+// starting from 3 whose number is specified by the LISTEN_FDS environment variable.
+// It returns nil if there are none or the LISTEN_PID environment variable doesn't
+// match the current process. The environment variables are unset on return, so that
+// they are not inherited by the child processes.
+func InheritedListeners() ([]*os.File, error) {
+	return inheritedListeners()
+}
+
+// RunWithListeners is like Rotate, but it serves on the inherited listening sockets
+// rather than the given network addresses, see also WithInheritedListeners.
+func RunWithListeners(eventHandler EventHandler, files []*os.File, opts ...Option) error {
+	addrs, err := listenerProtoAddrs(files)
+	if err != nil {
+		return err
+	}
+	opts = append(opts[:len(opts):len(opts)], WithInheritedListeners(files...))
+	return Rotate(eventHandler, addrs, opts...)
+}
+
 var (
 	allEngines sync.Map
 
@@ -787,7 +1306,7 @@ func parseProtoAddr(protoAddr string) (string, string, error) {
 			return "", "", errorx.ErrInvalidNetworkAddress
 		}
 		return u.Scheme, u.Host, nil
-	case "unix":
+	case "unix", "unixgram", "unixpacket":
 		hostPath := path.Join(u.Host, u.Path)
 		if hostPath == "" {
 			return "", "", errorx.ErrInvalidNetworkAddress
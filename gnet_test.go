@@ -542,6 +542,25 @@ func TestServer(t *testing.T) {
 	})
 }
 
+func TestServerWithIOURing(t *testing.T) {
+	t.Run("poll-LT", func(t *testing.T) {
+		t.Run("multi-addrs", func(t *testing.T) {
+			runServer(t, []string{"tcp://:9991", "udp://:9993", "unix://" + testUnixAddr(t)}, &testConf{false, 0, false, true, false, false, 10, LeastConnections}, WithIOURing(true))
+		})
+		t.Run("multi-addrs-reuseport-async", func(t *testing.T) {
+			runServer(t, []string{"tcp://:9995", "udp://:9997", "unix://" + testUnixAddr(t)}, &testConf{false, 0, true, true, true, false, 10, RoundRobin}, WithIOURing(true))
+		})
+	})
+	t.Run("poll-ET", func(t *testing.T) {
+		t.Run("multi-addrs-writev", func(t *testing.T) {
+			runServer(t, []string{"tcp://:9991", "unix://" + testUnixAddr(t)}, &testConf{true, 0, false, true, false, true, 10, LeastConnections}, WithIOURing(true))
+		})
+		t.Run("multi-addrs-reuseport-async-writev", func(t *testing.T) {
+			runServer(t, []string{"tcp://:9995", "unix://" + testUnixAddr(t)}, &testConf{true, 1 << 18, true, false, true, true, 10, RoundRobin}, WithIOURing(true))
+		})
+	})
+}
+
 type testServer struct {
 	*BuiltinEventEngine
 	tester       *testing.T
@@ -726,9 +745,15 @@ func (s *testServer) OnTraffic(c Conn) (action Action) {
 		n, err := c.Discard(1)
 		assert.NoErrorf(s.tester, err, "discard error")
 		assert.Zerof(s.tester, n, "discard error")
-		assert.ErrorIs(s.tester, c.SetDeadline(time.Now().Add(time.Second)), errorx.ErrUnsupportedOp)
-		assert.ErrorIs(s.tester, c.SetReadDeadline(time.Now().Add(time.Second)), errorx.ErrUnsupportedOp)
-		assert.ErrorIs(s.tester, c.SetWriteDeadline(time.Now().Add(time.Second)), errorx.ErrUnsupportedOp)
+		if c.LocalAddr().Network() == "udp" {
+			assert.ErrorIs(s.tester, c.SetDeadline(time.Now().Add(time.Second)), errorx.ErrUnsupportedOp)
+			assert.ErrorIs(s.tester, c.SetReadDeadline(time.Now().Add(time.Second)), errorx.ErrUnsupportedOp)
+			assert.ErrorIs(s.tester, c.SetWriteDeadline(time.Now().Add(time.Second)), errorx.ErrUnsupportedOp)
+		} else {
+			assert.NoError(s.tester, c.SetReadDeadline(time.Now().Add(time.Second)), "set read deadline error")
+			assert.NoError(s.tester, c.SetWriteDeadline(time.Now().Add(time.Second)), "set write deadline error")
+			assert.NoError(s.tester, c.SetDeadline(time.Time{}), "clear deadline error")
+		}
 
 		if c.LocalAddr().Network() == "udp" {
 			n, err := c.Writev([][]byte{})
@@ -782,7 +807,7 @@ func (s *testServer) OnTick() (delay time.Duration, action Action) {
 	return
 }
 
-func runServer(t *testing.T, addrs []string, conf *testConf) {
+func runServer(t *testing.T, addrs []string, conf *testConf, opts ...Option) {
 	ts := &testServer{
 		tester:    t,
 		addrs:     addrs,
@@ -795,31 +820,35 @@ func runServer(t *testing.T, addrs []string, conf *testConf) {
 	if len(addrs) > 1 {
 		err = Rotate(ts,
 			addrs,
-			WithEdgeTriggeredIO(conf.et),
-			WithEdgeTriggeredIOChunk(conf.etChunk),
-			WithLockOSThread(conf.async),
-			WithMulticore(conf.multicore),
-			WithReusePort(conf.reuseport),
-			WithTicker(true),
-			WithTCPKeepAlive(time.Minute),
-			WithTCPKeepInterval(time.Second*10),
-			WithTCPKeepCount(10),
-			WithTCPNoDelay(TCPNoDelay),
-			WithLoadBalancing(conf.lb))
+			append([]Option{
+				WithEdgeTriggeredIO(conf.et),
+				WithEdgeTriggeredIOChunk(conf.etChunk),
+				WithLockOSThread(conf.async),
+				WithMulticore(conf.multicore),
+				WithReusePort(conf.reuseport),
+				WithTicker(true),
+				WithTCPKeepAlive(time.Minute),
+				WithTCPKeepInterval(time.Second * 10),
+				WithTCPKeepCount(10),
+				WithTCPNoDelay(TCPNoDelay),
+				WithLoadBalancing(conf.lb),
+			}, opts...)...)
 	} else {
 		err = Run(ts,
 			addrs[0],
-			WithEdgeTriggeredIO(conf.et),
-			WithEdgeTriggeredIOChunk(conf.etChunk),
-			WithLockOSThread(conf.async),
-			WithMulticore(conf.multicore),
-			WithReusePort(conf.reuseport),
-			WithTicker(true),
-			WithTCPKeepAlive(time.Minute),
-			WithTCPKeepInterval(time.Second*10),
-			WithTCPKeepCount(10),
-			WithTCPNoDelay(TCPDelay),
-			WithLoadBalancing(conf.lb))
+			append([]Option{
+				WithEdgeTriggeredIO(conf.et),
+				WithEdgeTriggeredIOChunk(conf.etChunk),
+				WithLockOSThread(conf.async),
+				WithMulticore(conf.multicore),
+				WithReusePort(conf.reuseport),
+				WithTicker(true),
+				WithTCPKeepAlive(time.Minute),
+				WithTCPKeepInterval(time.Second * 10),
+				WithTCPKeepCount(10),
+				WithTCPNoDelay(TCPDelay),
+				WithLoadBalancing(conf.lb),
+			}, opts...)...)
 	}
 	assert.NoError(t, err)
 }
@@ -2211,8 +2240,8 @@ func (p *streamProxyServer) OnOpen(c Conn) (out []byte, action Action) {
 		assert.ErrorIsf(p.tester, err, errorx.ErrNilRunnable, "Expected error: %v, but got: %v",
 			errorx.ErrNilRunnable, err)
 		err = c.EventLoop().Schedule(context.Background(), nil, time.Millisecond)
-		assert.ErrorIsf(p.tester, err, errorx.ErrUnsupportedOp, "Expected error: %v, but got: %v",
-			errorx.ErrUnsupportedOp, err)
+		assert.ErrorIsf(p.tester, err, errorx.ErrNilRunnable, "Expected error: %v, but got: %v",
+			errorx.ErrNilRunnable, err)
 
 		network, addr, err := parseProtoAddr(backendServer)
 		assert.NoError(p.tester, err, "parseProtoAddr error")
@@ -2472,8 +2501,8 @@ func testStreamProxyServer(t *testing.T, addr string, backendServers []string, m
 	require.ErrorIsf(t, err, errorx.ErrEngineInShutdown, "Expected error: %v, but got: %v",
 		errorx.ErrEngineInShutdown, err)
 	err = srv.eventLoop.Schedule(context.Background(), nil, time.Millisecond)
-	require.ErrorIsf(t, err, errorx.ErrUnsupportedOp, "Expected error: %v, but got: %v",
-		errorx.ErrUnsupportedOp, err)
+	require.ErrorIsf(t, err, errorx.ErrEngineInShutdown, "Expected error: %v, but got: %v",
+		errorx.ErrEngineInShutdown, err)
 
 	for _, server := range netServers {
 		require.NoError(t, server.Close(), "Close backend server error")
@@ -2884,3 +2913,509 @@ func testSafeContext(t *testing.T, network, addr string) {
 		return events.closedConn.SafeContext() == nil
 	}, time.Second, time.Millisecond, "SafeContext() must be nil once the connection is released")
 }
+
+func TestSchedule(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testSchedule(t, "tcp", ":9900")
+	})
+	t.Run("unix", func(t *testing.T) {
+		testSchedule(t, "unix", testUnixAddr(t))
+	})
+}
+
+type testScheduleServer struct {
+	BuiltinEventEngine
+	tester        *testing.T
+	network, addr string
+
+	periodic int32
+	stopped  int32
+	canceled int32
+	done     chan struct{}
+}
+
+func (s *testScheduleServer) OnBoot(Engine) (action Action) {
+	err := goPool.DefaultWorkerPool.Submit(func() {
+		c, err := net.Dial(s.network, s.addr)
+		assert.NoError(s.tester, err)
+		defer c.Close() //nolint:errcheck
+
+		buf := make([]byte, len("scheduled"))
+		_, err = io.ReadFull(c, buf)
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, "scheduled", string(buf))
+	})
+	assert.NoError(s.tester, err)
+	return None
+}
+
+func (s *testScheduleServer) OnOpen(c Conn) (out []byte, action Action) {
+	el := c.EventLoop()
+	start := time.Now()
+
+	err := el.Schedule(context.Background(), RunnableFunc(func(context.Context) error {
+		assert.GreaterOrEqual(s.tester, time.Since(start), 50*time.Millisecond)
+		assert.EqualValues(s.tester, 3, atomic.LoadInt32(&s.periodic), "periodic runnable should run 3 times")
+		_, err := c.Write([]byte("scheduled"))
+		return err
+	}), 50*time.Millisecond)
+	assert.NoError(s.tester, err)
+
+	_, err = el.ScheduleTimer(context.Background(), RunnableFunc(func(context.Context) error {
+		if atomic.AddInt32(&s.periodic, 1) == 3 {
+			return errors.New("stop the periodic runnable")
+		}
+		return nil
+	}), time.Millisecond, 5*time.Millisecond)
+	assert.NoError(s.tester, err)
+
+	timer, err := el.ScheduleTimer(context.Background(), RunnableFunc(func(context.Context) error {
+		atomic.AddInt32(&s.stopped, 1)
+		return nil
+	}), 10*time.Millisecond, 0)
+	assert.NoError(s.tester, err)
+	assert.True(s.tester, timer.Stop())
+	assert.False(s.tester, timer.Stop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = el.Schedule(ctx, RunnableFunc(func(context.Context) error {
+		atomic.AddInt32(&s.canceled, 1)
+		return nil
+	}), 10*time.Millisecond)
+	assert.NoError(s.tester, err)
+	cancel()
+
+	_, err = el.ScheduleTimer(context.Background(), nil, time.Millisecond, 0)
+	assert.ErrorIs(s.tester, err, errorx.ErrNilRunnable)
+	return
+}
+
+func (s *testScheduleServer) OnClose(Conn, error) (action Action) {
+	assert.EqualValues(s.tester, 3, atomic.LoadInt32(&s.periodic))
+	assert.Zero(s.tester, atomic.LoadInt32(&s.stopped), "stopped runnable should not run")
+	assert.Zero(s.tester, atomic.LoadInt32(&s.canceled), "runnable with canceled context should not run")
+	close(s.done)
+	return Shutdown
+}
+
+func testSchedule(t *testing.T, network, addr string) {
+	events := &testScheduleServer{tester: t, network: network, addr: addr, done: make(chan struct{})}
+	err := Run(events, network+"://"+addr, WithReuseAddr(true))
+	assert.NoError(t, err)
+	select {
+	case <-events.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for test completion")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	t.Run("tcp-read", func(t *testing.T) {
+		testDeadline(t, "tcp", ":9901", false)
+	})
+	t.Run("tcp-write", func(t *testing.T) {
+		testDeadline(t, "tcp", ":9902", true)
+	})
+	t.Run("unix-read", func(t *testing.T) {
+		testDeadline(t, "unix", testUnixAddr(t), false)
+	})
+	t.Run("unix-write", func(t *testing.T) {
+		testDeadline(t, "unix", testUnixAddr(t), true)
+	})
+}
+
+type testDeadlineServer struct {
+	BuiltinEventEngine
+	tester        *testing.T
+	network, addr string
+	write         bool
+
+	opened    time.Time
+	deadlines int32
+	done      chan struct{}
+}
+
+func (s *testDeadlineServer) OnBoot(Engine) (action Action) {
+	err := goPool.DefaultWorkerPool.Submit(func() {
+		c, err := net.Dial(s.network, s.addr)
+		assert.NoError(s.tester, err)
+		defer c.Close() //nolint:errcheck
+		<-s.done
+	})
+	assert.NoError(s.tester, err)
+	return None
+}
+
+func (s *testDeadlineServer) OnOpen(c Conn) (out []byte, action Action) {
+	s.opened = time.Now()
+	if !s.write {
+		assert.NoError(s.tester, c.SetReadDeadline(time.Now().Add(time.Hour)))
+		assert.NoError(s.tester, c.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+		return
+	}
+	assert.NoError(s.tester, c.SetWriteBuffer(4096))
+	// The peer never reads, so the outbound buffer can't be drained in time.
+	data := make([]byte, 16*1024*1024)
+	_, err := c.Write(data)
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, c.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	return
+}
+
+func (s *testDeadlineServer) OnDeadline(c Conn, err error) (action Action) {
+	assert.GreaterOrEqual(s.tester, time.Since(s.opened), 50*time.Millisecond)
+	assert.ErrorIs(s.tester, err, os.ErrDeadlineExceeded)
+	assert.ErrorIs(s.tester, err, errorx.ErrWriteDeadlineExceeded)
+	assert.Positive(s.tester, c.OutboundBuffered())
+	if atomic.AddInt32(&s.deadlines, 1) == 1 {
+		// Keep the connection open and give it another chance.
+		assert.NoError(s.tester, c.SetWriteDeadline(time.Now().Add(10*time.Millisecond)))
+		return None
+	}
+	return Close
+}
+
+func (s *testDeadlineServer) OnClose(_ Conn, err error) (action Action) {
+	if s.write {
+		assert.NoError(s.tester, err)
+		assert.EqualValues(s.tester, 2, atomic.LoadInt32(&s.deadlines))
+	} else {
+		assert.GreaterOrEqual(s.tester, time.Since(s.opened), 50*time.Millisecond)
+		assert.ErrorIs(s.tester, err, os.ErrDeadlineExceeded)
+		assert.ErrorIs(s.tester, err, errorx.ErrReadDeadlineExceeded)
+	}
+	close(s.done)
+	return Shutdown
+}
+
+type testReadDeadlineServer struct {
+	*testDeadlineServer
+}
+
+// OnDeadline shadows the DeadlineHandler of the embedded server,
+// so that testReadDeadlineServer doesn't implement DeadlineHandler.
+func (s *testReadDeadlineServer) OnDeadline() {}
+
+func testDeadline(t *testing.T, network, addr string, write bool) {
+	events := &testDeadlineServer{tester: t, network: network, addr: addr, write: write, done: make(chan struct{})}
+	var handler EventHandler = events
+	if !write {
+		handler = &testReadDeadlineServer{events}
+		_, ok := handler.(DeadlineHandler)
+		require.False(t, ok)
+	}
+	err := Run(handler, network+"://"+addr, WithReuseAddr(true))
+	assert.NoError(t, err)
+	select {
+	case <-events.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for test completion")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testIdleTimeout(t, "tcp", ":9903", false)
+	})
+	t.Run("tcp-et", func(t *testing.T) {
+		testIdleTimeout(t, "tcp", ":9904", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testIdleTimeout(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testIdleTimeoutServer struct {
+	BuiltinEventEngine
+	tester        *testing.T
+	network, addr string
+
+	opened  time.Time
+	traffic int32
+	done    chan struct{}
+}
+
+func (s *testIdleTimeoutServer) OnBoot(Engine) (action Action) {
+	err := goPool.DefaultWorkerPool.Submit(func() {
+		c, err := net.Dial(s.network, s.addr)
+		assert.NoError(s.tester, err)
+		defer c.Close() //nolint:errcheck
+
+		// Keep the connection active for a while, then fall silent.
+		for i := 0; i < 5; i++ {
+			_, err = c.Write([]byte("ping"))
+			assert.NoError(s.tester, err)
+			time.Sleep(30 * time.Millisecond)
+		}
+		<-s.done
+	})
+	assert.NoError(s.tester, err)
+	return None
+}
+
+func (s *testIdleTimeoutServer) OnOpen(Conn) (out []byte, action Action) {
+	s.opened = time.Now()
+	return
+}
+
+func (s *testIdleTimeoutServer) OnTraffic(c Conn) (action Action) {
+	_, _ = c.Discard(-1)
+	atomic.AddInt32(&s.traffic, 1)
+	return
+}
+
+func (s *testIdleTimeoutServer) OnClose(_ Conn, err error) (action Action) {
+	assert.ErrorIs(s.tester, err, errorx.ErrIdleTimeout)
+	assert.GreaterOrEqual(s.tester, time.Since(s.opened), 200*time.Millisecond)
+	assert.Positive(s.tester, atomic.LoadInt32(&s.traffic))
+	close(s.done)
+	return Shutdown
+}
+
+func testIdleTimeout(t *testing.T, network, addr string, et bool) {
+	events := &testIdleTimeoutServer{tester: t, network: network, addr: addr, done: make(chan struct{})}
+	err := Run(events, network+"://"+addr,
+		WithIdleTimeout(100*time.Millisecond),
+		WithEdgeTriggeredIO(et),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	select {
+	case <-events.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for test completion")
+	}
+}
+
+func TestFrameCodec(t *testing.T) {
+	t.Run("fixed-length", func(t *testing.T) {
+		testFrameCodec(t, "tcp", ":9907", NewFixedLengthFrameCodec(8), func(i int) []byte {
+			return []byte(strings.Repeat(string(rune('a'+i%26)), 8))
+		})
+	})
+	t.Run("delimiter-based", func(t *testing.T) {
+		testFrameCodec(t, "unix", testUnixAddr(t), NewDelimiterBasedFrameCodec('\n', 64), func(i int) []byte {
+			return []byte(strings.Repeat("x", i%50))
+		})
+	})
+	t.Run("length-field-2-bytes", func(t *testing.T) {
+		codec := NewLengthFieldBasedFrameCodec(
+			EncoderConfig{LengthFieldLength: 2},
+			DecoderConfig{LengthFieldLength: 2, InitialBytesToStrip: 2})
+		testFrameCodec(t, "tcp", ":9908", codec, func(i int) []byte {
+			return make([]byte, i*37%1000)
+		})
+	})
+	t.Run("length-field-3-bytes", func(t *testing.T) {
+		codec := NewLengthFieldBasedFrameCodec(
+			EncoderConfig{ByteOrder: binary.LittleEndian, LengthFieldLength: 3, LengthIncludesLengthFieldLength: true},
+			DecoderConfig{ByteOrder: binary.LittleEndian, LengthFieldLength: 3, LengthAdjustment: -3, InitialBytesToStrip: 3})
+		testFrameCodec(t, "tcp", ":9909", codec, func(i int) []byte {
+			return make([]byte, i*131%70000)
+		})
+	})
+	t.Run("length-field-offset", func(t *testing.T) {
+		// Frames consist of a 1-byte type, a 4-byte length and the payload,
+		// they are passed to OnMessage as they are.
+		codec := &rawEncodeCodec{NewLengthFieldBasedFrameCodec(
+			EncoderConfig{},
+			DecoderConfig{LengthFieldOffset: 1, LengthFieldLength: 4})}
+		testFrameCodec(t, "tcp", ":9910", codec, func(i int) []byte {
+			frame := make([]byte, 5+i%300)
+			frame[0] = byte(i)
+			binary.BigEndian.PutUint32(frame[1:], uint32(len(frame)-5))
+			return frame
+		})
+	})
+	t.Run("encode-errors", func(t *testing.T) {
+		_, err := NewFixedLengthFrameCodec(8).Encode(nil, make([]byte, 7))
+		assert.ErrorIs(t, err, errorx.ErrInvalidFixedLength)
+		_, err = NewLengthFieldBasedFrameCodec(EncoderConfig{LengthFieldLength: 5}, DecoderConfig{}).Encode(nil, nil)
+		assert.ErrorIs(t, err, errorx.ErrUnsupportedLength)
+		_, err = NewLengthFieldBasedFrameCodec(EncoderConfig{LengthFieldLength: 1}, DecoderConfig{}).Encode(nil, make([]byte, 256))
+		assert.ErrorIs(t, err, errorx.ErrTooLargeLength)
+		_, err = NewLengthFieldBasedFrameCodec(EncoderConfig{LengthFieldLength: 2, LengthAdjustment: -2}, DecoderConfig{}).Encode(nil, nil)
+		assert.ErrorIs(t, err, errorx.ErrTooLessLength)
+	})
+	t.Run("length-field-3-bytes-custom-order", func(t *testing.T) {
+		order := littleEndianOrder{binary.LittleEndian}
+		frame, err := NewLengthFieldBasedFrameCodec(EncoderConfig{ByteOrder: order, LengthFieldLength: 3},
+			DecoderConfig{}).Encode(nil, make([]byte, 0x010203))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x03, 0x02, 0x01}, frame[:3])
+		codec := NewLengthFieldBasedFrameCodec(
+			EncoderConfig{ByteOrder: order, LengthFieldLength: 3},
+			DecoderConfig{ByteOrder: order, LengthFieldLength: 3, InitialBytesToStrip: 3})
+		testFrameCodec(t, "tcp", ":9984", codec, func(i int) []byte {
+			return make([]byte, i*131%70000)
+		})
+	})
+	t.Run("decoder-config-errors", func(t *testing.T) {
+		_, err := NewLengthFieldBasedFrameCodec(EncoderConfig{}, DecoderConfig{LengthFieldLength: 5}).Decode(nil)
+		assert.ErrorIs(t, err, errorx.ErrUnsupportedLength)
+		_, err = NewLengthFieldBasedFrameCodec(EncoderConfig{},
+			DecoderConfig{LengthFieldLength: 2, LengthFieldOffset: -1}).Decode(nil)
+		assert.ErrorIs(t, err, errorx.ErrNegativeOffset)
+		_, err = NewLengthFieldBasedFrameCodec(EncoderConfig{},
+			DecoderConfig{LengthFieldLength: 2, InitialBytesToStrip: -1}).Decode(nil)
+		assert.ErrorIs(t, err, errorx.ErrNegativeOffset)
+	})
+	t.Run("max-frame-length", func(t *testing.T) {
+		testMaxFrameLength(t, "tcp", ":9963", lengthFieldMaxFrameCodec, []byte{0x03, 0xe8, 'x'})
+	})
+	t.Run("max-frame-length-udp-session", func(t *testing.T) {
+		testMaxFrameLength(t, "udp", ":9964", lengthFieldMaxFrameCodec, []byte{0x03, 0xe8, 'x'},
+			WithUDPSessionTimeout(time.Minute))
+	})
+	t.Run("max-frame-length-delimiter-based", func(t *testing.T) {
+		// The delimiter doesn't show up in the first 16 bytes.
+		testMaxFrameLength(t, "tcp", ":9977", NewDelimiterBasedFrameCodec('\n', 16),
+			[]byte(strings.Repeat("x", 17)))
+	})
+	t.Run("decode-no-progress", func(t *testing.T) {
+		testDecodeFailure(t, "tcp", ":9981", peekCodec{}, []byte("ping"), errorx.ErrDecodeNoProgress)
+	})
+}
+
+// peekCodec decodes the inbound data without consuming it.
+type peekCodec struct{}
+
+func (peekCodec) Decode(c Conn) ([]byte, error) {
+	return c.Peek(-1)
+}
+
+func (peekCodec) Encode(_ Conn, msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+// lengthFieldMaxFrameCodec caps the frames at 512 bytes, the length field of the data sent
+// to it claims a frame of 1000 bytes, which is never sent.
+var lengthFieldMaxFrameCodec = NewLengthFieldBasedFrameCodec(
+	EncoderConfig{LengthFieldLength: 2},
+	DecoderConfig{LengthFieldLength: 2, InitialBytesToStrip: 2, MaxFrameLength: 512})
+
+type testDecodeFailureServer struct {
+	BuiltinEventEngine
+	tester        *testing.T
+	network, addr string
+	data          []byte
+	err           error
+}
+
+func (s *testDecodeFailureServer) OnBoot(Engine) (action Action) {
+	err := goPool.DefaultWorkerPool.Submit(func() {
+		c, err := net.Dial(s.network, s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		defer c.Close() //nolint:errcheck
+		_, err = c.Write(s.data)
+		assert.NoError(s.tester, err)
+	})
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testDecodeFailureServer) OnMessage(Conn, []byte) (out []byte, action Action) {
+	s.tester.Error("OnMessage must not be fired with a message that fails to be decoded")
+	return
+}
+
+func (s *testDecodeFailureServer) OnClose(_ Conn, err error) (action Action) {
+	s.err = err
+	return Shutdown
+}
+
+func testMaxFrameLength(t *testing.T, network, addr string, codec Codec, data []byte, opts ...Option) {
+	testDecodeFailure(t, network, addr, codec, data, errorx.ErrTooLongFrame, opts...)
+}
+
+func testDecodeFailure(t *testing.T, network, addr string, codec Codec, data []byte, want error, opts ...Option) {
+	events := &testDecodeFailureServer{tester: t, network: network, addr: addr, data: data}
+	err := Run(events, network+"://"+addr, append(opts, WithCodec(codec), WithReuseAddr(true))...)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, events.err, want)
+}
+
+// littleEndianOrder is a byte order other than binary.LittleEndian that lays out bytes in the same way.
+type littleEndianOrder struct {
+	binary.ByteOrder
+}
+
+type rawEncodeCodec struct {
+	*LengthFieldBasedFrameCodec
+}
+
+func (*rawEncodeCodec) Encode(_ Conn, msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+type testFrameCodecServer struct {
+	BuiltinEventEngine
+	tester        *testing.T
+	network, addr string
+	codec         Codec
+	msgs          [][]byte
+
+	received int
+}
+
+func (s *testFrameCodecServer) OnBoot(Engine) (action Action) {
+	err := goPool.DefaultWorkerPool.Submit(func() {
+		c, err := net.Dial(s.network, s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		defer c.Close() //nolint:errcheck
+
+		var stream []byte
+		for _, msg := range s.msgs {
+			frame, err := s.codec.Encode(nil, msg)
+			assert.NoError(s.tester, err)
+			stream = append(stream, frame...)
+		}
+		// Send the frames in chunks of random sizes to split them arbitrarily.
+		go func() {
+			for data := stream; len(data) > 0; {
+				n := rand.Intn(1024) + 1
+				if n > len(data) {
+					n = len(data)
+				}
+				_, err := c.Write(data[:n])
+				assert.NoError(s.tester, err)
+				data = data[n:]
+			}
+		}()
+		echo := make([]byte, len(stream))
+		_, err = io.ReadFull(c, echo)
+		assert.NoError(s.tester, err)
+		assert.True(s.tester, bytes.Equal(stream, echo), "echoed frames mismatch")
+	})
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testFrameCodecServer) OnTraffic(Conn) (action Action) {
+	s.tester.Error("OnTraffic must not be fired with a codec")
+	return Close
+}
+
+func (s *testFrameCodecServer) OnMessage(_ Conn, msg []byte) (out []byte, action Action) {
+	assert.Equal(s.tester, s.msgs[s.received], msg)
+	s.received++
+	return msg, None
+}
+
+func (s *testFrameCodecServer) OnClose(Conn, error) (action Action) {
+	return Shutdown
+}
+
+func testFrameCodec(t *testing.T, network, addr string, codec Codec, newMsg func(i int) []byte) {
+	msgs := make([][]byte, 1000)
+	for i := range msgs {
+		msgs[i] = newMsg(i)
+	}
+	events := &testFrameCodecServer{tester: t, network: network, addr: addr, codec: codec, msgs: msgs}
+	err := Run(events, network+"://"+addr, WithCodec(codec), WithReuseAddr(true))
+	assert.NoError(t, err)
+	assert.Equal(t, len(msgs), events.received)
+}
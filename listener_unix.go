@@ -20,6 +20,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -36,22 +37,54 @@ type listener struct {
 	fd                  int
 	addr                net.Addr
 	address, network    string
+	protoAddr           string       // network address in the form passed by the user
+	opts                *Options     // options of the listener and its connections
+	handler             EventHandler // event handler of the connections, nil for the engine's
 	sockOptInts         []socket.Option[int]
 	sockOptStrs         []socket.Option[string]
 	pollAttachment      *netpoll.PollAttachment // listener attachment for poller
+	file                *os.File                // inherited listening socket that is adopted instead of opening a new one
+	handedOff           bool                    // whether the socket has been handed off to another process
 }
 
 func (ln *listener) packPollAttachment(handler netpoll.PollEventHandler) *netpoll.PollAttachment {
 	ln.pollAttachment = &netpoll.PollAttachment{FD: ln.fd, Callback: handler}
+	if !ln.isDatagram() {
+		ln.pollAttachment.Submission = netpoll.SubmitAccept
+	}
 	return ln.pollAttachment
 }
 
+// clone opens another listener on the same address for the event-loops under ReusePort.
+func (ln *listener) clone() (*listener, error) {
+	l, err := initListener(ln.network, ln.address, ln.opts, nil)
+	if err != nil {
+		return l, err
+	}
+	l.protoAddr, l.handler = ln.protoAddr, ln.handler
+	return l, nil
+}
+
+// is reports whether the listener is bound to the given address.
+func (ln *listener) is(network, address string) bool {
+	return strings.HasPrefix(network, ln.network) && ln.address == address
+}
+
+// isDatagram reports whether the listener is a datagram socket, which is read from instead of accepting.
+func (ln *listener) isDatagram() bool {
+	return ln.network == "udp" || ln.network == "unixgram"
+}
+
 func (ln *listener) dup() (int, error) {
 	return socket.Dup(ln.fd)
 }
 
 func (ln *listener) open() (err error) {
 	ln.openOnce.Do(func() {
+		if ln.file != nil {
+			err = ln.adopt()
+			return
+		}
 		switch ln.network {
 		case "tcp", "tcp4", "tcp6":
 			ln.fd, ln.addr, err = socket.TCPSocket(ln.network, ln.address, true, ln.sockOptInts, ln.sockOptStrs)
@@ -59,7 +92,7 @@ func (ln *listener) open() (err error) {
 		case "udp", "udp4", "udp6":
 			ln.fd, ln.addr, err = socket.UDPSocket(ln.network, ln.address, false, ln.sockOptInts, ln.sockOptStrs)
 			ln.network = "udp"
-		case "unix":
+		case "unix", "unixgram", "unixpacket":
 			_ = os.RemoveAll(ln.address)
 			ln.fd, ln.addr, err = socket.UnixSocket(ln.network, ln.address, true, ln.sockOptInts, ln.sockOptStrs)
 		default:
@@ -75,19 +108,155 @@ func (ln *listener) close() {
 			logging.Error(os.NewSyscallError("close", unix.Close(ln.fd)))
 		}
 		ln.fd = -1
-		if ln.network == "unix" {
+		// Leave the socket file to the process that has created
+		// or taken over the listener.
+		if strings.HasPrefix(ln.network, "unix") && ln.file == nil && !ln.handedOff {
 			logging.Error(os.RemoveAll(ln.address))
 		}
 	})
 }
 
-func initListener(network, addr string, options *Options) (ln *listener, err error) {
+// adopt takes over the inherited listening socket instead of opening a new one,
+// socket options other than TCP keepalive are left as they are.
+func (ln *listener) adopt() error {
+	fd, err := dupFile(ln.file)
+	if err != nil {
+		return err
+	}
+	if err = unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return os.NewSyscallError("setnonblock", err)
+	}
+	network, addr, err := listenerAddr(fd)
+	if err != nil {
+		_ = unix.Close(fd)
+		return err
+	}
+	ln.fd, ln.network, ln.addr = fd, network, addr
+	return nil
+}
+
+// controlFile invokes fn with the file descriptor of f, which must not be used after fn returns.
+// Unlike f.Fd, it doesn't put the file descriptor into blocking mode.
+func controlFile(f *os.File, fn func(int)) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return rc.Control(func(s uintptr) { fn(int(s)) })
+}
+
+// dupFile duplicates the file descriptor of f.
+func dupFile(f *os.File) (fd int, err error) {
+	if e := controlFile(f, func(s int) { fd, err = socket.Dup(s) }); e != nil {
+		return -1, e
+	}
+	return
+}
+
+// fileListenerAddr returns the network and the local address of the socket of f.
+func fileListenerAddr(f *os.File) (network string, addr net.Addr, err error) {
+	if e := controlFile(f, func(s int) { network, addr, err = listenerAddr(s) }); e != nil {
+		return "", nil, e
+	}
+	return
+}
+
+// listenerAddr returns the network and the local address of the given socket.
+func listenerAddr(fd int) (string, net.Addr, error) {
+	sotype, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TYPE)
+	if err != nil {
+		return "", nil, os.NewSyscallError("getsockopt", err)
+	}
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		return "", nil, os.NewSyscallError("getsockname", err)
+	}
+	switch sa.(type) {
+	case *unix.SockaddrInet4, *unix.SockaddrInet6:
+		switch sotype {
+		case unix.SOCK_STREAM:
+			return "tcp", socket.SockaddrToTCPOrUnixAddr(sa), nil
+		case unix.SOCK_DGRAM:
+			return "udp", socket.SockaddrToUDPAddr(sa), nil
+		}
+	case *unix.SockaddrUnix:
+		switch sotype {
+		case unix.SOCK_STREAM:
+			return "unix", socket.SockaddrToUnixAddr(sa, "unix"), nil
+		case unix.SOCK_DGRAM:
+			return "unixgram", socket.SockaddrToUnixAddr(sa, "unixgram"), nil
+		case unix.SOCK_SEQPACKET:
+			return "unixpacket", socket.SockaddrToUnixAddr(sa, "unixpacket"), nil
+		}
+	}
+	return "", nil, errorx.ErrUnsupportedProtocol
+}
+
+// takeInheritedListener picks the first socket in files that is bound to addr on network
+// and hasn't been adopted yet, and then marks it as adopted.
+func takeInheritedListener(network, addr string, files []*os.File, adopted map[*os.File]struct{}) *os.File {
+	for _, f := range files {
+		if _, ok := adopted[f]; ok {
+			continue
+		}
+		n, a, err := fileListenerAddr(f)
+		if err != nil || !matchListenerAddr(network, addr, n, a) {
+			continue
+		}
+		adopted[f] = struct{}{}
+		return f
+	}
+	return nil
+}
+
+func matchListenerAddr(network, addr, inheritedNetwork string, inheritedAddr net.Addr) bool {
+	if !strings.HasPrefix(network, inheritedNetwork) {
+		return false
+	}
+	sameIP := func(x, y net.IP) bool {
+		return x.Equal(y) || (len(x) == 0 || x.IsUnspecified()) && (len(y) == 0 || y.IsUnspecified())
+	}
+	switch a := inheritedAddr.(type) {
+	case *net.TCPAddr:
+		tcpAddr, err := net.ResolveTCPAddr(network, addr)
+		return err == nil && tcpAddr.Port == a.Port && sameIP(tcpAddr.IP, a.IP)
+	case *net.UDPAddr:
+		udpAddr, err := net.ResolveUDPAddr(network, addr)
+		return err == nil && udpAddr.Port == a.Port && sameIP(udpAddr.IP, a.IP)
+	case *net.UnixAddr:
+		return addr == a.Name
+	}
+	return false
+}
+
+// minUDPGROBufferCap is the minimum ReadBufferCap with UDPGRO, the datagrams coalesced
+// by UDP_GRO are up to 64KB, which would otherwise be truncated silently.
+const minUDPGROBufferCap = 64 << 10
+
+// checkListenerOptions reports the options that a listener on the given network can't be
+// opened with, it's checked ahead of initListener.
+func checkListenerOptions(network string, options *Options) error {
+	// Trusting every source in the optional mode would let any client spoof its address.
+	if options.ProxyProtocol == ProxyProtocolOptional && len(options.ProxyProtocolTrustedCIDRs) == 0 &&
+		strings.HasPrefix(network, "tcp") {
+		return errorx.ErrNoTrustedProxies
+	}
+	if options.UDPGRO && strings.HasPrefix(network, "udp") && options.ReadBufferCap < minUDPGROBufferCap {
+		return errorx.ErrTooSmallReadBuffer
+	}
+	return nil
+}
+
+// initListener opens a listener on the given address, or adopts one of the InheritedListeners
+// that is bound to it and not in adopted yet, adopted is nil if none of them can be adopted.
+func initListener(network, addr string, options *Options, adopted map[*os.File]struct{}) (ln *listener, err error) {
 	var (
 		sockOptInts []socket.Option[int]
 		sockOptStrs []socket.Option[string]
 	)
 
-	if options.ReusePort && network != "unix" {
+	if options.ReusePort && !strings.HasPrefix(network, "unix") {
 		sockOpt := socket.Option[int]{SetSockOpt: socket.SetReuseport, Opt: 1}
 		sockOptInts = append(sockOptInts, sockOpt)
 	}
@@ -116,12 +285,23 @@ func initListener(network, addr string, options *Options) (ln *listener, err err
 			}
 		}
 	}
+	if options.UDPGRO && strings.HasPrefix(network, "udp") {
+		sockOpt := socket.Option[int]{SetSockOpt: socket.SetUDPGRO, Opt: 1}
+		sockOptInts = append(sockOptInts, sockOpt)
+	}
+	if options.UDPPacketInfo && strings.HasPrefix(network, "udp") {
+		sockOpt := socket.Option[int]{SetSockOpt: socket.SetUDPPacketInfo, Opt: 1}
+		sockOptInts = append(sockOptInts, sockOpt)
+	}
 	if options.BindToDevice != "" {
 		sockOpt := socket.Option[string]{SetSockOpt: socket.SetBindToDevice, Opt: options.BindToDevice}
 		sockOptStrs = append(sockOptStrs, sockOpt)
 	}
 
-	ln = &listener{network: network, address: addr, sockOptInts: sockOptInts, sockOptStrs: sockOptStrs}
+	ln = &listener{network: network, address: addr, opts: options, sockOptInts: sockOptInts, sockOptStrs: sockOptStrs}
+	if adopted != nil {
+		ln.file = takeInheritedListener(network, addr, options.InheritedListeners, adopted)
+	}
 	err = ln.open()
 
 	if options.TCPKeepAlive > 0 && ln.network == "tcp" &&
@@ -140,3 +320,53 @@ func initListener(network, addr string, options *Options) (ln *listener, err err
 
 	return
 }
+
+const (
+	envListenFDs = "LISTEN_FDS"
+	envListenPID = "LISTEN_PID"
+
+	listenFDsStart = 3 // SD_LISTEN_FDS_START
+)
+
+func inheritedListeners() ([]*os.File, error) {
+	nfds := os.Getenv(envListenFDs)
+	if nfds == "" {
+		return nil, nil
+	}
+	defer func() {
+		_ = os.Unsetenv(envListenFDs)
+		_ = os.Unsetenv(envListenPID)
+	}()
+	if pid := os.Getenv(envListenPID); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil // the sockets are meant for another process
+	}
+	n, err := strconv.Atoi(nfds)
+	if err != nil || n < 0 {
+		return nil, errorx.ErrInvalidListenFDs
+	}
+
+	files := make([]*os.File, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		unix.CloseOnExec(fd)
+		files = append(files, os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)))
+	}
+	return files, nil
+}
+
+func listenerProtoAddrs(files []*os.File) ([]string, error) {
+	addrs := make([]string, 0, len(files))
+	seen := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		network, addr, err := fileListenerAddr(f)
+		if err != nil {
+			return nil, err
+		}
+		protoAddr := network + "://" + addr.String()
+		if _, ok := seen[protoAddr]; ok {
+			continue // multiple sockets bound to the same address with SO_REUSEPORT
+		}
+		seen[protoAddr] = struct{}{}
+		addrs = append(addrs, protoAddr)
+	}
+	return addrs, nil
+}
@@ -32,6 +32,9 @@ type listener struct {
 	openOnce, closeOnce sync.Once
 	network             string
 	address             string
+	protoAddr           string       // network address in the form passed by the user
+	opts                *Options     // options of the listener and its connections
+	handler             EventHandler // event handler of the connections, nil for the engine's
 	lc                  *net.ListenConfig
 	ln                  net.Listener
 	pc                  net.PacketConn
@@ -116,7 +119,11 @@ func (l *listener) close() {
 	})
 }
 
-func initListener(network, addr string, options *Options) (*listener, error) {
+func checkListenerOptions(string, *Options) error {
+	return nil
+}
+
+func initListener(network, addr string, options *Options, _ map[*os.File]struct{}) (*listener, error) {
 	lc := net.ListenConfig{
 		Control: func(network, address string, c syscall.RawConn) error {
 			return c.Control(func(fd uintptr) {
@@ -137,7 +144,15 @@ func initListener(network, addr string, options *Options) (*listener, error) {
 		KeepAlive: options.TCPKeepAlive,
 	}
 
-	l := listener{network: network, address: addr, lc: &lc}
+	l := listener{network: network, address: addr, opts: options, lc: &lc}
 
 	return &l, l.open()
 }
+
+func inheritedListeners() ([]*os.File, error) {
+	return nil, errorx.ErrUnsupportedOp
+}
+
+func listenerProtoAddrs(_ []*os.File) ([]string, error) {
+	return nil, errorx.ErrUnsupportedOp
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMetricsInterval = 10 * time.Second
+
+// trafficLatencyBounds are the upper bounds of the buckets of OnTraffic latency histograms.
+var trafficLatencyBounds = [...]time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// MetricsSink receives the metrics collected by the engine, it's set up with WithMetricsSink,
+// and the engine pushes the snapshot of metrics to it every MetricsInterval.
+// Collect is invoked on a goroutine other than the event-loops, it must not block for long.
+type MetricsSink interface {
+	// Collect is fired with the snapshot of metrics of all event-loops, ordered by their indexes.
+	Collect(metrics []EventLoopMetrics)
+}
+
+// LatencyHistogram is the snapshot of a histogram of latencies.
+type LatencyHistogram struct {
+	// Bounds are the upper bounds of the buckets in ascending order,
+	// the implicit last bucket with no upper bound is not included.
+	Bounds []time.Duration
+	// Counts are the cumulative counts of the observations that are less than
+	// or equal to each bound, Counts[i] is the count of Bounds[i].
+	Counts []uint64
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of all observations.
+	Sum time.Duration
+}
+
+// EventLoopMetrics is the snapshot of metrics of an event-loop.
+type EventLoopMetrics struct {
+	// Index is the index of the event-loop.
+	Index int
+	// Accepted is the number of connections served by the event-loop, which includes the ones
+	// accepted by listeners, enrolled by Client, registered by EventLoop.Register and the
+	// UDP sessions, each of them is counted in Closed once it's closed.
+	Accepted uint64
+	// Closed is the number of connections closed by the event-loop.
+	Closed uint64
+	// Active is the number of connections currently served by the event-loop.
+	Active int
+	// BytesRead is the number of bytes read from sockets, including the ciphertext of TLS.
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to sockets, including the ciphertext of TLS.
+	BytesWritten uint64
+	// ReadEAGAIN is the number of reads that failed with EAGAIN.
+	ReadEAGAIN uint64
+	// WriteEAGAIN is the number of writes that failed with EAGAIN.
+	WriteEAGAIN uint64
+	// OutboundHighWaterMark is the largest number of bytes that have ever been
+	// pending in the outbound buffer of a single connection.
+	OutboundHighWaterMark int
+	// UrgentTaskQueueDepth is the number of pending tasks of high priority.
+	UrgentTaskQueueDepth int
+	// TaskQueueDepth is the number of pending tasks of low priority.
+	TaskQueueDepth int
+	// TrafficLatency is the histogram of the time spent in OnTraffic or OnMessage.
+	TrafficLatency LatencyHistogram
+}
+
+// eventloopMetrics holds the metrics of an event-loop, it's written by the event-loop
+// and read by others. All methods are no-ops on a nil *eventloopMetrics, which means
+// that metrics are disabled.
+type eventloopMetrics struct {
+	accepted      atomic.Uint64
+	closed        atomic.Uint64
+	bytesRead     atomic.Uint64
+	bytesWritten  atomic.Uint64
+	readEAGAIN    atomic.Uint64
+	writeEAGAIN   atomic.Uint64
+	highWaterMark atomic.Int64
+	latencySum    atomic.Int64
+	latency       [len(trafficLatencyBounds) + 1]atomic.Uint64 // non-cumulative counts of buckets
+}
+
+func (m *eventloopMetrics) addAccepted() {
+	if m != nil {
+		m.accepted.Add(1)
+	}
+}
+
+func (m *eventloopMetrics) addClosed() {
+	if m != nil {
+		m.closed.Add(1)
+	}
+}
+
+func (m *eventloopMetrics) addRead(n int) {
+	if m != nil && n > 0 {
+		m.bytesRead.Add(uint64(n))
+	}
+}
+
+func (m *eventloopMetrics) addWritten(n int) {
+	if m != nil && n > 0 {
+		m.bytesWritten.Add(uint64(n))
+	}
+}
+
+func (m *eventloopMetrics) addReadEAGAIN() {
+	if m != nil {
+		m.readEAGAIN.Add(1)
+	}
+}
+
+func (m *eventloopMetrics) addWriteEAGAIN() {
+	if m != nil {
+		m.writeEAGAIN.Add(1)
+	}
+}
+
+// observeOutbound raises the high-water mark of outbound buffers to n if n exceeds it.
+func (m *eventloopMetrics) observeOutbound(n int) {
+	if m == nil {
+		return
+	}
+	for {
+		hwm := m.highWaterMark.Load()
+		if int64(n) <= hwm || m.highWaterMark.CompareAndSwap(hwm, int64(n)) {
+			return
+		}
+	}
+}
+
+// observeTraffic records the time spent in OnTraffic since start, unlike the others,
+// it must be called on a non-nil *eventloopMetrics.
+func (m *eventloopMetrics) observeTraffic(start time.Time) {
+	d := time.Since(start)
+	i := 0
+	for i < len(trafficLatencyBounds) && d > trafficLatencyBounds[i] {
+		i++
+	}
+	m.latency[i].Add(1)
+	m.latencySum.Add(int64(d))
+}
+
+func (m *eventloopMetrics) snapshot() (s EventLoopMetrics) {
+	s.Accepted = m.accepted.Load()
+	s.Closed = m.closed.Load()
+	s.BytesRead = m.bytesRead.Load()
+	s.BytesWritten = m.bytesWritten.Load()
+	s.ReadEAGAIN = m.readEAGAIN.Load()
+	s.WriteEAGAIN = m.writeEAGAIN.Load()
+	s.OutboundHighWaterMark = int(m.highWaterMark.Load())
+
+	h := &s.TrafficLatency
+	h.Bounds = append([]time.Duration(nil), trafficLatencyBounds[:]...)
+	h.Counts = make([]uint64, len(trafficLatencyBounds))
+	for i := range m.latency {
+		h.Count += m.latency[i].Load()
+		if i < len(h.Counts) {
+			h.Counts[i] = h.Count
+		}
+	}
+	h.Sum = time.Duration(m.latencySum.Load())
+	return
+}
+
+// WriteMetrics writes metrics to w in the Prometheus text exposition format,
+// each sample is labeled with the index of its event-loop.
+func WriteMetrics(w io.Writer, metrics []EventLoopMetrics) error {
+	var buf bytes.Buffer
+	family := func(name, typ, help string, sample func(m *EventLoopMetrics, loop string)) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for i := range metrics {
+			sample(&metrics[i], strconv.Itoa(metrics[i].Index))
+		}
+	}
+	counter := func(name, help string, value func(m *EventLoopMetrics) uint64) {
+		family(name, "counter", help, func(m *EventLoopMetrics, loop string) {
+			fmt.Fprintf(&buf, "%s{loop=%q} %d\n", name, loop, value(m))
+		})
+	}
+	gauge := func(name, help string, value func(m *EventLoopMetrics) int) {
+		family(name, "gauge", help, func(m *EventLoopMetrics, loop string) {
+			fmt.Fprintf(&buf, "%s{loop=%q} %d\n", name, loop, value(m))
+		})
+	}
+
+	counter("gnet_connections_accepted_total", "Number of connections accepted.",
+		func(m *EventLoopMetrics) uint64 { return m.Accepted })
+	counter("gnet_connections_closed_total", "Number of connections closed.",
+		func(m *EventLoopMetrics) uint64 { return m.Closed })
+	gauge("gnet_connections_active", "Number of active connections.",
+		func(m *EventLoopMetrics) int { return m.Active })
+	counter("gnet_read_bytes_total", "Number of bytes read from sockets.",
+		func(m *EventLoopMetrics) uint64 { return m.BytesRead })
+	counter("gnet_written_bytes_total", "Number of bytes written to sockets.",
+		func(m *EventLoopMetrics) uint64 { return m.BytesWritten })
+	family("gnet_eagain_total", "counter", "Number of reads and writes that failed with EAGAIN.",
+		func(m *EventLoopMetrics, loop string) {
+			fmt.Fprintf(&buf, "gnet_eagain_total{loop=%q,op=\"read\"} %d\n", loop, m.ReadEAGAIN)
+			fmt.Fprintf(&buf, "gnet_eagain_total{loop=%q,op=\"write\"} %d\n", loop, m.WriteEAGAIN)
+		})
+	gauge("gnet_outbound_buffer_high_water_mark_bytes", "Largest number of bytes pending in an outbound buffer.",
+		func(m *EventLoopMetrics) int { return m.OutboundHighWaterMark })
+	family("gnet_task_queue_depth", "gauge", "Number of pending tasks of event-loops.",
+		func(m *EventLoopMetrics, loop string) {
+			fmt.Fprintf(&buf, "gnet_task_queue_depth{loop=%q,queue=\"urgent\"} %d\n", loop, m.UrgentTaskQueueDepth)
+			fmt.Fprintf(&buf, "gnet_task_queue_depth{loop=%q,queue=\"normal\"} %d\n", loop, m.TaskQueueDepth)
+		})
+	family("gnet_traffic_duration_seconds", "histogram", "Time spent in OnTraffic or OnMessage.",
+		func(m *EventLoopMetrics, loop string) {
+			h := &m.TrafficLatency
+			for i, bound := range h.Bounds {
+				fmt.Fprintf(&buf, "gnet_traffic_duration_seconds_bucket{loop=%q,le=\"%s\"} %d\n",
+					loop, strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), h.Counts[i])
+			}
+			fmt.Fprintf(&buf, "gnet_traffic_duration_seconds_bucket{loop=%q,le=\"+Inf\"} %d\n", loop, h.Count)
+			fmt.Fprintf(&buf, "gnet_traffic_duration_seconds_sum{loop=%q} %s\n",
+				loop, strconv.FormatFloat(h.Sum.Seconds(), 'g', -1, 64))
+			fmt.Fprintf(&buf, "gnet_traffic_duration_seconds_count{loop=%q} %d\n", loop, h.Count)
+		})
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -15,6 +15,9 @@
 package gnet
 
 import (
+	"crypto/tls"
+	"net"
+	"os"
 	"time"
 
 	"github.com/panjf2000/gnet/v2/pkg/logging"
@@ -40,6 +43,32 @@ const (
 	TCPDelay
 )
 
+// ProxyProtocolMode is the mode of decoding the PROXY protocol header on accepted connections.
+type ProxyProtocolMode int
+
+// Available modes of the PROXY protocol.
+const (
+	// ProxyProtocolOff doesn't decode the PROXY protocol header.
+	ProxyProtocolOff ProxyProtocolMode = iota
+	// ProxyProtocolOptional decodes the PROXY protocol header if there is one.
+	ProxyProtocolOptional
+	// ProxyProtocolRequired closes the connections without a valid PROXY protocol header.
+	ProxyProtocolRequired
+)
+
+// RejectPolicy is the policy of handling the connections beyond MaxConnections.
+type RejectPolicy int
+
+// Available policies of rejecting connections.
+const (
+	// RejectClose accepts the connections beyond MaxConnections and closes them
+	// right away after writing RejectResponse.
+	RejectClose RejectPolicy = iota
+	// RejectPause stops accepting connections until the number of connections drops
+	// below MaxConnections, the new connections are left in the backlog of the listeners.
+	RejectPause
+)
+
 // Options are configurations for the gnet application.
 type Options struct {
 	// LB represents the load-balancing algorithm used when assigning new connections
@@ -91,6 +120,69 @@ type Options struct {
 	// or equal to its real amount.
 	WriteBufferCap int
 
+	// UDPBatchSize is the maximum number of datagrams read from a UDP socket when the readable
+	// event comes, the datagrams are read by a single recvmmsg(2) on Linux and handed over to
+	// OnTraffic one by one. Each event-loop allocates UDPBatchSize buffers of ReadBufferCap for
+	// it. The default is 0, which reads one datagram at a time.
+	// Note that this option is ignored on Windows, and recvmmsg(2) is only used on Linux,
+	// the datagrams are read one by one with recvfrom(2) on other UNIX-like platforms.
+	UDPBatchSize int
+
+	// UDPGRO enables UDP_GRO on UDP sockets, which has the kernel coalesce the datagrams
+	// of the same flow into one large buffer, the buffer is split into the original datagrams
+	// before they're handed over to OnTraffic. ReadBufferCap must be at least 64KB to hold
+	// the coalesced datagrams, errors.ErrTooSmallReadBuffer is returned otherwise.
+	// It is only available on Linux at the moment, an error will therefore be returned when
+	// setting this option on non-linux platforms.
+	UDPGRO bool
+
+	// UDPGSO enables UDP_SEGMENT for Conn.SendSegments, which hands a large buffer over to
+	// the kernel in one go and has the kernel or the NIC split it into datagrams, otherwise
+	// the buffer is split by gnet and sent with sendmmsg(2), which is also the fallback when
+	// the device fails UDP_SEGMENT with EIO for lack of checksum offloading.
+	// Note that this option is only available on Linux, it's ignored on other platforms.
+	UDPGSO bool
+
+	// UDPPacketInfo enables the ancillary data of the datagrams received on UDP sockets,
+	// which is reported by Conn.PacketInfo: the local address that a datagram was sent to
+	// along with the interface it came from, the TOS or traffic class, and the time when
+	// it was received by the kernel. It's useful for the UDP servers bound to a wildcard
+	// address, which can reply from the right address with Conn.SendMsg.
+	// It is only available on Linux at the moment, an error will therefore be returned when
+	// setting this option on non-linux platforms.
+	UDPPacketInfo bool
+
+	// OutboundHighWatermark is the number of bytes pending in the outbound buffer of a connection
+	// at which the connection is considered to be under backpressure, OnBackpressure of the
+	// BackpressureHandler fires with paused=true when it's reached. The default is 0, which
+	// means the outbound buffer is unlimited.
+	// Note that this option is only available for stream-oriented protocol on UNIX-like platforms.
+	OutboundHighWatermark int
+
+	// OutboundLowWatermark is the number of bytes pending in the outbound buffer of a connection
+	// under backpressure at which the backpressure is relieved, OnBackpressure fires with
+	// paused=false when the outbound buffer is drained down to it. The default is 0, it takes
+	// half of OutboundHighWatermark if it's not less than OutboundHighWatermark.
+	OutboundLowWatermark int
+
+	// PauseReadOnBackpressure indicates whether to stop reading from a connection while it's
+	// under backpressure, it takes effect only when OutboundHighWatermark is set.
+	PauseReadOnBackpressure bool
+
+	// ZeroCopyWriteThreshold enables MSG_ZEROCOPY for the data of at least this many bytes
+	// passed to Conn.AsyncWrite, which is sent without being copied into the kernel, and the
+	// callback of AsyncWrite is deferred until the kernel signals the completion of the
+	// transmission, the data must not be modified before then. The data is written as usual
+	// if the outbound buffer is not empty. The default is 0, which disables zero-copy writes.
+	// If the connection is closed before the kernel signals the completion, the callback is
+	// invoked with net.ErrClosed, which doesn't guarantee that the kernel has released the
+	// data: a graceful close keeps sending the queued data from the same memory, so the data
+	// must not be modified or reused even then.
+	// Zero-copy writes pay off only for large payloads, a threshold below 10KB is unlikely
+	// to be helpful.
+	// Note that this option is only available for TCP on Linux 4.14+, it's ignored otherwise.
+	ZeroCopyWriteThreshold int
+
 	// LockOSThread is used to determine whether each I/O event-loop should be associated to an OS thread,
 	// it is useful when you need some kind of mechanisms like thread local storage, or invoke certain C
 	// libraries (such as graphics lib: GLib) that require thread-level manipulation via cgo, or want all I/O
@@ -130,6 +222,148 @@ type Options struct {
 	// SocketSendBuffer sets the maximum socket send buffer of kernel in bytes.
 	SocketSendBuffer int
 
+	// IdleTimeout is the maximum amount of time a connection may be idle,
+	// without reading or writing any data, before it's closed by the engine.
+	// The connection is closed with errors.ErrIdleTimeout passed to OnClose.
+	// The default is 0, which means the idle timeout is disabled.
+	// Note that this option is only available for stream-oriented protocol
+	// on UNIX-like platforms.
+	IdleTimeout time.Duration
+
+	// UDPSessionTimeout enables the session mode of UDP listeners when it's greater than 0,
+	// in which the engine keeps a connection for each remote address instead of a throwaway
+	// one for each datagram: OnOpen fires on the first datagram from a remote, the context
+	// of the connection persists across datagrams, and the connection is closed with
+	// errors.ErrIdleTimeout passed to OnClose once it has been idle for UDPSessionTimeout.
+	// Closing a session doesn't affect the listener, a new session is opened on the next
+	// datagram from the same remote.
+	// It can be set for each listener, while MaxUDPSessions is engine-wide.
+	// Note that this option is only available on UNIX-like platforms.
+	UDPSessionTimeout time.Duration
+
+	// MaxUDPSessions is the maximum number of UDP sessions that can be open at the same
+	// time across all listeners, it's unlimited if it's not greater than 0. The datagrams
+	// from the remotes without a session are dropped while the limit is reached.
+	// It's engine-wide and can't be set for each listener, the option is ignored in the
+	// Options of ListenerConfig and AddListener, unlike UDPSessionTimeout.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	MaxUDPSessions int
+
+	// HalfClose keeps a stream-oriented connection open when the remote shuts down
+	// the writing side of the connection, instead of closing it right away. OnReadClosed
+	// of the HalfCloseHandler fires then, and the connection can still be written to
+	// until Conn.CloseWrite or Conn.Close is called. The connection is closed once both
+	// sides have been shut down.
+	// Note that this option is only available on UNIX-like platforms.
+	HalfClose bool
+
+	// UnixRights enables receiving the file descriptors passed by SCM_RIGHTS on Unix domain
+	// sockets, which are reported by Conn.ReceivedFDs. The file descriptors are discarded
+	// by the kernel when it's disabled.
+	// Note that this option is only available on UNIX-like platforms.
+	UnixRights bool
+
+	// TLSConfig enables TLS on stream-oriented connections when it's not nil,
+	// OnTraffic will see the decrypted bytes and all writes will be encrypted
+	// transparently. ALPN, SNI-based certificate selection and session resumption
+	// are all driven by the tls.Config itself.
+	// The handshake is driven by crypto/tls, which blocks until the peer responds, thus
+	// each handshake runs on a goroutine of the global goroutine pool, see MaxTLSHandshakes.
+	// Once it completes, the records are encrypted and decrypted on the event-loop.
+	// For servers, the config must contain at least one certificate or else set
+	// GetCertificate. For clients, the ServerName is derived from the address
+	// passed to Client.Dial if it's empty, as tls.Dial does. Connections registered
+	// via EventLoop.Register or EventLoop.Enroll are not affected by this option.
+	// Note that this option is only available on UNIX-like platforms, Run and
+	// NewClient return errors.ErrUnsupportedOp on other platforms when it's set.
+	TLSConfig *tls.Config
+
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS handshake
+	// to complete since the connection is opened, the default is 5 seconds. It takes
+	// effect only when TLSConfig is set.
+	TLSHandshakeTimeout time.Duration
+
+	// MaxTLSHandshakes is the maximum number of TLS handshakes that can be in progress
+	// at the same time on a server across all listeners, the default is 4096. crypto/tls
+	// can't suspend a handshake while waiting for the peer, so the server waits for the
+	// first record of the ClientHello on the event-loop, after which the rest of the
+	// handshake occupies a goroutine of the global goroutine pool until it completes or
+	// TLSHandshakeTimeout elapses. This limit bounds those goroutines no matter how many
+	// remote IPs the handshakes come from. The connections beyond the limit are closed
+	// with errors.ErrTooManyHandshakes.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	MaxTLSHandshakes int
+
+	// MaxTLSHandshakesPerIP is the maximum number of TLS handshakes from the same remote IP
+	// that can be in progress at the same time on a server, the default is 64. It keeps a
+	// peer that stalls its handshakes from taking up MaxTLSHandshakes by itself. The
+	// connections beyond the limit are closed with errors.ErrTooManyHandshakes. Unix domain
+	// sockets are only counted in MaxTLSHandshakes.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	MaxTLSHandshakesPerIP int
+
+	// ProxyProtocol enables decoding the HAProxy PROXY protocol header, either version 1
+	// or 2, at the beginning of the accepted stream-oriented connections. OnOpen is deferred
+	// until the header has been read, after which Conn.RemoteAddr and Conn.LocalAddr report
+	// the addresses carried by the header and Conn.ProxyHeader reports the header itself.
+	// The connections that fail to present a valid header are closed without firing OnOpen
+	// or OnClose in the ProxyProtocolRequired mode. In the ProxyProtocolOptional mode, the
+	// connections that don't start with a header are opened as is, which are opened after
+	// ProxyProtocolTimeout if they don't send anything, so it's not recommended for the
+	// protocols in which the server speaks first, and it requires ProxyProtocolTrustedCIDRs.
+	// The header precedes the TLS handshake.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	ProxyProtocol ProxyProtocolMode
+
+	// ProxyProtocolTrustedCIDRs are the networks of the proxies that are allowed to send
+	// the PROXY protocol header. The header is not decoded for the connections from untrusted
+	// sources, which are opened as is in the ProxyProtocolOptional mode and closed in the
+	// ProxyProtocolRequired mode. All sources are trusted if it's empty, which is only allowed
+	// in the ProxyProtocolRequired mode: any client could otherwise claim an arbitrary address
+	// in the ProxyProtocolOptional mode, so starting a TCP listener in that mode without
+	// trusted networks fails with errors.ErrNoTrustedProxies. Unix domain sockets are always
+	// trusted.
+	ProxyProtocolTrustedCIDRs []*net.IPNet
+
+	// ProxyProtocolTimeout is the maximum amount of time to wait for the PROXY protocol
+	// header, the default is 10 seconds. It takes effect only when ProxyProtocol is enabled.
+	ProxyProtocolTimeout time.Duration
+
+	// MaxConnections is the maximum number of the accepted connections that can be open
+	// at the same time across all listeners, it's unlimited if it's not greater than 0.
+	// The connections beyond it are handled in accordance with RejectPolicy.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	MaxConnections int
+
+	// MaxConnectionsPerIP is the maximum number of the accepted connections from a single
+	// source IP that can be open at the same time, it's unlimited if it's not greater than 0.
+	// The connections beyond it are always closed right away after writing RejectResponse
+	// regardless of RejectPolicy. The source IP is the address of the peer of the socket,
+	// which is not affected by ProxyProtocol.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	MaxConnectionsPerIP int
+
+	// RejectPolicy is the policy of handling the connections beyond MaxConnections,
+	// the default is RejectClose.
+	// This option is server-only.
+	RejectPolicy RejectPolicy
+
+	// RejectResponse is written to the connections rejected by the connection limits
+	// before they're closed, nothing is written if it's empty. It's written in a single
+	// non-blocking write in plaintext, even if TLSConfig is set.
+	// This option is server-only.
+	RejectResponse []byte
+
+	// Codec splits the inbound data into messages for EventHandler that implements MessageHandler.
+	// It's also used to frame the out returned by OnMessage.
+	Codec Codec
+
 	// LogPath specifies a local path where logs will be written, this is the easiest
 	// way to set up logging, gnet instantiates a default uber-go/zap logger with this
 	// given log path, you are also allowed to employ your own logger during the lifetime
@@ -157,6 +391,37 @@ type Options struct {
 	// 1MB is used. The value of EdgeTriggeredIOChunk must be a power of 2,
 	// otherwise, it will be rounded up to the nearest power of 2.
 	EdgeTriggeredIOChunk int
+
+	// IOURing makes event-loops perform network I/O with io_uring rather than epoll:
+	// accepting connections, receiving data into the buffers provided to the kernel
+	// and sending data are submitted as requests, the readiness of other sockets is
+	// monitored with poll requests. It's only available on Linux 6.3+, gnet falls back
+	// to epoll with a warning when the kernel lacks support, and it's ignored on other
+	// platforms. Unix domain sockets with UnixRights and unixpacket sockets are served
+	// with system calls as before, and ZeroCopyWriteThreshold has no effect on the rest.
+	IOURing bool
+
+	// InheritedListeners are the listening sockets inherited from another process,
+	// the engine adopts the one bound to the same network address instead of opening
+	// a new listener, which enables zero-downtime restarts. The sockets are duplicated,
+	// so the files can be closed once the engine has started.
+	// Socket options other than TCPKeepAlive are not applied to the adopted sockets,
+	// and the socket files of the adopted Unix domain sockets are left on shutdown.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	InheritedListeners []*os.File
+
+	// Metrics enables the collection of metrics of event-loops, which are retrieved with
+	// Engine.Metrics or served by the Handler of package metrics. It's implied by a non-nil MetricsSink.
+	// Note that this option is only available on UNIX-like platforms.
+	// This option is server-only.
+	Metrics bool
+
+	// MetricsSink receives the snapshot of metrics every MetricsInterval when it's set.
+	MetricsSink MetricsSink
+
+	// MetricsInterval is the interval of pushing metrics to MetricsSink, the default is 10 seconds.
+	MetricsInterval time.Duration
 }
 
 // WithOptions sets up all options.
@@ -194,6 +459,62 @@ func WithWriteBufferCap(writeBufferCap int) Option {
 	}
 }
 
+// WithUDPBatchSize sets the maximum number of datagrams read from a UDP socket at a time.
+func WithUDPBatchSize(size int) Option {
+	return func(opts *Options) {
+		opts.UDPBatchSize = size
+	}
+}
+
+// WithUDPGRO enables UDP_GRO on UDP sockets.
+func WithUDPGRO(gro bool) Option {
+	return func(opts *Options) {
+		opts.UDPGRO = gro
+	}
+}
+
+// WithUDPGSO enables UDP_SEGMENT for Conn.SendSegments.
+func WithUDPGSO(gso bool) Option {
+	return func(opts *Options) {
+		opts.UDPGSO = gso
+	}
+}
+
+// WithUDPPacketInfo enables the ancillary data of the datagrams received on UDP sockets.
+func WithUDPPacketInfo(info bool) Option {
+	return func(opts *Options) {
+		opts.UDPPacketInfo = info
+	}
+}
+
+// WithOutboundHighWatermark sets OutboundHighWatermark for backpressure of connections.
+func WithOutboundHighWatermark(highWatermark int) Option {
+	return func(opts *Options) {
+		opts.OutboundHighWatermark = highWatermark
+	}
+}
+
+// WithOutboundLowWatermark sets OutboundLowWatermark for backpressure of connections.
+func WithOutboundLowWatermark(lowWatermark int) Option {
+	return func(opts *Options) {
+		opts.OutboundLowWatermark = lowWatermark
+	}
+}
+
+// WithPauseReadOnBackpressure sets PauseReadOnBackpressure for backpressure of connections.
+func WithPauseReadOnBackpressure(pause bool) Option {
+	return func(opts *Options) {
+		opts.PauseReadOnBackpressure = pause
+	}
+}
+
+// WithZeroCopyWriteThreshold sets ZeroCopyWriteThreshold for zero-copy writes of TCP connections.
+func WithZeroCopyWriteThreshold(threshold int) Option {
+	return func(opts *Options) {
+		opts.ZeroCopyWriteThreshold = threshold
+	}
+}
+
 // WithLoadBalancing picks the load-balancing algorithm for gnet engine.
 func WithLoadBalancing(lb LoadBalancing) Option {
 	return func(opts *Options) {
@@ -265,6 +586,128 @@ func WithSocketSendBuffer(sendBuf int) Option {
 	}
 }
 
+// WithIdleTimeout sets the maximum amount of time a connection may be idle before it's closed.
+func WithIdleTimeout(idleTimeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.IdleTimeout = idleTimeout
+	}
+}
+
+// WithUDPSessionTimeout enables the session mode of UDP listeners with the given idle timeout.
+func WithUDPSessionTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.UDPSessionTimeout = timeout
+	}
+}
+
+// WithHalfClose sets HalfClose for stream-oriented connections.
+func WithHalfClose(halfClose bool) Option {
+	return func(opts *Options) {
+		opts.HalfClose = halfClose
+	}
+}
+
+// WithUnixRights enables receiving the file descriptors passed by SCM_RIGHTS on Unix domain sockets.
+func WithUnixRights(unixRights bool) Option {
+	return func(opts *Options) {
+		opts.UnixRights = unixRights
+	}
+}
+
+// WithTLSConfig sets up the TLS configuration for connections.
+// Note that each TLS handshake runs on a goroutine, see Options.MaxTLSHandshakes.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(opts *Options) {
+		opts.TLSConfig = config
+	}
+}
+
+// WithTLSHandshakeTimeout sets the maximum amount of time to wait for a TLS handshake to complete.
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.TLSHandshakeTimeout = timeout
+	}
+}
+
+// WithMaxTLSHandshakes sets up the maximum number of TLS handshakes that can be in progress
+// at the same time.
+func WithMaxTLSHandshakes(n int) Option {
+	return func(opts *Options) {
+		opts.MaxTLSHandshakes = n
+	}
+}
+
+// WithMaxTLSHandshakesPerIP sets up the maximum number of TLS handshakes from the same remote IP
+// that can be in progress at the same time.
+func WithMaxTLSHandshakesPerIP(n int) Option {
+	return func(opts *Options) {
+		opts.MaxTLSHandshakesPerIP = n
+	}
+}
+
+// WithProxyProtocol sets up the mode of decoding the PROXY protocol header.
+func WithProxyProtocol(mode ProxyProtocolMode) Option {
+	return func(opts *Options) {
+		opts.ProxyProtocol = mode
+	}
+}
+
+// WithProxyProtocolTrustedCIDRs sets up the networks of the proxies that are trusted to send the PROXY protocol header.
+func WithProxyProtocolTrustedCIDRs(cidrs ...*net.IPNet) Option {
+	return func(opts *Options) {
+		opts.ProxyProtocolTrustedCIDRs = cidrs
+	}
+}
+
+// WithProxyProtocolTimeout sets up the timeout for reading the PROXY protocol header.
+func WithProxyProtocolTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.ProxyProtocolTimeout = timeout
+	}
+}
+
+// WithMaxUDPSessions sets up the maximum number of UDP sessions that can be open at the same time.
+func WithMaxUDPSessions(n int) Option {
+	return func(opts *Options) {
+		opts.MaxUDPSessions = n
+	}
+}
+
+// WithMaxConnections sets up the maximum number of the accepted connections that can be open at the same time.
+func WithMaxConnections(n int) Option {
+	return func(opts *Options) {
+		opts.MaxConnections = n
+	}
+}
+
+// WithMaxConnectionsPerIP sets up the maximum number of the accepted connections from a single source IP.
+func WithMaxConnectionsPerIP(n int) Option {
+	return func(opts *Options) {
+		opts.MaxConnectionsPerIP = n
+	}
+}
+
+// WithRejectPolicy sets up the policy of handling the connections beyond MaxConnections.
+func WithRejectPolicy(policy RejectPolicy) Option {
+	return func(opts *Options) {
+		opts.RejectPolicy = policy
+	}
+}
+
+// WithRejectResponse sets up the response written to the rejected connections before they're closed.
+func WithRejectResponse(response []byte) Option {
+	return func(opts *Options) {
+		opts.RejectResponse = response
+	}
+}
+
+// WithCodec sets up the Codec for MessageHandler.
+func WithCodec(codec Codec) Option {
+	return func(opts *Options) {
+		opts.Codec = codec
+	}
+}
+
 // WithTicker indicates whether a ticker is currently set.
 func WithTicker(ticker bool) Option {
 	return func(opts *Options) {
@@ -324,3 +767,38 @@ func WithEdgeTriggeredIOChunk(chunk int) Option {
 		opts.EdgeTriggeredIOChunk = chunk
 	}
 }
+
+// WithIOURing enables the io_uring-based poller on Linux.
+func WithIOURing(enable bool) Option {
+	return func(opts *Options) {
+		opts.IOURing = enable
+	}
+}
+
+// WithInheritedListeners sets up the inherited listening sockets to adopt.
+func WithInheritedListeners(files ...*os.File) Option {
+	return func(opts *Options) {
+		opts.InheritedListeners = files
+	}
+}
+
+// WithMetrics enables the collection of metrics.
+func WithMetrics(enable bool) Option {
+	return func(opts *Options) {
+		opts.Metrics = enable
+	}
+}
+
+// WithMetricsSink sets up the sink of metrics.
+func WithMetricsSink(sink MetricsSink) Option {
+	return func(opts *Options) {
+		opts.MetricsSink = sink
+	}
+}
+
+// WithMetricsInterval sets up the interval of pushing metrics to the sink.
+func WithMetricsInterval(interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.MetricsInterval = interval
+	}
+}
@@ -3,25 +3,35 @@
 package gnet
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
+	"os"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/sys/unix"
 
 	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
 	"github.com/panjf2000/gnet/v2/pkg/logging"
+	"github.com/panjf2000/gnet/v2/pkg/netpoll"
 	goPool "github.com/panjf2000/gnet/v2/pkg/pool/goroutine"
 )
 
@@ -729,3 +739,3711 @@ func (s *testEngineClosedWakeUpServer) OnClose(Conn, error) (action Action) {
 	return
 }
 */
+
+func TestEngineDrain(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testEngineDrain(t, "tcp", ":9911", false)
+	})
+	t.Run("tcp-reuseport", func(t *testing.T) {
+		testEngineDrain(t, "tcp", ":9912", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testEngineDrain(t, "unix", testUnixAddr(t), false)
+	})
+	t.Run("tcp-canceled", func(t *testing.T) {
+		testEngineDrainCanceled(t, "tcp", ":9911")
+	})
+}
+
+type testEngineDrainServer struct {
+	*BuiltinEventEngine
+	tester   *testing.T
+	eng      Engine
+	network  string
+	addr     string
+	timeout  time.Duration
+	canceled bool // whether the draining is cut short by canceling ctx
+	started  bool
+	opened   int32
+	drained  int32
+	finished chan struct{}
+}
+
+func (s *testEngineDrainServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineDrainServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testEngineDrainServer) OnOpen(Conn) (out []byte, action Action) {
+	atomic.AddInt32(&s.opened, 1)
+	return
+}
+
+func (s *testEngineDrainServer) OnDraining(c Conn) (action Action) {
+	atomic.AddInt32(&s.drained, 1)
+	_, err := c.Write([]byte("GOAWAY"))
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testEngineDrainServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testEngineDrainServer) runClients() {
+	defer close(s.finished)
+
+	const nclients = 4
+	conns := make([]net.Conn, 0, nclients)
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+	buf := make([]byte, 6)
+	for i := 0; i < nclients; i++ {
+		c, err := net.Dial(s.network, s.addr)
+		if !assert.NoError(s.tester, err) {
+			_ = s.eng.Stop(context.Background())
+			return
+		}
+		conns = append(conns, c)
+		_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = c.Write([]byte("ping"))
+		assert.NoError(s.tester, err)
+		_, err = io.ReadFull(c, buf[:4])
+		assert.NoError(s.tester, err)
+	}
+	assert.EqualValues(s.tester, nclients, s.eng.CountConnections())
+
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() {
+		drained <- s.eng.Drain(ctx, s.timeout)
+	}()
+
+	// Every connection is told to go away.
+	for _, c := range conns {
+		_, err := io.ReadFull(c, buf)
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, "GOAWAY", string(buf))
+	}
+	assert.EqualValues(s.tester, nclients, atomic.LoadInt32(&s.drained))
+
+	// Connections close themselves except for the last one.
+	for _, c := range conns[:nclients-1] {
+		_ = c.Close()
+	}
+	assert.Eventually(s.tester, func() bool {
+		return s.eng.CountConnections() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// New connections are no longer accepted.
+	if c, err := net.Dial(s.network, s.addr); err == nil {
+		_, _ = c.Write([]byte("ping"))
+		time.Sleep(100 * time.Millisecond)
+		_ = c.Close()
+	}
+	assert.EqualValues(s.tester, nclients, atomic.LoadInt32(&s.opened))
+
+	if s.canceled {
+		// Canceling ctx shuts down the Engine as the timeout does.
+		cancel()
+		_, err := conns[nclients-1].Read(buf)
+		assert.ErrorIs(s.tester, err, io.EOF)
+		assert.ErrorIs(s.tester, <-drained, context.Canceled)
+		assert.Less(s.tester, time.Since(start), s.timeout)
+		return
+	}
+
+	// The remaining connection is closed forcibly once the timeout elapses.
+	_, err := conns[nclients-1].Read(buf)
+	assert.ErrorIs(s.tester, err, io.EOF)
+	assert.NoError(s.tester, <-drained)
+	assert.GreaterOrEqual(s.tester, time.Since(start), s.timeout)
+}
+
+func testEngineDrain(t *testing.T, network, addr string, reuseport bool) {
+	svr := &testEngineDrainServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		timeout:            time.Second,
+		finished:           make(chan struct{}),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithMulticore(true),
+		WithNumEventLoop(2),
+		WithReusePort(reuseport),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	<-svr.finished
+}
+
+func testEngineDrainCanceled(t *testing.T, network, addr string) {
+	svr := &testEngineDrainServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		timeout:            time.Minute,
+		canceled:           true,
+		finished:           make(chan struct{}),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithMulticore(true),
+		WithNumEventLoop(2),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	<-svr.finished
+}
+
+func TestInheritedListeners(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testInheritedListeners(t, "tcp", ":9913", false)
+	})
+	t.Run("tcp-option", func(t *testing.T) {
+		testInheritedListeners(t, "tcp", ":9914", true)
+	})
+	t.Run("udp", func(t *testing.T) {
+		testInheritedListeners(t, "udp", ":9915", false)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testInheritedListeners(t, "unix", testUnixAddr(t), false)
+	})
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("LISTEN_FDS", "")
+		files, err := InheritedListeners()
+		assert.NoError(t, err)
+		assert.Nil(t, files)
+
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		files, err = InheritedListeners()
+		assert.NoError(t, err)
+		assert.Nil(t, files)
+		assert.Empty(t, os.Getenv("LISTEN_FDS"))
+		assert.Empty(t, os.Getenv("LISTEN_PID"))
+
+		t.Setenv("LISTEN_FDS", "-1")
+		_, err = InheritedListeners()
+		assert.ErrorIs(t, err, errorx.ErrInvalidListenFDs)
+	})
+}
+
+type testInheritedListenersServer struct {
+	*BuiltinEventEngine
+	tester  *testing.T
+	eng     Engine
+	network string
+	addr    string
+	started bool
+	done    bool
+}
+
+func (s *testInheritedListenersServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testInheritedListenersServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClient)
+		assert.NoError(s.tester, err)
+	}
+	delay = 100 * time.Millisecond
+	return
+}
+
+func (s *testInheritedListenersServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testInheritedListenersServer) runClient() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close() //nolint:errcheck
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = c.Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(c, buf)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "ping", string(buf))
+}
+
+func testInheritedListeners(t *testing.T, network, addr string, option bool) {
+	// Keep the original listener open, so the engine would fail
+	// with EADDRINUSE if it didn't adopt the inherited socket.
+	var f *os.File
+	if network == "udp" {
+		pc, err := net.ListenPacket(network, addr)
+		assert.NoError(t, err)
+		defer pc.Close() //nolint:errcheck
+		f, err = pc.(*net.UDPConn).File()
+		assert.NoError(t, err)
+	} else {
+		ln, err := net.Listen(network, addr)
+		assert.NoError(t, err)
+		defer ln.Close() //nolint:errcheck
+		switch ln := ln.(type) {
+		case *net.TCPListener:
+			f, err = ln.File()
+		case *net.UnixListener:
+			ln.SetUnlinkOnClose(false)
+			f, err = ln.File()
+		}
+		assert.NoError(t, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	svr := &testInheritedListenersServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+	}
+	var err error
+	if option {
+		err = Run(svr, network+"://"+addr, WithTicker(true), WithInheritedListeners(f))
+	} else {
+		err = RunWithListeners(svr, []*os.File{f}, WithTicker(true))
+	}
+	assert.NoError(t, err)
+	if network == "unix" {
+		_, err = os.Stat(addr)
+		assert.NoError(t, err, "the socket file of the inherited listener is removed")
+	}
+}
+
+func TestInheritedListenerAdoptedOnce(t *testing.T) {
+	ln, err := net.Listen("tcp", ":9980")
+	assert.NoError(t, err)
+	defer ln.Close() //nolint:errcheck
+	f, err := ln.(*net.TCPListener).File()
+	assert.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	// The listeners with options of their own share the inherited sockets of the engine.
+	base := &Options{InheritedListeners: []*os.File{f}}
+	adopted := make(map[*os.File]struct{})
+	l1, err := initListener("tcp", ":9980", listenerOptions(base, []Option{WithTCPNoDelay(TCPNoDelay)}), adopted)
+	assert.NoError(t, err)
+	defer l1.close()
+	assert.Same(t, f, l1.file)
+	l2, err := initListener("tcp", ":9980", listenerOptions(base, []Option{WithTCPNoDelay(TCPDelay)}), adopted)
+	assert.ErrorIs(t, err, unix.EADDRINUSE, "the inherited socket is adopted twice")
+	l2.close()
+	assert.Nil(t, l2.file)
+	assert.Len(t, base.InheritedListeners, 1)
+}
+
+func TestEngineHandoff(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testEngineHandoff(t, "tcp", ":9916", false)
+	})
+	t.Run("tcp-reuseport", func(t *testing.T) {
+		testEngineHandoff(t, "tcp", ":9967", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testEngineHandoff(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+// TestEngineHandoffChild isn't a real test, it's the new process started by TestEngineHandoff.
+func TestEngineHandoffChild(t *testing.T) {
+	if os.Getenv("LISTEN_FDS") == "" {
+		t.Skip("helper process of TestEngineHandoff")
+	}
+	files, err := InheritedListeners()
+	assert.NoError(t, err)
+	// Only one of the copies of a listener opened under ReusePort is handed off.
+	assert.Len(t, files, 1)
+	err = RunWithListeners(&testEngineHandoffServer{BuiltinEventEngine: &BuiltinEventEngine{}, reply: "C"},
+		files, WithTicker(true))
+	assert.NoError(t, err)
+	if !t.Failed() {
+		os.Exit(0)
+	}
+}
+
+type testEngineHandoffServer struct {
+	*BuiltinEventEngine
+	tester   *testing.T
+	eng      Engine
+	network  string
+	addr     string
+	reply    string
+	started  bool
+	finished chan struct{}
+}
+
+func (s *testEngineHandoffServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineHandoffServer) OnTick() (delay time.Duration, action Action) {
+	if s.tester == nil {
+		// The new process shuts itself down if it's left over.
+		if s.started {
+			action = Shutdown
+		}
+		s.started = true
+		return 10 * time.Second, action
+	}
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testEngineHandoffServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	if string(buf) == "quit" {
+		return Shutdown
+	}
+	_, _ = c.Write([]byte(s.reply))
+	return
+}
+
+func (s *testEngineHandoffServer) ping(c net.Conn) string {
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write([]byte("ping")); err != nil {
+		return err.Error()
+	}
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return err.Error()
+	}
+	return string(buf)
+}
+
+func (s *testEngineHandoffServer) runClients() {
+	defer close(s.finished)
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		_ = s.eng.Stop(context.Background())
+		return
+	}
+	defer c.Close() //nolint:errcheck
+	assert.Equal(s.tester, "P", s.ping(c))
+
+	type result struct {
+		p   *os.Process
+		err error
+	}
+	handedOff := make(chan result, 1)
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestEngineHandoffChild$"}
+	go func() {
+		p, err := s.eng.Handoff(context.Background(), 10*time.Second)
+		handedOff <- result{p, err}
+	}()
+
+	// The new process takes over the listener.
+	var cc net.Conn
+	assert.Eventually(s.tester, func() bool {
+		if cc != nil {
+			_ = cc.Close()
+		}
+		if cc, err = net.Dial(s.network, s.addr); err != nil {
+			return false
+		}
+		return s.ping(cc) == "C"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The existing connection is still served by this process.
+	assert.Equal(s.tester, "P", s.ping(c))
+	_ = c.Close()
+	r := <-handedOff
+	os.Args = args
+	assert.NoError(s.tester, r.err)
+	if !assert.NotNil(s.tester, r.p) {
+		return
+	}
+
+	// The listener lives on after this process shuts down.
+	c, err = net.Dial(s.network, s.addr)
+	if assert.NoError(s.tester, err) {
+		assert.Equal(s.tester, "C", s.ping(c))
+	}
+	if cc != nil {
+		_, err = cc.Write([]byte("quit"))
+		assert.NoError(s.tester, err)
+	}
+	state, err := r.p.Wait()
+	assert.NoError(s.tester, err)
+	assert.True(s.tester, state.Success(), "the new process exits with %v", state)
+}
+
+func testEngineHandoff(t *testing.T, network, addr string, reuseport bool) {
+	svr := &testEngineHandoffServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		reply:              "P",
+		finished:           make(chan struct{}),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithReusePort(reuseport),
+		WithNumEventLoop(4))
+	assert.NoError(t, err)
+	<-svr.finished
+}
+
+func TestEngineAddRemoveListener(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testEngineAddRemoveListener(t, ":9917", ":9918", "", false)
+	})
+	t.Run("tcp-reuseport", func(t *testing.T) {
+		testEngineAddRemoveListener(t, ":9919", ":9920", ":9921", true)
+	})
+}
+
+type testEngineListenersServer struct {
+	*BuiltinEventEngine
+	tester    *testing.T
+	eng       Engine
+	addr      string
+	tcpAddr   string
+	udpAddr   string
+	unixAddr  string
+	reuseport bool
+	started   bool
+}
+
+func (s *testEngineListenersServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineListenersServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testEngineListenersServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testEngineListenersServer) echo(c net.Conn) bool {
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write([]byte("ping")); !assert.NoError(s.tester, err) {
+		return false
+	}
+	buf := make([]byte, 4)
+	_, err := io.ReadFull(c, buf)
+	return assert.NoError(s.tester, err) && assert.Equal(s.tester, "ping", string(buf))
+}
+
+func (s *testEngineListenersServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	// New listeners are served right away.
+	tcpAddr, unixAddr := "tcp://"+s.tcpAddr, "unix://"+s.unixAddr
+	assert.NoError(s.tester, s.eng.AddListener(tcpAddr, WithTCPKeepAlive(time.Minute)))
+	assert.ErrorIs(s.tester, s.eng.AddListener(tcpAddr), errorx.ErrDuplicateListener)
+	assert.NoError(s.tester, s.eng.AddListener(unixAddr))
+	var conns []net.Conn
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+	for _, addr := range []string{s.tcpAddr, s.unixAddr} {
+		network := "tcp"
+		if addr == s.unixAddr {
+			network = "unix"
+		}
+		for i := 0; i < 4; i++ {
+			c, err := net.Dial(network, addr)
+			if !assert.NoError(s.tester, err) {
+				return
+			}
+			conns = append(conns, c)
+			s.echo(c)
+		}
+	}
+	if s.reuseport {
+		udpAddr := "udp://" + s.udpAddr
+		assert.NoError(s.tester, s.eng.AddListener(udpAddr))
+		c, err := net.Dial("udp", s.udpAddr)
+		if assert.NoError(s.tester, err) {
+			s.echo(c)
+			_ = c.Close()
+		}
+		assert.NoError(s.tester, s.eng.RemoveListener(udpAddr))
+	} else {
+		assert.ErrorIs(s.tester, s.eng.AddListener("udp://:9999"), errorx.ErrUnsupportedOp)
+	}
+
+	// Removed listeners are closed while the accepted connections remain.
+	assert.NoError(s.tester, s.eng.RemoveListener(tcpAddr))
+	assert.NoError(s.tester, s.eng.RemoveListener(unixAddr))
+	assert.ErrorIs(s.tester, s.eng.RemoveListener(tcpAddr), errorx.ErrInvalidNetworkAddress)
+	_, err := net.Dial("tcp", s.tcpAddr)
+	assert.Error(s.tester, err)
+	_, err = net.Dial("unix", s.unixAddr)
+	assert.Error(s.tester, err)
+	for _, c := range conns {
+		s.echo(c)
+	}
+	assert.EqualValues(s.tester, len(conns), s.eng.CountConnections())
+
+	// The original listener keeps serving, but it can't be removed as the last one.
+	c, err := net.Dial("tcp", s.addr)
+	if assert.NoError(s.tester, err) {
+		s.echo(c)
+		_ = c.Close()
+	}
+	assert.ErrorIs(s.tester, s.eng.RemoveListener("tcp://"+s.addr), errorx.ErrUnsupportedOp)
+}
+
+func testEngineAddRemoveListener(t *testing.T, addr, tcpAddr, udpAddr string, reuseport bool) {
+	svr := &testEngineListenersServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               addr,
+		tcpAddr:            tcpAddr,
+		udpAddr:            udpAddr,
+		unixAddr:           testUnixAddr(t),
+		reuseport:          reuseport,
+	}
+	err := Run(svr, "tcp://"+addr,
+		WithTicker(true),
+		WithMulticore(true),
+		WithNumEventLoop(2),
+		WithReusePort(reuseport),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+}
+
+func TestEngineAddListenerRejected(t *testing.T) {
+	t.Run("proxy-protocol-optional", func(t *testing.T) {
+		testEngineAddListenerRejected(t, ":9969", "tcp://:9970", errorx.ErrNoTrustedProxies,
+			nil, WithProxyProtocol(ProxyProtocolOptional))
+	})
+	t.Run("udp-gro-small-buffer", func(t *testing.T) {
+		testEngineAddListenerRejected(t, ":9971", "udp://:9972", errorx.ErrTooSmallReadBuffer,
+			[]Option{WithUDPGRO(true)}, WithReadBufferCap(1024))
+	})
+}
+
+type testEngineAddListenerRejectedServer struct {
+	*BuiltinEventEngine
+	tester    *testing.T
+	eng       Engine
+	protoAddr string
+	opts      []Option
+	want      error
+	started   bool
+}
+
+func (s *testEngineAddListenerRejectedServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineAddListenerRejectedServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(func() {
+			assert.ErrorIs(s.tester, s.eng.AddListener(s.protoAddr, s.opts...), s.want)
+			assert.NoError(s.tester, s.eng.Stop(context.Background()))
+		})
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func testEngineAddListenerRejected(t *testing.T, addr, protoAddr string, want error, engOpts []Option, opts ...Option) {
+	svr := &testEngineAddListenerRejectedServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		protoAddr:          protoAddr,
+		opts:               opts,
+		want:               want,
+	}
+	engOpts = append(engOpts, WithTicker(true), WithReusePort(true), WithReuseAddr(true))
+	err := Run(svr, "tcp://"+addr, engOpts...)
+	assert.NoError(t, err)
+}
+
+func TestEngineAddListenerReadBufferCap(t *testing.T) {
+	svr := &testEngineAddListenerReadBufferCapServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               ":9983",
+	}
+	err := Run(svr, "tcp://:9982",
+		WithTicker(true),
+		WithMulticore(true),
+		WithNumEventLoop(2),
+		WithReadBufferCap(1024),
+		WithReusePort(true),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+}
+
+type testEngineAddListenerReadBufferCapServer struct {
+	*BuiltinEventEngine
+	tester  *testing.T
+	eng     Engine
+	addr    string
+	started bool
+}
+
+func (s *testEngineAddListenerReadBufferCapServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineAddListenerReadBufferCapServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClient)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testEngineAddListenerReadBufferCapServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testEngineAddListenerReadBufferCapServer) runClient() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	// The datagrams larger than the read buffers of the engine are not truncated.
+	if !assert.NoError(s.tester, s.eng.AddListener("udp://"+s.addr, WithReadBufferCap(8192))) {
+		return
+	}
+	c, err := net.Dial("udp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close() //nolint:errcheck
+	data := make([]byte, 4096)
+	_, _ = crand.Read(data)
+	_, err = c.Write(data)
+	assert.NoError(s.tester, err)
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 8192)
+	n, err := c.Read(buf)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, data, buf[:n])
+}
+
+func TestEngineAddListenerOnEventLoop(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testEngineAddListenerOnEventLoop(t, ":9958", ":9959", false)
+	})
+	t.Run("tcp-reuseport", func(t *testing.T) {
+		testEngineAddListenerOnEventLoop(t, ":9960", ":9961", true)
+	})
+}
+
+type testEngineListenersOnEventLoopServer struct {
+	*BuiltinEventEngine
+	tester  *testing.T
+	eng     Engine
+	addr    string
+	newAddr string
+	started bool
+}
+
+func (s *testEngineListenersOnEventLoopServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineListenersOnEventLoopServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClient)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testEngineListenersOnEventLoopServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	// The result is passed to the callback on the event-loop of c.
+	callback := func(err error) {
+		if assert.NoError(s.tester, err) {
+			_, err = c.Write([]byte("ok"))
+			assert.NoError(s.tester, err)
+		}
+	}
+	var err error
+	switch string(buf) {
+	case "add":
+		err = c.EventLoop().AddListener("tcp://"+s.newAddr, callback)
+	case "add-again":
+		err = c.EventLoop().AddListener("tcp://"+s.newAddr, func(err error) {
+			if assert.ErrorIs(s.tester, err, errorx.ErrDuplicateListener) {
+				_, err = c.Write([]byte("duplicate"))
+				assert.NoError(s.tester, err)
+			}
+		})
+	case "remove":
+		err = c.EventLoop().RemoveListener("tcp://"+s.newAddr, callback)
+	default:
+		_, err = c.Write(buf)
+	}
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testEngineListenersOnEventLoopServer) request(c net.Conn, req string) string {
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write([]byte(req)); !assert.NoError(s.tester, err) {
+		return ""
+	}
+	buf := make([]byte, 64)
+	n, err := c.Read(buf)
+	assert.NoError(s.tester, err)
+	return string(buf[:n])
+}
+
+func (s *testEngineListenersOnEventLoopServer) runClient() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close() //nolint:errcheck
+
+	// The listener added on an event-loop is served once the callback is invoked.
+	assert.Equal(s.tester, "ok", s.request(c, "add"))
+	cc, err := net.Dial("tcp", s.newAddr)
+	if assert.NoError(s.tester, err) {
+		assert.Equal(s.tester, "ping", s.request(cc, "ping"))
+		_ = cc.Close()
+	}
+	assert.Equal(s.tester, "duplicate", s.request(c, "add-again"))
+	assert.Equal(s.tester, "ok", s.request(c, "remove"))
+	_, err = net.Dial("tcp", s.newAddr)
+	assert.Error(s.tester, err)
+	assert.Equal(s.tester, "ping", s.request(c, "ping"))
+}
+
+func testEngineAddListenerOnEventLoop(t *testing.T, addr, newAddr string, reuseport bool) {
+	svr := &testEngineListenersOnEventLoopServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               addr,
+		newAddr:            newAddr,
+	}
+	err := Run(svr, "tcp://"+addr,
+		WithTicker(true),
+		WithMulticore(true),
+		WithNumEventLoop(2),
+		WithReusePort(reuseport),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+}
+
+func TestRotateListeners(t *testing.T) {
+	unixAddr := testUnixAddr(t)
+	svr := &testRotateListenersServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		public:             &testListenerHandler{tester: t},
+		admin:              &testListenerHandler{tester: t},
+		unixAddr:           unixAddr,
+	}
+	configs := []ListenerConfig{
+		{
+			ProtoAddr: "tcp://:9922",
+			Handler:   svr.public,
+			Options:   []Option{WithCodec(NewDelimiterBasedFrameCodec('\n', 0)), WithIdleTimeout(200 * time.Millisecond)},
+		},
+		{
+			ProtoAddr: "unix://" + unixAddr,
+			Handler:   svr.admin,
+		},
+		{
+			ProtoAddr: "tcp://:9923",
+		},
+	}
+	err := RotateListeners(svr, configs,
+		WithTicker(true),
+		WithMulticore(true),
+		WithNumEventLoop(2),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.booted))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.opened))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.public.opened))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.admin.opened))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.public.closed))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.admin.closed))
+}
+
+type testListenerHandler struct {
+	BuiltinEventEngine
+	tester *testing.T
+	opened int32
+	closed int32
+	err    atomic.Value
+}
+
+func (h *testListenerHandler) OnOpen(Conn) (out []byte, action Action) {
+	atomic.AddInt32(&h.opened, 1)
+	return
+}
+
+func (h *testListenerHandler) OnClose(_ Conn, err error) (action Action) {
+	if err != nil {
+		h.err.Store(err)
+	}
+	atomic.AddInt32(&h.closed, 1)
+	return
+}
+
+func (h *testListenerHandler) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(append([]byte(c.Listener()+" "), buf...))
+	assert.NoError(h.tester, err)
+	return
+}
+
+func (h *testListenerHandler) OnMessage(c Conn, msg []byte) (out []byte, action Action) {
+	return append([]byte(c.Listener()+" "), msg...), None
+}
+
+type testRotateListenersServer struct {
+	*BuiltinEventEngine
+	tester   *testing.T
+	eng      Engine
+	public   *testListenerHandler
+	admin    *testListenerHandler
+	unixAddr string
+	started  bool
+	booted   int32
+	opened   int32
+}
+
+func (s *testRotateListenersServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	atomic.AddInt32(&s.booted, 1)
+	return
+}
+
+func (s *testRotateListenersServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testRotateListenersServer) OnOpen(c Conn) (out []byte, action Action) {
+	assert.Equal(s.tester, "tcp://:9923", c.Listener())
+	atomic.AddInt32(&s.opened, 1)
+	return
+}
+
+func (s *testRotateListenersServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(append([]byte("default "), buf...))
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testRotateListenersServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	request := func(network, addr, msg, expected string) net.Conn {
+		c, err := net.Dial(network, addr)
+		if !assert.NoError(s.tester, err) {
+			return nil
+		}
+		_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = c.Write([]byte(msg))
+		assert.NoError(s.tester, err)
+		buf := make([]byte, len(expected))
+		_, err = io.ReadFull(c, buf)
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, expected, string(buf))
+		return c
+	}
+
+	// The public listener frames messages with its codec and closes idle connections.
+	public := request("tcp", ":9922", "hello\n", "tcp://:9922 hello\n")
+	admin := request("unix", s.unixAddr, "stats", "unix://"+s.unixAddr+" stats")
+	fallback := request("tcp", ":9923", "ping", "default ping")
+	for _, c := range []net.Conn{public, admin, fallback} {
+		if c == nil {
+			return
+		}
+	}
+	defer admin.Close()    //nolint:errcheck
+	defer fallback.Close() //nolint:errcheck
+
+	_, err := public.Read(make([]byte, 1))
+	assert.ErrorIs(s.tester, err, io.EOF)
+	_ = public.Close()
+	assert.ErrorIs(s.tester, s.public.err.Load().(error), errorx.ErrIdleTimeout)
+
+	// The other listeners don't inherit the idle timeout.
+	_, err = admin.Write([]byte("stats"))
+	assert.NoError(s.tester, err)
+	buf := make([]byte, len("unix://"+s.unixAddr+" stats"))
+	_, err = io.ReadFull(admin, buf)
+	assert.NoError(s.tester, err)
+}
+
+func TestEngineMetrics(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testEngineMetrics(t, ":9924", false)
+	})
+	t.Run("tcp-reuseport", func(t *testing.T) {
+		testEngineMetrics(t, ":9925", true)
+	})
+}
+
+type testMetricsSink struct {
+	collected chan []EventLoopMetrics
+}
+
+func (s *testMetricsSink) Collect(metrics []EventLoopMetrics) {
+	select {
+	case s.collected <- metrics:
+	default:
+	}
+}
+
+type testEngineMetricsServer struct {
+	*BuiltinEventEngine
+	tester  *testing.T
+	eng     Engine
+	addr    string
+	sink    *testMetricsSink
+	loop    atomic.Value
+	started bool
+}
+
+func (s *testEngineMetricsServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testEngineMetricsServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testEngineMetricsServer) OnTraffic(c Conn) (action Action) {
+	if s.loop.Load() == nil {
+		s.loop.Store(c.EventLoop())
+	}
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testEngineMetricsServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	const numConns = 4
+	payload := []byte("ping")
+	var conns []net.Conn
+	for i := 0; i < numConns; i++ {
+		c, err := net.Dial("tcp", s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		conns = append(conns, c)
+		_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = c.Write(payload)
+		assert.NoError(s.tester, err)
+		_, err = io.ReadFull(c, make([]byte, len(payload)))
+		assert.NoError(s.tester, err)
+	}
+	_ = conns[0].Close()
+
+	var (
+		metrics []EventLoopMetrics
+		total   EventLoopMetrics
+	)
+	assert.Eventually(s.tester, func() bool {
+		var err error
+		metrics, err = s.eng.Metrics()
+		if !assert.NoError(s.tester, err) {
+			return true
+		}
+		total = EventLoopMetrics{}
+		for _, m := range metrics {
+			total.Accepted += m.Accepted
+			total.Closed += m.Closed
+			total.Active += m.Active
+			total.BytesRead += m.BytesRead
+			total.BytesWritten += m.BytesWritten
+			total.TrafficLatency.Count += m.TrafficLatency.Count
+		}
+		return total.Closed == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(s.tester, metrics, 2)
+	assert.EqualValues(s.tester, numConns, total.Accepted)
+	assert.EqualValues(s.tester, numConns-1, total.Active)
+	assert.EqualValues(s.tester, numConns*len(payload), total.BytesRead)
+	assert.EqualValues(s.tester, numConns*len(payload), total.BytesWritten)
+	assert.EqualValues(s.tester, numConns, total.TrafficLatency.Count)
+	for i, m := range metrics {
+		assert.Equal(s.tester, i, m.Index)
+		h := m.TrafficLatency
+		assert.Len(s.tester, h.Counts, len(h.Bounds))
+		assert.LessOrEqual(s.tester, h.Counts[len(h.Counts)-1], h.Count)
+	}
+
+	// A connection registered to an event-loop is counted in both Accepted and Closed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer ln.Close()
+	peerCh := make(chan net.Conn, 1)
+	go func() {
+		peer, err := ln.Accept()
+		if assert.NoError(s.tester, err) {
+			peerCh <- peer
+		}
+		close(peerCh)
+	}()
+	resCh, err := s.loop.Load().(EventLoop).Register(context.Background(), ln.Addr())
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	res := <-resCh
+	if !assert.NoError(s.tester, res.Err) {
+		return
+	}
+	peer := <-peerCh
+	if !assert.NotNil(s.tester, peer) {
+		return
+	}
+	_ = peer.Close()
+	assert.Eventually(s.tester, func() bool {
+		metrics, err = s.eng.Metrics()
+		if !assert.NoError(s.tester, err) {
+			return true
+		}
+		total = EventLoopMetrics{}
+		for _, m := range metrics {
+			total.Accepted += m.Accepted
+			total.Closed += m.Closed
+		}
+		return total.Closed == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.EqualValues(s.tester, numConns+1, total.Accepted)
+
+	select {
+	case metrics := <-s.sink.collected:
+		assert.Len(s.tester, metrics, 2)
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for the metrics pushed to the sink")
+	}
+
+	var out bytes.Buffer
+	assert.NoError(s.tester, WriteMetrics(&out, metrics))
+	body := out.String()
+	for _, line := range []string{
+		"# TYPE gnet_connections_accepted_total counter",
+		"# TYPE gnet_traffic_duration_seconds histogram",
+		`gnet_connections_active{loop="0"}`,
+		`gnet_eagain_total{loop="1",op="write"}`,
+		`gnet_task_queue_depth{loop="0",queue="urgent"}`,
+		`gnet_traffic_duration_seconds_bucket{loop="1",le="+Inf"}`,
+	} {
+		assert.Contains(s.tester, body, line)
+	}
+
+	for _, c := range conns[1:] {
+		_ = c.Close()
+	}
+}
+
+func testEngineMetrics(t *testing.T, addr string, reuseport bool) {
+	svr := &testEngineMetricsServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               addr,
+		sink:               &testMetricsSink{collected: make(chan []EventLoopMetrics, 1)},
+	}
+	err := Run(svr, "tcp://"+addr,
+		WithTicker(true),
+		WithNumEventLoop(2),
+		WithLoadBalancing(RoundRobin),
+		WithReusePort(reuseport),
+		WithReuseAddr(true),
+		WithMetricsSink(svr.sink),
+		WithMetricsInterval(50*time.Millisecond))
+	assert.NoError(t, err)
+}
+
+func TestConnStats(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testConnStats(t, "tcp", ":9926")
+	})
+	t.Run("unix", func(t *testing.T) {
+		testConnStats(t, "unix", testUnixAddr(t))
+	})
+	t.Run("udp", func(t *testing.T) {
+		testConnStats(t, "udp", ":9962")
+	})
+}
+
+type testConnStatsServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	payload       []byte
+	rounds        int
+	stats         chan ConnStats
+	started       bool
+}
+
+func (s *testConnStatsServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testConnStatsServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testConnStatsServer) OnOpen(c Conn) (out []byte, action Action) {
+	stats := c.Stats()
+	assert.False(s.tester, stats.OpenedAt.IsZero())
+	assert.Equal(s.tester, stats.OpenedAt, stats.LastActive)
+	assert.Zero(s.tester, stats.BytesRead)
+	return
+}
+
+func (s *testConnStatsServer) OnTraffic(c Conn) (action Action) {
+	stats := c.Stats()
+	assert.False(s.tester, stats.OpenedAt.IsZero())
+	assert.Equal(s.tester, c.InboundBuffered(), stats.InboundBuffered)
+	assert.Equal(s.tester, c.OutboundBuffered(), stats.OutboundBuffered)
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testConnStatsServer) OnClose(c Conn, _ error) (action Action) {
+	s.stats <- c.Stats()
+	return
+}
+
+func (s *testConnStatsServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < s.rounds; i++ {
+		_, err = c.Write(s.payload)
+		assert.NoError(s.tester, err)
+		_, err = io.ReadFull(c, make([]byte, len(s.payload)))
+		assert.NoError(s.tester, err)
+	}
+	_ = c.Close()
+	if s.network == "udp" {
+		return // the connection of each datagram is never closed
+	}
+
+	select {
+	case stats := <-s.stats:
+		total := uint64(s.rounds * len(s.payload))
+		assert.Equal(s.tester, total, stats.BytesRead)
+		assert.Equal(s.tester, total, stats.BytesWritten)
+		assert.GreaterOrEqual(s.tester, stats.Reads, uint64(s.rounds))
+		assert.GreaterOrEqual(s.tester, stats.Writes, uint64(s.rounds))
+		assert.True(s.tester, stats.LastActive.After(stats.OpenedAt))
+		assert.Zero(s.tester, stats.OutboundBuffered)
+		if s.network == "tcp" && runtime.GOOS == "linux" {
+			if assert.NotNil(s.tester, stats.TCPInfo) {
+				assert.Positive(s.tester, stats.TCPInfo.RTT)
+				assert.Positive(s.tester, stats.TCPInfo.SndCwnd)
+			}
+		} else {
+			assert.Nil(s.tester, stats.TCPInfo)
+		}
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for OnClose")
+	}
+}
+
+func testConnStats(t *testing.T, network, addr string) {
+	svr := &testConnStatsServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		payload:            []byte("hello gnet"),
+		rounds:             3,
+		stats:              make(chan ConnStats, 1),
+	}
+	err := Run(svr, network+"://"+addr, WithTicker(true), WithReuseAddr(true))
+	assert.NoError(t, err)
+}
+
+func TestBackpressure(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testBackpressure(t, "tcp", ":9927", false)
+	})
+	t.Run("tcp-edge-triggered", func(t *testing.T) {
+		testBackpressure(t, "tcp", ":9928", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testBackpressure(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testBackpressureServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	high, low     int
+	payload       []byte
+	traffic       atomic.Int32
+	paused        chan struct{}
+	resumed       chan struct{}
+	started       bool
+}
+
+func (s *testBackpressureServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testBackpressureServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testBackpressureServer) OnTraffic(c Conn) (action Action) {
+	_, _ = c.Discard(-1)
+	if s.traffic.Add(1) == 1 {
+		_, err := c.Write(s.payload)
+		assert.NoError(s.tester, err)
+	}
+	return
+}
+
+func (s *testBackpressureServer) OnBackpressure(c Conn, paused bool) (action Action) {
+	if paused {
+		assert.GreaterOrEqual(s.tester, c.OutboundBuffered(), s.high)
+		close(s.paused)
+	} else {
+		assert.LessOrEqual(s.tester, c.OutboundBuffered(), s.low)
+		close(s.resumed)
+	}
+	return
+}
+
+func (s *testBackpressureServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	if tc, ok := c.(*net.TCPConn); ok {
+		_ = tc.SetReadBuffer(64 * 1024)
+	}
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	_, err = c.Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	select {
+	case <-s.paused:
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for the backpressure")
+		return
+	}
+
+	// Reading from the connection is paused, the second request must not be seen by the server.
+	_, err = c.Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.EqualValues(s.tester, 1, s.traffic.Load())
+
+	_, err = io.ReadFull(c, make([]byte, len(s.payload)))
+	assert.NoError(s.tester, err)
+	select {
+	case <-s.resumed:
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for the relief of backpressure")
+		return
+	}
+	assert.Eventually(s.tester, func() bool { return s.traffic.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func testBackpressure(t *testing.T, network, addr string, et bool) {
+	svr := &testBackpressureServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		high:               1 << 20,
+		low:                256 << 10,
+		payload:            bytes.Repeat([]byte("x"), 8<<20),
+		paused:             make(chan struct{}),
+		resumed:            make(chan struct{}),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithEdgeTriggeredIO(et),
+		WithSocketSendBuffer(64*1024),
+		WithOutboundHighWatermark(svr.high),
+		WithOutboundLowWatermark(svr.low),
+		WithPauseReadOnBackpressure(true))
+	assert.NoError(t, err)
+}
+
+func TestPauseResumeRead(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testPauseResumeRead(t, "tcp", ":9929", false)
+	})
+	t.Run("tcp-edge-triggered", func(t *testing.T) {
+		testPauseResumeRead(t, "tcp", ":9930", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testPauseResumeRead(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testPauseResumeReadServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	conn          chan Conn
+	traffic       atomic.Int32
+	received      []byte
+	started       bool
+}
+
+func (s *testPauseResumeReadServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testPauseResumeReadServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testPauseResumeReadServer) OnOpen(c Conn) (out []byte, action Action) {
+	s.conn <- c
+	return
+}
+
+func (s *testPauseResumeReadServer) OnTraffic(c Conn) (action Action) {
+	if s.traffic.Add(1) == 1 {
+		// Consume a part of the data and leave the rest in the inbound buffer.
+		buf, err := c.Next(5)
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, "hello", string(buf))
+		assert.NoError(s.tester, c.PauseRead())
+		return
+	}
+	buf, _ := c.Next(-1)
+	s.received = append(s.received, buf...)
+	if string(s.received) == " gnet!!" {
+		_, err := c.Write(s.received)
+		assert.NoError(s.tester, err)
+	}
+	return
+}
+
+func (s *testPauseResumeReadServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	sc := <-s.conn
+
+	_, err = c.Write([]byte("hello gnet"))
+	assert.NoError(s.tester, err)
+	assert.Eventually(s.tester, func() bool { return s.traffic.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Reading from the connection is paused, the data must not be seen by the server.
+	time.Sleep(50 * time.Millisecond)
+	_, err = c.Write([]byte("!!"))
+	assert.NoError(s.tester, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.EqualValues(s.tester, 1, s.traffic.Load())
+
+	assert.NoError(s.tester, sc.ResumeRead())
+	buf := make([]byte, len(" gnet!!"))
+	_, err = io.ReadFull(c, buf)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, " gnet!!", string(buf))
+}
+
+func testPauseResumeRead(t *testing.T, network, addr string, et bool) {
+	svr := &testPauseResumeReadServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		conn:               make(chan Conn, 1),
+	}
+	err := Run(svr, network+"://"+addr, WithTicker(true), WithReuseAddr(true), WithEdgeTriggeredIO(et))
+	assert.NoError(t, err)
+}
+
+func TestHalfClose(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testHalfClose(t, "tcp", ":9931", false)
+	})
+	t.Run("tcp-edge-triggered", func(t *testing.T) {
+		testHalfClose(t, "tcp", ":9932", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testHalfClose(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testHalfCloseServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	request       []byte
+	closed        chan error
+	started       bool
+}
+
+func (s *testHalfCloseServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testHalfCloseServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testHalfCloseServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	s.request = append(s.request, buf...)
+	return
+}
+
+func (s *testHalfCloseServer) OnReadClosed(c Conn) (action Action) {
+	// Respond to the request after the remote finishes sending it.
+	_, err := c.Write(append([]byte("echo: "), s.request...))
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, c.CloseWrite())
+	return
+}
+
+func (s *testHalfCloseServer) OnClose(_ Conn, err error) (action Action) {
+	s.closed <- err
+	return
+}
+
+func (s *testHalfCloseServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := bytes.Repeat([]byte("hello gnet "), 1024)
+	_, err = c.Write(request)
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, c.(interface{ CloseWrite() error }).CloseWrite())
+
+	resp, err := io.ReadAll(c)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "echo: "+string(request), string(resp))
+
+	select {
+	case err = <-s.closed:
+		assert.NoError(s.tester, err)
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for OnClose")
+	}
+}
+
+func testHalfClose(t *testing.T, network, addr string, et bool) {
+	svr := &testHalfCloseServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		closed:             make(chan error, 1),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithEdgeTriggeredIO(et),
+		WithHalfClose(true))
+	assert.NoError(t, err)
+}
+
+func TestSendFile(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testSendFile(t, "tcp", ":9933", false)
+	})
+	t.Run("tcp-edge-triggered", func(t *testing.T) {
+		testSendFile(t, "tcp", ":9934", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testSendFile(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testSendFileServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	file          *os.File
+	data          []byte
+	sent          chan error
+	started       bool
+}
+
+func (s *testSendFileServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testSendFileServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testSendFileServer) OnTraffic(c Conn) (action Action) {
+	_, _ = c.Discard(-1)
+	// The file must be sent between the data written before it and the asynchronous write after it.
+	_, err := c.Write([]byte("head|"))
+	assert.NoError(s.tester, err)
+	err = c.SendFile(s.file, 10, int64(len(s.data)-10), func(_ Conn, err error) error {
+		s.sent <- err
+		return nil
+	})
+	assert.NoError(s.tester, err)
+	err = c.AsyncWrite([]byte("|tail"), nil)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testSendFileServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	_, err = c.Write([]byte("GET"))
+	assert.NoError(s.tester, err)
+	expected := append(append([]byte("head|"), s.data[10:]...), "|tail"...)
+	resp := make([]byte, len(expected))
+	_, err = io.ReadFull(c, resp)
+	assert.NoError(s.tester, err)
+	assert.True(s.tester, bytes.Equal(expected, resp), "response mismatched")
+
+	select {
+	case err = <-s.sent:
+		assert.NoError(s.tester, err)
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for the callback of SendFile")
+	}
+}
+
+func testSendFile(t *testing.T, network, addr string, et bool) {
+	data := make([]byte, 10<<20)
+	_, err := crand.Read(data)
+	assert.NoError(t, err)
+	f, err := os.CreateTemp(t.TempDir(), "gnet-sendfile")
+	assert.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(data)
+	assert.NoError(t, err)
+
+	svr := &testSendFileServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		file:               f,
+		data:               data,
+		sent:               make(chan error, 1),
+	}
+	err = Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithEdgeTriggeredIO(et))
+	assert.NoError(t, err)
+}
+
+func TestScheduleTimerCanceled(t *testing.T) {
+	svr := &testScheduleTimerCanceledServer{
+		tester: t,
+		addr:   ":9974",
+		timers: make(chan []Timer, 1),
+	}
+	err := Run(svr, "tcp://"+svr.addr, WithReuseAddr(true))
+	assert.NoError(t, err)
+}
+
+type testScheduleTimerCanceledServer struct {
+	BuiltinEventEngine
+	tester  *testing.T
+	addr    string
+	cancels []context.CancelFunc
+	timers  chan []Timer
+}
+
+func (s *testScheduleTimerCanceledServer) OnBoot(Engine) (action Action) {
+	err := goPool.DefaultWorkerPool.Submit(func() {
+		c, err := net.Dial("tcp", s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		defer c.Close() //nolint:errcheck
+
+		// The timers are removed from the poller once their ctx is canceled, long before they expire.
+		timers := <-s.timers
+		for _, cancel := range s.cancels {
+			cancel()
+		}
+		assert.Eventually(s.tester, func() bool {
+			for _, timer := range timers {
+				if !timer.(*netpoll.Timer).Stopped() {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testScheduleTimerCanceledServer) OnOpen(c Conn) (out []byte, action Action) {
+	// Timers with a ctx that can be done don't cost a goroutine each.
+	n := runtime.NumGoroutine()
+	timers := make([]Timer, 0, 1000)
+	for i := 0; i < cap(timers); i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancels = append(s.cancels, cancel)
+		timer, err := c.EventLoop().ScheduleTimer(ctx, RunnableFunc(func(context.Context) error {
+			s.tester.Error("runnable with canceled context should not run")
+			return nil
+		}), time.Hour, 0)
+		if !assert.NoError(s.tester, err) {
+			break
+		}
+		timers = append(timers, timer)
+	}
+	assert.LessOrEqual(s.tester, runtime.NumGoroutine(), n+10)
+	s.timers <- timers
+	return
+}
+
+func (s *testScheduleTimerCanceledServer) OnClose(Conn, error) (action Action) {
+	return Shutdown
+}
+
+func TestSplice(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testSplice(t, "tcp", ":9935", false)
+	})
+	t.Run("tcp-edge-triggered", func(t *testing.T) {
+		testSplice(t, "tcp", ":9936", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testSplice(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testSpliceServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	pending       Conn
+	started       bool
+}
+
+func (s *testSpliceServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testSpliceServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testSpliceServer) OnOpen(c Conn) (out []byte, action Action) {
+	if s.pending == nil {
+		s.pending = c
+		return
+	}
+	// Pair up the connections and move the data that arrived before the pairing.
+	peer := s.pending
+	s.pending = nil
+	c.SetContext(peer)
+	peer.SetContext(c)
+	_, err := peer.Splice(c, -1)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testSpliceServer) OnTraffic(c Conn) (action Action) {
+	peer, ok := c.Context().(Conn)
+	if !ok {
+		return // wait for the peer
+	}
+	_, err := c.Splice(peer, -1)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testSpliceServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	src, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer src.Close()
+	data := make([]byte, 4<<20)
+	_, err = crand.Read(data)
+	assert.NoError(s.tester, err)
+	_, err = src.Write(data[:1024])
+	assert.NoError(s.tester, err)
+
+	dst, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer dst.Close()
+	_ = dst.SetDeadline(time.Now().Add(10 * time.Second))
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := src.Write(data[1024:])
+		errCh <- err
+	}()
+	resp := make([]byte, len(data))
+	_, err = io.ReadFull(dst, resp)
+	assert.NoError(s.tester, err)
+	assert.True(s.tester, bytes.Equal(data, resp), "spliced data mismatched")
+	assert.NoError(s.tester, <-errCh)
+
+	// Splice the data in the opposite direction.
+	_, err = dst.Write(data[:4096])
+	assert.NoError(s.tester, err)
+	_ = src.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err = io.ReadFull(src, resp[:4096])
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, data[:4096], resp[:4096])
+}
+
+func testSplice(t *testing.T, network, addr string, et bool) {
+	svr := &testSpliceServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithEdgeTriggeredIO(et))
+	assert.NoError(t, err)
+}
+
+func TestSpliceClosedDst(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testSpliceClosedDst(t, "tcp", ":9973")
+	})
+	t.Run("unix", func(t *testing.T) {
+		testSpliceClosedDst(t, "unix", testUnixAddr(t))
+	})
+}
+
+type testSpliceClosedDstServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	data          []byte
+	conns         []Conn
+	started       bool
+}
+
+func (s *testSpliceClosedDstServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testSpliceClosedDstServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testSpliceClosedDstServer) OnOpen(c Conn) (out []byte, action Action) {
+	s.conns = append(s.conns, c)
+	switch len(s.conns) {
+	case 1:
+		// Leave the data in the socket of src.
+		assert.NoError(s.tester, c.(*conn).toggleRead(true))
+	case 3:
+		src, dst1, dst2 := s.conns[0], s.conns[1], s.conns[2]
+
+		// dst fails while the data is being spliced from the socket of src.
+		assert.NoError(s.tester, unix.Shutdown(dst1.(*conn).fd, unix.SHUT_WR))
+		n, err := src.Splice(dst1, -1)
+		assert.ErrorIs(s.tester, err, net.ErrClosed)
+		assert.Zero(s.tester, n)
+		buffered := src.InboundBuffered()
+		assert.Positive(s.tester, buffered)
+		buf, err := src.Peek(-1)
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, s.data[:buffered], buf)
+
+		// dst fails while the data in the inbound buffer of src is being moved.
+		assert.NoError(s.tester, unix.Shutdown(dst2.(*conn).fd, unix.SHUT_WR))
+		n, err = src.Splice(dst2, -1)
+		assert.ErrorIs(s.tester, err, net.ErrClosed)
+		assert.Zero(s.tester, n)
+		assert.Equal(s.tester, buffered, src.InboundBuffered())
+	}
+	return
+}
+
+func (s *testSpliceClosedDstServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	src, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer src.Close()
+	_, err = src.Write(s.data)
+	assert.NoError(s.tester, err)
+
+	dst1, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer dst1.Close()
+	dst2, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer dst2.Close()
+
+	// Both dst connections are closed by the server once the checks are done.
+	for _, dst := range []net.Conn{dst1, dst2} {
+		_ = dst.SetDeadline(time.Now().Add(10 * time.Second))
+		n, err := dst.Read(make([]byte, 1))
+		assert.Zero(s.tester, n)
+		assert.ErrorIs(s.tester, err, io.EOF)
+	}
+}
+
+func testSpliceClosedDst(t *testing.T, network, addr string) {
+	svr := &testSpliceClosedDstServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		data:               make([]byte, 8192),
+	}
+	_, err := crand.Read(svr.data)
+	assert.NoError(t, err)
+	err = Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+}
+
+func TestZeroCopyWrite(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testZeroCopyWrite(t, "tcp", ":9937", false)
+	})
+	t.Run("tcp-edge-triggered", func(t *testing.T) {
+		testZeroCopyWrite(t, "tcp", ":9938", true)
+	})
+}
+
+type testZeroCopyWriteServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	data          [][]byte
+	completed     chan error
+	started       bool
+}
+
+func (s *testZeroCopyWriteServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testZeroCopyWriteServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testZeroCopyWriteServer) OnOpen(c Conn) (out []byte, action Action) {
+	if runtime.GOOS == "linux" {
+		assert.True(s.tester, c.(*conn).zeroCopy, "SO_ZEROCOPY is not enabled")
+	}
+	return
+}
+
+func (s *testZeroCopyWriteServer) OnTraffic(c Conn) (action Action) {
+	_, _ = c.Discard(-1)
+	for _, data := range s.data {
+		err := c.AsyncWrite(data, func(_ Conn, err error) error {
+			s.completed <- err
+			return nil
+		})
+		assert.NoError(s.tester, err)
+	}
+	return
+}
+
+func (s *testZeroCopyWriteServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	_, err = c.Write([]byte("GET"))
+	assert.NoError(s.tester, err)
+	for _, data := range s.data {
+		resp := make([]byte, len(data))
+		_, err = io.ReadFull(c, resp)
+		assert.NoError(s.tester, err)
+		assert.True(s.tester, bytes.Equal(data, resp), "response mismatched")
+	}
+
+	for range s.data {
+		select {
+		case err = <-s.completed:
+			assert.NoError(s.tester, err)
+		case <-time.After(5 * time.Second):
+			s.tester.Error("timeout waiting for the callback of AsyncWrite")
+			return
+		}
+	}
+}
+
+func testZeroCopyWrite(t *testing.T, network, addr string, et bool) {
+	svr := &testZeroCopyWriteServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		completed:          make(chan error, 16),
+	}
+	// The small data is written as usual and the large data is written with MSG_ZEROCOPY.
+	for _, size := range []int{1 << 10, 1 << 20, 64 << 10, 4 << 20, 1 << 20, 512, 2 << 20, 8 << 10} {
+		data := make([]byte, size)
+		_, err := crand.Read(data)
+		assert.NoError(t, err)
+		svr.data = append(svr.data, data)
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithEdgeTriggeredIO(et),
+		WithZeroCopyWriteThreshold(16<<10))
+	assert.NoError(t, err)
+}
+
+func TestUDPBatch(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		testUDPBatch(t, "udp", ":9939", 16)
+	})
+	t.Run("udp-unbatched", func(t *testing.T) {
+		testUDPBatch(t, "udp", ":9940", 0)
+	})
+}
+
+type testUDPBatchServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	started       bool
+}
+
+func (s *testUDPBatchServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUDPBatchServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUDPBatchServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	// Echo the datagram back twice with a single call.
+	n, err := c.SendToBatch([][]byte{buf, buf}, []net.Addr{c.RemoteAddr(), c.RemoteAddr()})
+	assert.NoError(s.tester, err)
+	assert.EqualValues(s.tester, 2, n)
+	return
+}
+
+func (s *testUDPBatchServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	const count = 64
+	for i := 0; i < count; i++ {
+		_, err = c.Write([]byte("datagram-" + strconv.Itoa(i)))
+		assert.NoError(s.tester, err)
+	}
+	received := make(map[string]int)
+	buf := make([]byte, 1024)
+	for i := 0; i < 2*count; i++ {
+		n, err := c.Read(buf)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		received[string(buf[:n])]++
+	}
+	assert.Len(s.tester, received, count)
+	for i := 0; i < count; i++ {
+		assert.EqualValues(s.tester, 2, received["datagram-"+strconv.Itoa(i)])
+	}
+}
+
+func testUDPBatch(t *testing.T, network, addr string, batch int) {
+	svr := &testUDPBatchServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithUDPBatchSize(batch))
+	assert.NoError(t, err)
+}
+
+func TestUDPSegments(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		testUDPSegments(t, "udp", ":9941", false)
+	})
+	t.Run("udp-offload", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("UDP_GRO and UDP_SEGMENT are only available on Linux")
+		}
+		testUDPSegments(t, "udp", ":9942", true)
+	})
+	t.Run("udp-gro-small-buffer", func(t *testing.T) {
+		err := Run(&BuiltinEventEngine{}, "udp://:9942",
+			WithReuseAddr(true),
+			WithUDPGRO(true),
+			WithReadBufferCap(16*1024))
+		assert.ErrorIs(t, err, errorx.ErrTooSmallReadBuffer)
+	})
+}
+
+type testUDPSegmentsServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	offload       bool
+	received      atomic.Int32
+	started       bool
+}
+
+func (s *testUDPSegmentsServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUDPSegmentsServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUDPSegmentsServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	s.received.Add(1)
+	// Echo the datagram back in quarters.
+	n, err := c.SendSegments(buf, len(buf)/4, c.RemoteAddr())
+	assert.NoError(s.tester, err)
+	assert.EqualValues(s.tester, len(buf), n)
+	return
+}
+
+func (s *testUDPSegmentsServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	const (
+		count   = 10
+		segment = 1000
+	)
+	data := make([]byte, count*segment)
+	_, err = crand.Read(data)
+	assert.NoError(s.tester, err)
+	if s.offload {
+		// Send all datagrams at once with UDP_SEGMENT to get them coalesced by UDP_GRO,
+		// the constants are spelled out since they're only defined on Linux.
+		const solUDP, udpSegment = 0x11, 0x67 // SOL_UDP, UDP_SEGMENT
+		oob := make([]byte, unix.CmsgSpace(2))
+		h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+		h.Level = solUDP
+		h.Type = udpSegment
+		h.SetLen(unix.CmsgLen(2))
+		*(*uint16)(unsafe.Pointer(&oob[unix.CmsgLen(0)])) = segment
+		_, _, err = c.(*net.UDPConn).WriteMsgUDP(data, oob, nil)
+		assert.NoError(s.tester, err)
+	} else {
+		for i := 0; i < count; i++ {
+			_, err = c.Write(data[i*segment : (i+1)*segment])
+			assert.NoError(s.tester, err)
+		}
+	}
+
+	buf := make([]byte, 2*segment)
+	resp := make([]byte, 0, len(data))
+	for i := 0; i < 4*count; i++ {
+		n, err := c.Read(buf)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		assert.EqualValues(s.tester, segment/4, n)
+		resp = append(resp, buf[:n]...)
+	}
+	assert.True(s.tester, bytes.Equal(data, resp), "echoed data mismatched")
+	assert.EqualValues(s.tester, count, s.received.Load())
+}
+
+func testUDPSegments(t *testing.T, network, addr string, offload bool) {
+	svr := &testUDPSegmentsServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		offload:            offload,
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithUDPGRO(offload),
+		WithUDPGSO(offload))
+	assert.NoError(t, err)
+}
+
+func TestUDPSession(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		testUDPSession(t, "udp", ":9943", false)
+	})
+	t.Run("udp-multicore", func(t *testing.T) {
+		testUDPSession(t, "udp", ":9944", true)
+	})
+	t.Run("udp-limit-drain", func(t *testing.T) {
+		testUDPSessionLimit(t, "udp", ":9965")
+	})
+	t.Run("udp-open-failure", func(t *testing.T) {
+		testUDPSessionOpenFailure(t, "udp", ":9976")
+	})
+}
+
+type testUDPSessionServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	opened        atomic.Int32
+	closed        chan error
+	started       bool
+}
+
+func (s *testUDPSessionServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUDPSessionServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUDPSessionServer) OnOpen(c Conn) (out []byte, action Action) {
+	s.opened.Add(1)
+	c.SetContext(new(int))
+	return []byte("hello"), None
+}
+
+func (s *testUDPSessionServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	if string(buf) == "bye" {
+		return Close
+	}
+	count := c.Context().(*int)
+	*count++
+	_, err := c.Write([]byte(strconv.Itoa(*count)))
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testUDPSessionServer) OnClose(_ Conn, err error) (action Action) {
+	s.closed <- err
+	return
+}
+
+func (s *testUDPSessionServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	exchange := func(c net.Conn, msg, expected string) {
+		_, err := c.Write([]byte(msg))
+		assert.NoError(s.tester, err)
+		buf := make([]byte, 64)
+		n, err := c.Read(buf)
+		if assert.NoError(s.tester, err) {
+			assert.Equal(s.tester, expected, string(buf[:n]))
+		}
+	}
+
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial(s.network, s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		defer c.Close()
+		_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+		clients = append(clients, c)
+	}
+
+	// Each remote gets its own session with the context persisting across datagrams.
+	for i, c := range clients {
+		exchange(c, "ping", "hello")
+		buf := make([]byte, 64)
+		n, err := c.Read(buf)
+		if assert.NoError(s.tester, err) {
+			assert.Equal(s.tester, "1", string(buf[:n]))
+		}
+		for j := 2; j <= 3+i; j++ {
+			exchange(c, "ping", strconv.Itoa(j))
+		}
+	}
+	assert.EqualValues(s.tester, 2, s.opened.Load())
+	assert.Equal(s.tester, 2, s.eng.CountConnections())
+
+	// Sessions are closed after being idle for a while.
+	for range clients {
+		select {
+		case err := <-s.closed:
+			assert.ErrorIs(s.tester, err, errorx.ErrIdleTimeout)
+		case <-time.After(5 * time.Second):
+			s.tester.Error("UDP session wasn't closed after being idle")
+			return
+		}
+	}
+	assert.Zero(s.tester, s.eng.CountConnections())
+
+	// A new session is opened on the next datagram, and it can be closed by OnTraffic.
+	exchange(clients[0], "ping", "hello")
+	buf := make([]byte, 64)
+	n, err := clients[0].Read(buf)
+	if assert.NoError(s.tester, err) {
+		assert.Equal(s.tester, "1", string(buf[:n]))
+	}
+	assert.EqualValues(s.tester, 3, s.opened.Load())
+	_, err = clients[0].Write([]byte("bye"))
+	assert.NoError(s.tester, err)
+	select {
+	case err := <-s.closed:
+		assert.NoError(s.tester, err)
+	case <-time.After(5 * time.Second):
+		s.tester.Error("UDP session wasn't closed by OnTraffic")
+	}
+}
+
+func testUDPSession(t *testing.T, network, addr string, multicore bool) {
+	svr := &testUDPSessionServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		closed:             make(chan error, 4),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithMulticore(multicore),
+		WithUDPSessionTimeout(200*time.Millisecond))
+	assert.NoError(t, err)
+}
+
+type testUDPSessionLimitServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	drained       atomic.Int32
+	started       bool
+}
+
+func (s *testUDPSessionLimitServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUDPSessionLimitServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUDPSessionLimitServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testUDPSessionLimitServer) OnDraining(Conn) (action Action) {
+	s.drained.Add(1)
+	return Close
+}
+
+func (s *testUDPSessionLimitServer) runClients() {
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial(s.network, s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		defer c.Close()
+		clients = append(clients, c)
+	}
+
+	// The first remote gets a session, the datagrams of the second one are dropped.
+	_ = clients[0].SetDeadline(time.Now().Add(5 * time.Second))
+	_, err := clients[0].Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	buf := make([]byte, 64)
+	n, err := clients[0].Read(buf)
+	if assert.NoError(s.tester, err) {
+		assert.Equal(s.tester, "ping", string(buf[:n]))
+	}
+	_ = clients[1].SetDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err = clients[1].Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	_, err = clients[1].Read(buf)
+	assert.ErrorIs(s.tester, err, os.ErrDeadlineExceeded)
+	assert.Equal(s.tester, 1, s.eng.CountConnections())
+
+	// The session is notified with OnDraining and waited for as a connection.
+	start := time.Now()
+	assert.NoError(s.tester, s.eng.Drain(context.Background(), 5*time.Second))
+	assert.Less(s.tester, time.Since(start), 5*time.Second)
+	assert.EqualValues(s.tester, 1, s.drained.Load())
+}
+
+func testUDPSessionLimit(t *testing.T, network, addr string) {
+	svr := &testUDPSessionLimitServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithUDPSessionTimeout(time.Minute),
+		WithMaxUDPSessions(1))
+	assert.NoError(t, err)
+}
+
+type testUDPSessionOpenFailureServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	opened        atomic.Int32
+	closed        chan error
+	started       bool
+}
+
+func (s *testUDPSessionOpenFailureServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUDPSessionOpenFailureServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUDPSessionOpenFailureServer) OnOpen(Conn) (out []byte, action Action) {
+	if s.opened.Add(1) == 1 {
+		return make([]byte, 70000), None // too large for a datagram
+	}
+	return
+}
+
+func (s *testUDPSessionOpenFailureServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testUDPSessionOpenFailureServer) OnClose(_ Conn, err error) (action Action) {
+	s.closed <- err
+	return
+}
+
+func (s *testUDPSessionOpenFailureServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial(s.network, s.addr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		defer c.Close()
+		_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+		clients = append(clients, c)
+	}
+
+	// The session that fails to open is closed and it doesn't count.
+	_, err := clients[0].Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	select {
+	case err := <-s.closed:
+		assert.ErrorIs(s.tester, err, unix.EMSGSIZE)
+	case <-time.After(5 * time.Second):
+		s.tester.Error("UDP session wasn't closed after failing to open")
+		return
+	}
+	assert.Zero(s.tester, s.eng.CountConnections())
+
+	// So another remote still gets a session under the limit.
+	_, err = clients[1].Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	buf := make([]byte, 64)
+	n, err := clients[1].Read(buf)
+	if assert.NoError(s.tester, err) {
+		assert.Equal(s.tester, "ping", string(buf[:n]))
+	}
+	assert.Equal(s.tester, 1, s.eng.CountConnections())
+}
+
+func testUDPSessionOpenFailure(t *testing.T, network, addr string) {
+	svr := &testUDPSessionOpenFailureServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		closed:             make(chan error, 2),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithUDPSessionTimeout(time.Minute),
+		WithMaxUDPSessions(1))
+	assert.NoError(t, err)
+}
+
+func TestUDPPacketInfo(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the ancillary data of datagrams is only available on Linux")
+	}
+	t.Run("udp", func(t *testing.T) {
+		testUDPPacketInfo(t, "udp", ":9945", 0)
+	})
+	t.Run("udp4-batch", func(t *testing.T) {
+		testUDPPacketInfo(t, "udp4", "0.0.0.0:9946", 8)
+	})
+}
+
+type testUDPPacketInfoServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	port          int
+	started       bool
+}
+
+func (s *testUDPPacketInfoServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUDPPacketInfoServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUDPPacketInfoServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	info := c.PacketInfo()
+	assert.True(s.tester, info.LocalIP.Equal(net.IPv4(127, 0, 0, 1)), "local IP: %v", info.LocalIP)
+	assert.Positive(s.tester, info.IfIndex)
+	assert.EqualValues(s.tester, 0x28, info.TOS)
+	assert.WithinDuration(s.tester, time.Now(), info.Timestamp, 5*time.Second)
+	// Reply from another address of the loopback interface.
+	_, err := c.SendMsg(buf, nil, &PacketInfo{LocalIP: net.IPv4(127, 0, 0, 2), TOS: 0x48})
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testUDPPacketInfoServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	const ipTOS, ipRecvTOS = 0x1, 0xd // IP_TOS and IP_RECVTOS on Linux
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	rc, err := c.SyscallConn()
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	err = rc.Control(func(fd uintptr) {
+		assert.NoError(s.tester, unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, ipTOS, 0x28))
+		assert.NoError(s.tester, unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, ipRecvTOS, 1))
+	})
+	assert.NoError(s.tester, err)
+
+	dst := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: s.port}
+	buf, oob := make([]byte, 64), make([]byte, 64)
+	for i := 0; i < 3; i++ {
+		msg := "datagram-" + strconv.Itoa(i)
+		_, err = c.WriteToUDP([]byte(msg), dst)
+		assert.NoError(s.tester, err)
+		n, oobn, _, from, err := c.ReadMsgUDP(buf, oob)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		assert.Equal(s.tester, msg, string(buf[:n]))
+		assert.True(s.tester, from.IP.Equal(net.IPv4(127, 0, 0, 2)), "source IP: %v", from.IP)
+		assert.EqualValues(s.tester, dst.Port, from.Port)
+		cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if assert.NoError(s.tester, err) && assert.Len(s.tester, cmsgs, 1) {
+			assert.EqualValues(s.tester, ipTOS, cmsgs[0].Header.Type)
+			assert.EqualValues(s.tester, 0x48, cmsgs[0].Data[0])
+		}
+	}
+}
+
+func testUDPPacketInfo(t *testing.T, network, addr string, batch int) {
+	_, port, err := net.SplitHostPort(addr)
+	assert.NoError(t, err)
+	svr := &testUDPPacketInfoServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+	}
+	svr.port, err = strconv.Atoi(port)
+	assert.NoError(t, err)
+	err = Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithUDPBatchSize(batch),
+		WithUDPPacketInfo(true))
+	assert.NoError(t, err)
+}
+
+func TestUnixRights(t *testing.T) {
+	t.Run("unix", func(t *testing.T) {
+		testUnixRights(t, "unix", testUnixAddr(t), false)
+	})
+	t.Run("unix-edge-triggered", func(t *testing.T) {
+		testUnixRights(t, "unix", testUnixAddr(t), true)
+	})
+}
+
+type testUnixRightsServer struct {
+	*BuiltinEventEngine
+	tester        *testing.T
+	eng           Engine
+	network, addr string
+	data          []byte
+	started       bool
+}
+
+func (s *testUnixRightsServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUnixRightsServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUnixRightsServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	assert.Equal(s.tester, "fd", string(buf))
+	fds := c.ReceivedFDs()
+	if !assert.Len(s.tester, fds, 1) {
+		return Close
+	}
+	assert.Nil(s.tester, c.ReceivedFDs())
+	f := os.NewFile(uintptr(fds[0]), "pipe")
+	content, err := io.ReadAll(f)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "from client", string(content))
+	assert.NoError(s.tester, f.Close())
+
+	cred, err := c.PeerCredentials()
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd":
+		if assert.NoError(s.tester, err) {
+			if runtime.GOOS != "freebsd" {
+				assert.EqualValues(s.tester, os.Getpid(), cred.PID)
+			}
+			assert.EqualValues(s.tester, os.Getuid(), cred.UID)
+			assert.EqualValues(s.tester, os.Getgid(), cred.GID)
+		}
+	default:
+		assert.ErrorIs(s.tester, err, errorx.ErrUnsupportedOp)
+	}
+
+	// Pass a pipe behind a large amount of pending data.
+	r, w, err := os.Pipe()
+	if !assert.NoError(s.tester, err) {
+		return Close
+	}
+	_, err = w.WriteString("from server")
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, w.Close())
+	_, err = c.Write(s.data)
+	assert.NoError(s.tester, err)
+	n, err := c.SendFDs([]int{int(r.Fd())}, []byte("fd"))
+	assert.NoError(s.tester, err)
+	assert.EqualValues(s.tester, 2, n)
+	assert.NoError(s.tester, r.Close())
+	_, err = c.Write([]byte("tail"))
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testUnixRightsServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial(s.network, s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	uc := c.(*net.UnixConn)
+	_ = uc.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r, w, err := os.Pipe()
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	_, err = w.WriteString("from client")
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, w.Close())
+	_, _, err = uc.WriteMsgUnix([]byte("fd"), unix.UnixRights(int(r.Fd())), nil)
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, r.Close())
+
+	expected := append(append(append([]byte(nil), s.data...), "fd"...), "tail"...)
+	received := make([]byte, 0, len(expected))
+	buf, oob := make([]byte, 64*1024), make([]byte, unix.CmsgSpace(4*4))
+	var fds []int
+	for len(received) < len(expected) {
+		n, oobn, _, _, err := uc.ReadMsgUnix(buf, oob)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		if oobn > 0 {
+			// The file descriptor must come along with the data that carries it.
+			assert.LessOrEqual(s.tester, len(received), len(s.data))
+			assert.Greater(s.tester, len(received)+n, len(s.data))
+			cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+			if assert.NoError(s.tester, err) && assert.Len(s.tester, cmsgs, 1) {
+				rights, err := unix.ParseUnixRights(&cmsgs[0])
+				assert.NoError(s.tester, err)
+				fds = append(fds, rights...)
+			}
+		}
+		received = append(received, buf[:n]...)
+	}
+	assert.Equal(s.tester, expected, received)
+	if !assert.Len(s.tester, fds, 1) {
+		return
+	}
+	f := os.NewFile(uintptr(fds[0]), "pipe")
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "from server", string(content))
+}
+
+func testUnixRights(t *testing.T, network, addr string, et bool) {
+	svr := &testUnixRightsServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		network:            network,
+		addr:               addr,
+		data:               bytes.Repeat([]byte("x"), 4<<20),
+	}
+	err := Run(svr, network+"://"+addr,
+		WithTicker(true),
+		WithEdgeTriggeredIO(et),
+		WithUnixRights(true))
+	assert.NoError(t, err)
+}
+
+func TestUnixgram(t *testing.T) {
+	t.Run("unixgram", func(t *testing.T) {
+		testUnixgram(t, false)
+	})
+	t.Run("unixgram-multicore", func(t *testing.T) {
+		testUnixgram(t, true)
+	})
+}
+
+type testUnixgramServer struct {
+	*BuiltinEventEngine
+	tester     *testing.T
+	eng        Engine
+	addr       string
+	clientAddr string
+	dialAddr   string
+	started    bool
+}
+
+func (s *testUnixgramServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUnixgramServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUnixgramServer) OnTraffic(c Conn) (action Action) {
+	assert.Equal(s.tester, "unixgram", c.LocalAddr().Network())
+	addr, ok := c.RemoteAddr().(*net.UnixAddr)
+	if assert.True(s.tester, ok) {
+		assert.Equal(s.tester, "unixgram", addr.Net)
+		assert.Contains(s.tester, []string{s.clientAddr, s.dialAddr}, addr.Name)
+	}
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+type testUnixgramClient struct {
+	*BuiltinEventEngine
+	ch chan []byte
+}
+
+func (ev *testUnixgramClient) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	ev.ch <- append([]byte(nil), buf...)
+	return
+}
+
+func (s *testUnixgramServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	raddr := &net.UnixAddr{Name: s.addr, Net: "unixgram"}
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: s.clientAddr, Net: "unixgram"})
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer pc.Close()
+	_ = pc.SetDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, 1024)
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("datagram-%d", i))
+		_, err = pc.WriteToUnix(msg, raddr)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		n, from, err := pc.ReadFromUnix(buf)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		assert.Equal(s.tester, msg, buf[:n])
+		assert.Equal(s.tester, s.addr, from.Name)
+	}
+
+	// Enroll a connected unixgram socket with a client.
+	ev := &testUnixgramClient{BuiltinEventEngine: &BuiltinEventEngine{}, ch: make(chan []byte, 1)}
+	cli, err := NewClient(ev)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	assert.NoError(s.tester, cli.Start())
+	defer cli.Stop() //nolint:errcheck
+	uc, err := net.DialUnix("unixgram", &net.UnixAddr{Name: s.dialAddr, Net: "unixgram"}, raddr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer uc.Close()
+	c, err := cli.Enroll(uc)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	assert.Equal(s.tester, "unixgram", c.RemoteAddr().Network())
+	assert.NoError(s.tester, c.AsyncWrite([]byte("enrolled"), nil))
+	select {
+	case data := <-ev.ch:
+		assert.Equal(s.tester, "enrolled", string(data))
+	case <-time.After(10 * time.Second):
+		assert.Fail(s.tester, "timeout waiting for the echo")
+	}
+}
+
+func testUnixgram(t *testing.T, multicore bool) {
+	svr := &testUnixgramServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               testUnixAddr(t),
+		clientAddr:         testUnixAddr(t),
+		dialAddr:           testUnixAddr(t),
+	}
+	err := Run(svr, "unixgram://"+svr.addr,
+		WithTicker(true),
+		WithMulticore(multicore))
+	assert.NoError(t, err)
+}
+
+func TestUnixpacket(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("unixpacket is not supported on darwin")
+	}
+	t.Run("unixpacket", func(t *testing.T) {
+		testUnixpacket(t, false)
+	})
+	t.Run("unixpacket-edge-triggered", func(t *testing.T) {
+		testUnixpacket(t, true)
+	})
+	t.Run("unixpacket-partial-consume", func(t *testing.T) {
+		testUnixpacketLimits(t, false)
+	})
+	t.Run("unixpacket-truncated", func(t *testing.T) {
+		testUnixpacketLimits(t, true)
+	})
+}
+
+type testUnixpacketServer struct {
+	*BuiltinEventEngine
+	tester   *testing.T
+	eng      Engine
+	addr     string
+	messages [][]byte
+	received int
+	started  bool
+}
+
+func (s *testUnixpacketServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUnixpacketServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUnixpacketServer) OnOpen(c Conn) (out []byte, action Action) {
+	assert.Equal(s.tester, "unixpacket", c.LocalAddr().Network())
+	assert.Equal(s.tester, "unixpacket", c.RemoteAddr().Network())
+	assert.ErrorIs(s.tester, c.SendFile(nil, 0, 0, nil), errorx.ErrUnsupportedOp)
+	return
+}
+
+func (s *testUnixpacketServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	if !assert.Less(s.tester, s.received, len(s.messages)) {
+		return Close
+	}
+	// Each message is handed over on its own.
+	assert.Equal(s.tester, s.messages[s.received], buf)
+	var err error
+	if s.received%2 == 0 {
+		_, err = c.Write(buf)
+	} else {
+		_, err = c.Writev([][]byte{buf[:len(buf)/2], buf[len(buf)/2:]})
+	}
+	assert.NoError(s.tester, err)
+	s.received++
+	return
+}
+
+func (s *testUnixpacketServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial("unixpacket", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// Send all messages before reading any echoes so that the echoes are queued up on the server.
+	for _, msg := range s.messages {
+		_, err = c.Write(msg)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+	}
+	buf := make([]byte, 64*1024)
+	for _, msg := range s.messages {
+		n, err := c.Read(buf)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		assert.Equal(s.tester, msg, buf[:n])
+	}
+}
+
+func testUnixpacket(t *testing.T, et bool) {
+	svr := &testUnixpacketServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               testUnixAddr(t),
+	}
+	for i := 0; i < 20; i++ {
+		svr.messages = append(svr.messages, bytes.Repeat([]byte{byte('a' + i)}, 1+i*3001))
+	}
+	err := Run(svr, "unixpacket://"+svr.addr,
+		WithTicker(true),
+		WithEdgeTriggeredIO(et))
+	assert.NoError(t, err)
+	assert.Equal(t, len(svr.messages), svr.received)
+}
+
+type testUnixpacketLimitsServer struct {
+	*BuiltinEventEngine
+	tester   *testing.T
+	eng      Engine
+	addr     string
+	truncate bool
+	traffic  int
+	closeErr error
+	closed   atomic.Bool
+	started  bool
+}
+
+func (s *testUnixpacketLimitsServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testUnixpacketLimitsServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClient)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testUnixpacketLimitsServer) OnTraffic(c Conn) (action Action) {
+	s.traffic++
+	if s.traffic == 1 {
+		// Leave the rest of the first message behind, it gets merged with the next one.
+		buf, err := c.Next(2)
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, []byte("ab"), buf)
+		_, err = c.Write([]byte("ok"))
+		assert.NoError(s.tester, err)
+		return
+	}
+	buf, _ := c.Next(-1)
+	assert.Equal(s.tester, []byte("cdefgh"), buf)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testUnixpacketLimitsServer) OnClose(_ Conn, err error) (action Action) {
+	s.closeErr = err
+	s.closed.Store(true)
+	return
+}
+
+func (s *testUnixpacketLimitsServer) runClient() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	c, err := net.Dial("unixpacket", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	buf := make([]byte, 1024)
+	if s.truncate {
+		_, err = c.Write(bytes.Repeat([]byte{'x'}, 4096))
+		assert.NoError(s.tester, err)
+		_, err = c.Read(buf)
+		assert.ErrorIs(s.tester, err, io.EOF)
+		for i := 0; i < 100 && !s.closed.Load(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return
+	}
+
+	_, err = c.Write([]byte("abcd"))
+	assert.NoError(s.tester, err)
+	n, err := c.Read(buf)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	assert.Equal(s.tester, []byte("ok"), buf[:n])
+	_, err = c.Write([]byte("efgh"))
+	assert.NoError(s.tester, err)
+	n, err = c.Read(buf)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	assert.Equal(s.tester, []byte("cdefgh"), buf[:n])
+}
+
+func testUnixpacketLimits(t *testing.T, truncate bool) {
+	svr := &testUnixpacketLimitsServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               testUnixAddr(t),
+		truncate:           truncate,
+	}
+	err := Run(svr, "unixpacket://"+svr.addr,
+		WithTicker(true),
+		WithReadBufferCap(1024))
+	assert.NoError(t, err)
+	if truncate {
+		assert.Zero(t, svr.traffic)
+		assert.ErrorIs(t, svr.closeErr, errorx.ErrMessageTruncated)
+	} else {
+		assert.Equal(t, 2, svr.traffic)
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	_, trusted, err := net.ParseCIDR("127.0.0.0/8")
+	assert.NoError(t, err)
+	_, untrusted, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+	t.Run("required", func(t *testing.T) {
+		testProxyProtocol(t, ":9947", ProxyProtocolRequired, []*net.IPNet{trusted}, false, false)
+	})
+	t.Run("required-edge-triggered", func(t *testing.T) {
+		testProxyProtocol(t, ":9948", ProxyProtocolRequired, nil, false, true)
+	})
+	t.Run("optional", func(t *testing.T) {
+		testProxyProtocol(t, ":9949", ProxyProtocolOptional, []*net.IPNet{trusted}, false, false)
+	})
+	t.Run("optional-no-trusted-cidrs", func(t *testing.T) {
+		err := Run(&BuiltinEventEngine{}, "tcp://:9949", WithProxyProtocol(ProxyProtocolOptional))
+		assert.ErrorIs(t, err, errorx.ErrNoTrustedProxies)
+	})
+	t.Run("required-untrusted", func(t *testing.T) {
+		testProxyProtocol(t, ":9950", ProxyProtocolRequired, []*net.IPNet{untrusted}, false, false)
+	})
+	t.Run("optional-untrusted", func(t *testing.T) {
+		testProxyProtocol(t, ":9951", ProxyProtocolOptional, []*net.IPNet{untrusted}, false, false)
+	})
+	t.Run("required-tls", func(t *testing.T) {
+		testProxyProtocol(t, ":9952", ProxyProtocolRequired, nil, true, false)
+	})
+}
+
+type testProxyProtocolServer struct {
+	*BuiltinEventEngine
+	tester  *testing.T
+	eng     Engine
+	addr    string
+	mode    ProxyProtocolMode
+	trusted bool
+	roots   *x509.CertPool
+	started bool
+}
+
+func (s *testProxyProtocolServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testProxyProtocolServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testProxyProtocolServer) OnOpen(c Conn) (out []byte, action Action) {
+	// Greet the client with the address it's seen from and the ALPN passed by the proxy.
+	alpn := "-"
+	if h := c.ProxyHeader(); h != nil {
+		assert.Equal(s.tester, h.SourceAddr, c.RemoteAddr())
+		assert.Equal(s.tester, h.DestinationAddr, c.LocalAddr())
+		if v, ok := h.TLV(ProxyTLVTypeALPN); ok {
+			alpn = string(v)
+		}
+	}
+	return []byte("welcome " + c.RemoteAddr().String() + " " + alpn + "\n"), None
+}
+
+func (s *testProxyProtocolServer) OnTraffic(c Conn) (action Action) {
+	buf, _ := c.Next(-1)
+	_, err := c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testProxyProtocolServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	header1 := []byte("PROXY TCP4 203.0.113.7 198.51.100.1 4242 443\r\n")
+	addrs := make([]byte, 36)
+	copy(addrs, net.ParseIP("2001:db8::7"))
+	copy(addrs[16:], net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(addrs[32:], 4242)
+	binary.BigEndian.PutUint16(addrs[34:], 443)
+	header2 := proxyHeaderV2(0x1, 0x21, addrs, ProxyTLV{Type: ProxyTLVTypeALPN, Value: []byte("h2")})
+
+	switch {
+	case s.roots != nil:
+		s.expectTLS(header1)
+	case !s.trusted && s.mode == ProxyProtocolRequired:
+		s.expectClose([][]byte{header1})
+	case !s.trusted:
+		// The header is taken as data.
+		s.expectOpen([][]byte{header1}, "", string(header1))
+	default:
+		s.expectOpen([][]byte{append(header1, "ping"...)}, "203.0.113.7:4242 -", "ping")
+		// Feed the header piece by piece.
+		s.expectOpen([][]byte{header2[:5], header2[5:20], header2[20:], []byte("pong")}, "[2001:db8::7]:4242 h2", "pong")
+		if s.mode == ProxyProtocolRequired {
+			s.expectClose([][]byte{[]byte("GET / HTTP/1.1\r\n\r\n")})
+			s.expectClose(nil)
+		} else {
+			s.expectOpen([][]byte{[]byte("ping")}, "", "ping")
+			s.expectOpen(nil, "", "")
+		}
+	}
+}
+
+// expectOpen sends chunks and expects the greeting with remote, which is the real address
+// of the client if it's empty, followed by the echo.
+func (s *testProxyProtocolServer) expectOpen(chunks [][]byte, remote, echo string) {
+	c, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+	for i, chunk := range chunks {
+		if i > 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		_, err = c.Write(chunk)
+		assert.NoError(s.tester, err)
+	}
+	if remote == "" {
+		remote = c.LocalAddr().String() + " -"
+	}
+	r := bufio.NewReader(c)
+	line, err := r.ReadString('\n')
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "welcome "+remote+"\n", line)
+	buf := make([]byte, len(echo))
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, echo, string(buf))
+}
+
+// expectClose sends chunks and expects the connection to be closed without a greeting.
+func (s *testProxyProtocolServer) expectClose(chunks [][]byte) {
+	c, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+	for _, chunk := range chunks {
+		_, err = c.Write(chunk)
+		assert.NoError(s.tester, err)
+	}
+	n, err := c.Read(make([]byte, 64))
+	assert.Zero(s.tester, n)
+	assert.Error(s.tester, err)
+	assert.False(s.tester, os.IsTimeout(err))
+}
+
+// expectTLS sends the header in plaintext ahead of the TLS handshake.
+func (s *testProxyProtocolServer) expectTLS(header []byte) {
+	raw, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer raw.Close()
+	_ = raw.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err = raw.Write(header)
+	assert.NoError(s.tester, err)
+	c := tls.Client(raw, &tls.Config{ServerName: "example.com", RootCAs: s.roots})
+	r := bufio.NewReader(c)
+	line, err := r.ReadString('\n')
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "welcome 203.0.113.7:4242 -\n", line)
+	_, err = c.Write([]byte("ping"))
+	assert.NoError(s.tester, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "ping", string(buf))
+}
+
+func testProxyProtocol(t *testing.T, addr string, mode ProxyProtocolMode, cidrs []*net.IPNet, withTLS, et bool) {
+	svr := &testProxyProtocolServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               "127.0.0.1" + addr,
+		mode:               mode,
+		trusted:            isTrustedProxy(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, cidrs),
+	}
+	opts := []Option{
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithEdgeTriggeredIO(et),
+		WithProxyProtocol(mode),
+		WithProxyProtocolTrustedCIDRs(cidrs...),
+		WithProxyProtocolTimeout(200 * time.Millisecond),
+	}
+	if withTLS {
+		cert, leaf := newTestCertificate(t, "example.com")
+		svr.roots = x509.NewCertPool()
+		svr.roots.AddCert(leaf)
+		opts = append(opts, WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	}
+	err := Run(svr, "tcp://"+addr, opts...)
+	assert.NoError(t, err)
+}
+
+func TestConnectionLimits(t *testing.T) {
+	t.Run("close", func(t *testing.T) {
+		testConnectionLimits(t, ":9953", 2, 0, RejectClose, false)
+	})
+	t.Run("close-reuseport", func(t *testing.T) {
+		testConnectionLimits(t, ":9954", 2, 0, RejectClose, true)
+	})
+	t.Run("per-ip", func(t *testing.T) {
+		testConnectionLimits(t, ":9955", 0, 1, RejectClose, false)
+	})
+	t.Run("pause", func(t *testing.T) {
+		testConnectionLimits(t, ":9956", 1, 0, RejectPause, false)
+	})
+	t.Run("pause-reuseport", func(t *testing.T) {
+		testConnectionLimits(t, ":9957", 1, 0, RejectPause, true)
+	})
+}
+
+type testConnectionLimitsServer struct {
+	*BuiltinEventEngine
+	tester   *testing.T
+	eng      Engine
+	addr     string
+	maxConns int
+	maxPerIP int
+	policy   RejectPolicy
+	closed   chan struct{}
+	rejected chan RejectReason
+	rejectAt chan net.Addr
+	started  bool
+}
+
+func (s *testConnectionLimitsServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testConnectionLimitsServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClients)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testConnectionLimitsServer) OnOpen(_ Conn) (out []byte, action Action) {
+	return []byte("welcome\n"), None
+}
+
+func (s *testConnectionLimitsServer) OnClose(_ Conn, _ error) (action Action) {
+	s.closed <- struct{}{}
+	return
+}
+
+func (s *testConnectionLimitsServer) OnReject(addr net.Addr, reason RejectReason) {
+	s.rejected <- reason
+	s.rejectAt <- addr
+}
+
+func (s *testConnectionLimitsServer) dial() (net.Conn, *bufio.Reader, bool) {
+	c, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return nil, nil, false
+	}
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+	return c, bufio.NewReader(c), true
+}
+
+// expectWelcome dials the server and expects the connection to be opened.
+func (s *testConnectionLimitsServer) expectWelcome() net.Conn {
+	c, r, ok := s.dial()
+	if !ok {
+		return nil
+	}
+	line, err := r.ReadString('\n')
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "welcome\n", line)
+	return c
+}
+
+// expectReject dials the server and expects the connection to be rejected for reason.
+func (s *testConnectionLimitsServer) expectReject(reason RejectReason) {
+	c, r, ok := s.dial()
+	if !ok {
+		return
+	}
+	defer c.Close()
+	line, err := r.ReadString('\n')
+	assert.NoError(s.tester, err)
+	assert.Equal(s.tester, "busy\n", line)
+	_, err = r.ReadByte()
+	assert.ErrorIs(s.tester, err, io.EOF)
+	assert.Equal(s.tester, reason, <-s.rejected)
+	assert.Equal(s.tester, c.LocalAddr().String(), (<-s.rejectAt).String())
+}
+
+func (s *testConnectionLimitsServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	if s.policy == RejectPause {
+		c1 := s.expectWelcome()
+		// The connection stays in the backlog until there is room for it.
+		c2, r, ok := s.dial()
+		if c1 == nil || !ok {
+			return
+		}
+		defer c2.Close()
+		_ = c2.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := r.ReadByte()
+		assert.True(s.tester, os.IsTimeout(err), err)
+		_ = c2.SetReadDeadline(time.Now().Add(10 * time.Second))
+		c1.Close()
+		<-s.closed
+		line, err := r.ReadString('\n')
+		assert.NoError(s.tester, err)
+		assert.Equal(s.tester, "welcome\n", line)
+		assert.Empty(s.tester, s.rejected)
+		return
+	}
+
+	limit, reason := s.maxConns, RejectMaxConnections
+	if s.maxPerIP > 0 {
+		limit, reason = s.maxPerIP, RejectMaxConnectionsPerIP
+	}
+	conns := make([]net.Conn, limit)
+	for i := range conns {
+		if conns[i] = s.expectWelcome(); conns[i] == nil {
+			return
+		}
+	}
+	s.expectReject(reason)
+	s.expectReject(reason)
+	// There is room for a new connection once one of them is closed.
+	conns[0].Close()
+	<-s.closed
+	if conns[0] = s.expectWelcome(); conns[0] == nil {
+		return
+	}
+	s.expectReject(reason)
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+func testConnectionLimits(t *testing.T, addr string, maxConns, maxPerIP int, policy RejectPolicy, reusePort bool) {
+	svr := &testConnectionLimitsServer{
+		BuiltinEventEngine: &BuiltinEventEngine{},
+		tester:             t,
+		addr:               "127.0.0.1" + addr,
+		maxConns:           maxConns,
+		maxPerIP:           maxPerIP,
+		policy:             policy,
+		closed:             make(chan struct{}, 16),
+		rejected:           make(chan RejectReason, 16),
+		rejectAt:           make(chan net.Addr, 16),
+	}
+	err := Run(svr, "tcp://"+addr,
+		WithTicker(true),
+		WithReuseAddr(true),
+		WithReusePort(reusePort),
+		WithMulticore(true),
+		WithMaxConnections(maxConns),
+		WithMaxConnectionsPerIP(maxPerIP),
+		WithRejectPolicy(policy),
+		WithRejectResponse([]byte("busy\n")))
+	assert.NoError(t, err)
+}
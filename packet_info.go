@@ -0,0 +1,37 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+import (
+	"net"
+	"time"
+)
+
+// PacketInfo is the ancillary data of a datagram. For a received datagram, it's reported
+// by Conn.PacketInfo when UDPPacketInfo is enabled. For a datagram to send, it's passed
+// to Conn.SendMsg, where the zero value of each field leaves it to the kernel.
+type PacketInfo struct {
+	// LocalIP is the destination address of a received datagram,
+	// or the source address of a datagram to send.
+	LocalIP net.IP
+	// IfIndex is the index of the interface that a datagram was received from,
+	// or the one to send a datagram through.
+	IfIndex int
+	// TOS is the TOS field of IPv4 or the traffic class of IPv6.
+	TOS int
+	// Timestamp is the time when the datagram was received by the kernel,
+	// it's ignored when sending a datagram.
+	Timestamp time.Time
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+// PeerCredentials is the credentials of the peer process of a Unix domain socket,
+// which are taken by the kernel when the connection is established.
+type PeerCredentials struct {
+	// PID is the process ID of the peer, it's -1 if the platform doesn't report it.
+	PID int
+	// UID is the effective user ID of the peer.
+	UID int
+	// GID is the effective group ID of the peer.
+	GID int
+}
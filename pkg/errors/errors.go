@@ -15,7 +15,11 @@
 // Package errors defines common errors for gnet.
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"os"
+)
 
 var (
 	// ErrEmptyEngine occurs when trying to do something with an empty engine.
@@ -29,13 +33,13 @@ var (
 	// ErrTooManyEventLoopThreads occurs when attempting to set up more than 10,000 event-loop goroutines under LockOSThread mode.
 	ErrTooManyEventLoopThreads = errors.New("gnet: too many event-loops under LockOSThread mode")
 	// ErrUnsupportedProtocol occurs when trying to use protocol that is not supported.
-	ErrUnsupportedProtocol = errors.New("gnet: only unix, tcp/tcp4/tcp6, udp/udp4/udp6 are supported")
+	ErrUnsupportedProtocol = errors.New("gnet: only unix/unixgram/unixpacket, tcp/tcp4/tcp6, udp/udp4/udp6 are supported")
 	// ErrUnsupportedTCPProtocol occurs when trying to use an unsupported TCP protocol.
 	ErrUnsupportedTCPProtocol = errors.New("gnet: only tcp/tcp4/tcp6 are supported")
 	// ErrUnsupportedUDPProtocol occurs when trying to use an unsupported UDP protocol.
 	ErrUnsupportedUDPProtocol = errors.New("gnet: only udp/udp4/udp6 are supported")
 	// ErrUnsupportedUDSProtocol occurs when trying to use an unsupported Unix protocol.
-	ErrUnsupportedUDSProtocol = errors.New("gnet: only unix is supported")
+	ErrUnsupportedUDSProtocol = errors.New("gnet: only unix/unixgram/unixpacket are supported")
 	// ErrUnsupportedOp occurs when calling some methods that are either not supported or have not been implemented yet.
 	ErrUnsupportedOp = errors.New("gnet: unsupported operation")
 	// ErrNegativeSize occurs when trying to pass a negative size to a buffer.
@@ -44,8 +48,56 @@ var (
 	ErrNoIPv4AddressOnInterface = errors.New("gnet: no IPv4 address on interface")
 	// ErrInvalidNetworkAddress occurs when the network address is invalid.
 	ErrInvalidNetworkAddress = errors.New("gnet: invalid network address")
+	// ErrDuplicateListener occurs when trying to add a listener on the network address that is already listened on.
+	ErrDuplicateListener = errors.New("gnet: listener already exists")
 	// ErrInvalidNetConn occurs when trying to do something with an empty net.Conn.
 	ErrInvalidNetConn = errors.New("gnet: the net.Conn is empty")
 	// ErrNilRunnable occurs when trying to execute a nil runnable.
 	ErrNilRunnable = errors.New("gnet: nil runnable is not allowed")
+	// ErrReadDeadlineExceeded occurs when the read deadline of a connection is exceeded,
+	// it wraps os.ErrDeadlineExceeded.
+	ErrReadDeadlineExceeded = fmt.Errorf("gnet: read deadline exceeded: %w", os.ErrDeadlineExceeded)
+	// ErrWriteDeadlineExceeded occurs when the write deadline of a connection is exceeded
+	// before the pending data is sent, it wraps os.ErrDeadlineExceeded.
+	ErrWriteDeadlineExceeded = fmt.Errorf("gnet: write deadline exceeded: %w", os.ErrDeadlineExceeded)
+	// ErrInvalidListenFDs occurs when the LISTEN_FDS environment variable is not a non-negative integer.
+	ErrInvalidListenFDs = errors.New("gnet: invalid LISTEN_FDS environment variable")
+	// ErrIdleTimeout occurs when a connection is closed because it has been idle for longer than the idle timeout.
+	ErrIdleTimeout = errors.New("gnet: connection has been idle for too long")
+	// ErrIncompletePacket occurs when there isn't enough data buffered for a whole message.
+	ErrIncompletePacket = errors.New("gnet: incomplete packet")
+	// ErrInvalidFixedLength occurs when the length of data is not a multiple of the fixed frame length.
+	ErrInvalidFixedLength = errors.New("gnet: invalid fixed length of bytes")
+	// ErrUnsupportedLength occurs when the length of the length field is not 1, 2, 3, 4, or 8.
+	ErrUnsupportedLength = errors.New("gnet: unsupported length of the length field, expected: 1, 2, 3, 4, or 8")
+	// ErrNegativeOffset occurs when the LengthFieldOffset or InitialBytesToStrip of a codec is negative.
+	ErrNegativeOffset = errors.New("gnet: LengthFieldOffset and InitialBytesToStrip must not be negative")
+	// ErrTooLessLength occurs when the adjusted frame length is less than the length of the header or zero.
+	ErrTooLessLength = errors.New("gnet: adjusted frame length is too small")
+	// ErrTooLargeLength occurs when the frame length can't be represented by the length field.
+	ErrTooLargeLength = errors.New("gnet: frame length is too large for the length field")
+	// ErrTooLongFrame occurs when the frame length exceeds the maximum frame length of the codec.
+	ErrTooLongFrame = errors.New("gnet: frame length exceeds the maximum")
+	// ErrDecodeNoProgress occurs when a codec decodes a message without consuming any inbound data.
+	ErrDecodeNoProgress = errors.New("gnet: codec decoded a message without consuming any data")
+	// ErrTooSmallReadBuffer occurs when UDPGRO is enabled with a ReadBufferCap that can't hold the coalesced datagrams.
+	ErrTooSmallReadBuffer = errors.New("gnet: ReadBufferCap must be at least 64KB with UDPGRO")
+	// ErrTooManyHandshakes occurs when a connection is closed because MaxTLSHandshakes or MaxTLSHandshakesPerIP has been reached.
+	ErrTooManyHandshakes = errors.New("gnet: too many TLS handshakes in progress")
+	// ErrNoTrustedProxies occurs when ProxyProtocolOptional is enabled on a TCP listener without ProxyProtocolTrustedCIDRs.
+	ErrNoTrustedProxies = errors.New("gnet: ProxyProtocolOptional requires ProxyProtocolTrustedCIDRs on TCP listeners")
+	// ErrMessageTruncated occurs when a unixpacket message is larger than the ReadBufferCap of the connection.
+	ErrMessageTruncated = errors.New("gnet: message is larger than ReadBufferCap and has been truncated")
+	// ErrWriteClosed occurs when writing to a connection whose writing side has been shut down.
+	ErrWriteClosed = errors.New("gnet: the writing side of the connection has been shut down")
+	// ErrNotSameEventLoop occurs when two connections are required to be served by the same event-loop but they aren't.
+	ErrNotSameEventLoop = errors.New("gnet: connections are not served by the same event-loop")
+	// ErrEmptyData occurs when sending file descriptors without any data to carry them.
+	ErrEmptyData = errors.New("gnet: file descriptors must be sent along with at least one byte of data")
+	// ErrInvalidProxyHeader occurs when the PROXY protocol header of a connection is malformed or missing.
+	ErrInvalidProxyHeader = errors.New("gnet: invalid or missing PROXY protocol header")
+	// ErrProxyHeaderTimeout occurs when the PROXY protocol header of a connection doesn't arrive in time.
+	ErrProxyHeaderTimeout = errors.New("gnet: timed out waiting for the PROXY protocol header")
+	// ErrUntrustedProxy occurs when a PROXY protocol header is required from a source that isn't trusted.
+	ErrUntrustedProxy = errors.New("gnet: connection is not from a trusted proxy")
 )
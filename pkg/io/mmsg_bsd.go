@@ -0,0 +1,105 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package io
+
+import "golang.org/x/sys/unix"
+
+// MsgBatch holds the results of receiving a batch of datagrams, it's reusable but not
+// concurrency-safe. Since recvmmsg() and sendmmsg() are not available on BSD, a batch
+// is received and sent by one system call per datagram.
+type MsgBatch struct {
+	lens  []int
+	addrs []unix.Sockaddr
+}
+
+// NewMsgBatch creates a MsgBatch for up to n datagrams.
+func NewMsgBatch(n int) *MsgBatch {
+	return &MsgBatch{
+		lens:  make([]int, n),
+		addrs: make([]unix.Sockaddr, n),
+	}
+}
+
+// Cap returns the maximum number of datagrams in a batch.
+func (b *MsgBatch) Cap() int {
+	return len(b.lens)
+}
+
+// Recvmmsg receives up to len(bufs) datagrams into bufs with recvfrom() until there
+// is no more datagram, it returns the number of datagrams received, Len and Addr
+// report the length and the source address of each of them.
+func (b *MsgBatch) Recvmmsg(fd int, bufs [][]byte) (int, error) {
+	n := len(bufs)
+	if n > len(b.lens) {
+		n = len(b.lens)
+	}
+	for i := 0; i < n; i++ {
+		m, sa, err := unix.Recvfrom(fd, bufs[i], 0)
+		if err != nil {
+			if i == 0 {
+				return 0, err
+			}
+			return i, nil
+		}
+		b.lens[i], b.addrs[i] = m, sa
+	}
+	return n, nil
+}
+
+// Sendmmsg sends bufs[i] to addrs[i] with sendto(), addrs can be nil for a connected
+// socket. It returns the number of datagrams sent, which can be less than len(bufs).
+func (b *MsgBatch) Sendmmsg(fd int, bufs [][]byte, addrs []unix.Sockaddr) (int, error) {
+	n := len(bufs)
+	if n > len(b.lens) {
+		n = len(b.lens)
+	}
+	for i := 0; i < n; i++ {
+		var err error
+		if addrs == nil {
+			err = unix.Send(fd, bufs[i], 0)
+		} else {
+			err = unix.Sendto(fd, bufs[i], 0, addrs[i])
+		}
+		if err != nil {
+			if i == 0 {
+				return 0, err
+			}
+			return i, nil
+		}
+	}
+	return n, nil
+}
+
+// Len returns the length of the i-th datagram received by the latest Recvmmsg.
+func (b *MsgBatch) Len(i int) int {
+	return b.lens[i]
+}
+
+// SegmentSize always returns 0 since UDP_GRO is only available on Linux.
+func (b *MsgBatch) SegmentSize(_ int) int {
+	return 0
+}
+
+// Control always returns nil since the control messages are not received on BSD.
+func (b *MsgBatch) Control(_ int) []byte {
+	return nil
+}
+
+// Addr returns the source address of the i-th datagram received by the latest Recvmmsg.
+func (b *MsgBatch) Addr(i int) unix.Sockaddr {
+	return b.addrs[i]
+}
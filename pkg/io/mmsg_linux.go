@@ -0,0 +1,192 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmsghdr is the struct mmsghdr of recvmmsg() and sendmmsg().
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// MsgBatch holds the message headers for receiving or sending a batch of datagrams
+// in a single system call, it's reusable but not concurrency-safe.
+type MsgBatch struct {
+	hdrs  []mmsghdr
+	iovs  []unix.Iovec
+	names []unix.RawSockaddrAny
+	oobs  []byte // control messages of received datagrams, oobSize bytes for each
+}
+
+// oobSize is the size of the control messages of a received datagram, which is enough for
+// UDP_GRO, IP_PKTINFO and IPV6_PKTINFO (both of them come along with the IPv4 datagrams of
+// a dual-stack socket), IP_TOS or IPV6_TCLASS, and SO_TIMESTAMPING.
+var oobSize = unix.CmsgSpace(4) +
+	unix.CmsgSpace(unix.SizeofInet4Pktinfo) +
+	unix.CmsgSpace(unix.SizeofInet6Pktinfo) +
+	unix.CmsgSpace(4) +
+	unix.CmsgSpace(3*int(unsafe.Sizeof(unix.Timespec{})))
+
+// NewMsgBatch creates a MsgBatch for up to n datagrams.
+func NewMsgBatch(n int) *MsgBatch {
+	return &MsgBatch{
+		hdrs:  make([]mmsghdr, n),
+		iovs:  make([]unix.Iovec, n),
+		names: make([]unix.RawSockaddrAny, n),
+		oobs:  make([]byte, n*oobSize),
+	}
+}
+
+// Cap returns the maximum number of datagrams in a batch.
+func (b *MsgBatch) Cap() int {
+	return len(b.hdrs)
+}
+
+func (b *MsgBatch) setMsg(i int, buf []byte) {
+	iov := &b.iovs[i]
+	if len(buf) > 0 {
+		iov.Base = &buf[0]
+	} else {
+		iov.Base = nil
+	}
+	iov.SetLen(len(buf))
+	h := &b.hdrs[i]
+	h.hdr = unix.Msghdr{Name: (*byte)(unsafe.Pointer(&b.names[i])), Iov: iov}
+	h.hdr.SetIovlen(1)
+	h.len = 0
+}
+
+// Recvmmsg calls recvmmsg() on Linux to receive up to len(bufs) datagrams into bufs,
+// it returns the number of datagrams received, Len and Addr report the length and
+// the source address of each of them.
+func (b *MsgBatch) Recvmmsg(fd int, bufs [][]byte) (int, error) {
+	n := len(bufs)
+	if n > len(b.hdrs) {
+		n = len(b.hdrs)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	for i := 0; i < n; i++ {
+		b.setMsg(i, bufs[i])
+		h := &b.hdrs[i].hdr
+		h.Namelen = unix.SizeofSockaddrAny
+		h.Control = &b.oobs[i*oobSize]
+		h.SetControllen(oobSize)
+	}
+	r, _, errno := unix.Syscall6(unix.SYS_RECVMMSG, uintptr(fd), uintptr(unsafe.Pointer(&b.hdrs[0])),
+		uintptr(n), 0, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+// Sendmmsg calls sendmmsg() on Linux to send bufs[i] to addrs[i] in a single system call,
+// addrs can be nil for a connected socket. It returns the number of datagrams sent, which
+// can be less than len(bufs).
+func (b *MsgBatch) Sendmmsg(fd int, bufs [][]byte, addrs []unix.Sockaddr) (int, error) {
+	n := len(bufs)
+	if n > len(b.hdrs) {
+		n = len(b.hdrs)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	for i := 0; i < n; i++ {
+		b.setMsg(i, bufs[i])
+		if addrs == nil {
+			b.hdrs[i].hdr.Name = nil
+			continue
+		}
+		namelen, err := putSockaddr(&b.names[i], addrs[i])
+		if err != nil {
+			return 0, err
+		}
+		b.hdrs[i].hdr.Namelen = namelen
+	}
+	r, _, errno := unix.Syscall6(unix.SYS_SENDMMSG, uintptr(fd), uintptr(unsafe.Pointer(&b.hdrs[0])),
+		uintptr(n), 0, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+// Len returns the length of the i-th datagram received by the latest Recvmmsg.
+func (b *MsgBatch) Len(i int) int {
+	return int(b.hdrs[i].len)
+}
+
+// SegmentSize returns the size of the segments coalesced into the i-th datagram received
+// by the latest Recvmmsg with UDP_GRO enabled, it's 0 if the datagram is not coalesced.
+func (b *MsgBatch) SegmentSize(i int) int {
+	cmsgs, err := unix.ParseSocketControlMessage(b.Control(i))
+	if err != nil {
+		return 0
+	}
+	for _, cmsg := range cmsgs {
+		if cmsg.Header.Level == unix.SOL_UDP && cmsg.Header.Type == unix.UDP_GRO && len(cmsg.Data) >= 4 {
+			return int(*(*int32)(unsafe.Pointer(&cmsg.Data[0])))
+		}
+	}
+	return 0
+}
+
+// Control returns the control messages of the i-th datagram received by the latest Recvmmsg.
+func (b *MsgBatch) Control(i int) []byte {
+	return b.oobs[i*oobSize : i*oobSize+int(b.hdrs[i].hdr.Controllen)]
+}
+
+// Addr returns the source address of the i-th datagram received by the latest Recvmmsg,
+// it's nil if the address is neither IPv4 nor IPv6.
+func (b *MsgBatch) Addr(i int) unix.Sockaddr {
+	rsa := &b.names[i]
+	switch rsa.Addr.Family {
+	case unix.AF_INET:
+		raw := (*unix.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		return &unix.SockaddrInet4{Port: int(p[0])<<8 | int(p[1]), Addr: raw.Addr}
+	case unix.AF_INET6:
+		raw := (*unix.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		return &unix.SockaddrInet6{Port: int(p[0])<<8 | int(p[1]), ZoneId: raw.Scope_id, Addr: raw.Addr}
+	}
+	return nil
+}
+
+// putSockaddr converts the IPv4 or IPv6 address sa into rsa and returns the length of it.
+func putSockaddr(rsa *unix.RawSockaddrAny, sa unix.Sockaddr) (uint32, error) {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		raw := (*unix.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		*raw = unix.RawSockaddrInet4{Family: unix.AF_INET, Addr: sa.Addr}
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		p[0], p[1] = byte(sa.Port>>8), byte(sa.Port)
+		return unix.SizeofSockaddrInet4, nil
+	case *unix.SockaddrInet6:
+		raw := (*unix.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		*raw = unix.RawSockaddrInet6{Family: unix.AF_INET6, Scope_id: sa.ZoneId, Addr: sa.Addr}
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		p[0], p[1] = byte(sa.Port>>8), byte(sa.Port)
+		return unix.SizeofSockaddrInet6, nil
+	}
+	return 0, unix.EAFNOSUPPORT
+}
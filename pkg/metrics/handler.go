@@ -0,0 +1,39 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics serves the metrics of gnet engines over HTTP, it's kept apart from
+// package gnet so that the programs that don't serve metrics don't link net/http.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/panjf2000/gnet/v2"
+)
+
+// Handler returns an http.Handler that serves the metrics of eng in the Prometheus
+// text exposition format, it can be mounted on any path of any http.Server.
+// It responds with 503 Service Unavailable if the metrics are unavailable, e.g., the
+// engine has been shut down or gnet.WithMetrics is not set.
+func Handler(eng gnet.Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		metrics, err := eng.Metrics()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = gnet.WriteMetrics(w, metrics)
+	})
+}
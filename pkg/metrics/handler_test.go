@@ -0,0 +1,56 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/panjf2000/gnet/v2"
+	"github.com/panjf2000/gnet/v2/pkg/metrics"
+)
+
+type testServer struct {
+	*gnet.BuiltinEventEngine
+	tester *testing.T
+	eng    gnet.Engine
+}
+
+func (s *testServer) OnBoot(eng gnet.Engine) (action gnet.Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testServer) OnTick() (delay time.Duration, action gnet.Action) {
+	w := httptest.NewRecorder()
+	metrics.Handler(s.eng).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(s.tester, http.StatusOK, w.Code)
+	assert.Contains(s.tester, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(s.tester, w.Body.String(), "# TYPE gnet_connections_accepted_total counter")
+	assert.Contains(s.tester, w.Body.String(), `gnet_connections_active{loop="0"} 0`)
+	go func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+	delay = time.Minute
+	return
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("running", func(t *testing.T) {
+		svr := &testServer{BuiltinEventEngine: &gnet.BuiltinEventEngine{}, tester: t}
+		err := gnet.Run(svr, "tcp://127.0.0.1:9966", gnet.WithTicker(true),
+			gnet.WithNumEventLoop(1),
+			gnet.WithMetrics(true))
+		assert.NoError(t, err)
+	})
+	t.Run("unavailable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		metrics.Handler(gnet.Engine{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
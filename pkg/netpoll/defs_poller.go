@@ -23,4 +23,24 @@ type PollEventHandler func(int, IOEvent, IOFlags) error
 type PollAttachment struct {
 	FD       int
 	Callback PollEventHandler
+
+	// Submission is the I/O performed on FD by the io_uring-backed Poller itself, it's ignored
+	// by the pollers backed by epoll or kqueue.
+	Submission Submission
 }
+
+// Submission specifies the I/O that the io_uring-backed Poller performs on a file descriptor by
+// submitting requests instead of monitoring the readiness of it. The readable or writable events
+// are then notified once the results of the requests are available, which are supposed to be
+// taken by Poller.Accept, Poller.Read and Poller.Write/Writev in place of the system calls.
+type Submission uint8
+
+const (
+	// SubmitNone monitors the readiness of the file descriptor only.
+	SubmitNone Submission = iota
+	// SubmitAccept accepts connections from the listening socket.
+	SubmitAccept
+	// SubmitStream receives data from the stream socket into the provided buffers
+	// and sends the data passed to Poller.Write/Writev.
+	SubmitStream
+)
@@ -74,3 +74,21 @@ func (el *eventList) shrink() {
 		el.events = make([]unix.Kevent_t, newSize)
 	}
 }
+
+// timespec returns the timeout that kevent is allowed to block for, based on
+// the given timeout tsp and the earliest timer on the poller, buf is used to
+// store the timeout of the timer if it is earlier than tsp.
+func (p *Poller) timespec(tsp, buf *unix.Timespec) *unix.Timespec {
+	if len(p.timers) == 0 || (tsp != nil && tsp.Sec == 0 && tsp.Nsec == 0) {
+		return tsp
+	}
+	d := p.earliest()
+	if d < 0 {
+		d = 0
+	}
+	if tsp != nil && unix.TimespecToNsec(*tsp) <= d {
+		return tsp
+	}
+	*buf = unix.NsecToTimespec(d)
+	return buf
+}
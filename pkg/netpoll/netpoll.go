@@ -20,10 +20,18 @@ Package netpoll provides a portable event-driven interface for network I/O.
 The underlying facility of event notification is OS-specific:
   - epoll on Linux - https://man7.org/linux/man-pages/man7/epoll.7.html
   - kqueue on *BSD/Darwin - https://man.freebsd.org/cgi/man.cgi?kqueue
+  - io_uring on Linux 6.3+, optional - https://man7.org/linux/man-pages/man7/io_uring.7.html
 
 With the help of the netpoll package, you can easily build your own high-performance
 event-driven network applications based on epoll/kqueue.
 
+On Linux, the Poller can also be backed by io_uring, which is created by OpenIOURingPoller
+and has the same API as the one backed by epoll. OpenIOURingPoller fails if the kernel lacks
+support for io_uring, the caller is supposed to fall back to OpenPoller in that case. Besides
+monitoring the readiness, the io_uring-backed Poller performs the I/O of the file descriptors
+registered with a Submission itself, which are then accessed by Poller.Accept, Poller.Read,
+Poller.Write and Poller.Writev rather than the system calls.
+
 The Poller represents the event notification facility whose backend is epoll or kqueue.
 The OpenPoller function creates a new Poller instance:
 
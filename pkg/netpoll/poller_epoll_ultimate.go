@@ -21,6 +21,7 @@ import (
 	"os"
 	"runtime"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -39,6 +40,10 @@ type Poller struct {
 	asyncTaskQueue              queue.AsyncTaskQueue // queue with low priority
 	urgentAsyncTaskQueue        queue.AsyncTaskQueue // queue with high priority
 	highPriorityEventsThreshold int32                // threshold of high-priority events
+	timers                      timerHeap            // timers ordered by expiration
+	ctxTimers                   []*Timer             // armed timers with a ctx that can be done
+	now                         time.Time            // time sampled after the latest wait for events
+	ring                        *ioURing             // io_uring instance, nil if the poller is backed by epoll
 }
 
 // OpenPoller instantiates a poller.
@@ -69,9 +74,42 @@ func OpenPoller() (poller *Poller, err error) {
 	return
 }
 
+// OpenIOURingPoller instantiates a poller backed by io_uring, it returns an error
+// if io_uring is unavailable or the kernel is older than 6.3, in which case the
+// caller may fall back to OpenPoller.
+func OpenIOURingPoller() (poller *Poller, err error) {
+	poller = new(Poller)
+	if poller.ring, err = openIOURing(); err != nil {
+		poller = nil
+		return
+	}
+	poller.fd = poller.ring.fd
+	var efd int
+	if efd, err = unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC); err != nil {
+		_ = poller.ring.close()
+		poller = nil
+		err = os.NewSyscallError("eventfd", err)
+		return
+	}
+	poller.efdBuf = make([]byte, 8)
+	poller.epa = &PollAttachment{FD: efd}
+	if err = poller.AddRead(poller.epa, true); err != nil {
+		_ = poller.Close()
+		poller = nil
+		return
+	}
+	poller.asyncTaskQueue = queue.NewLockFreeQueue()
+	poller.urgentAsyncTaskQueue = queue.NewLockFreeQueue()
+	poller.highPriorityEventsThreshold = MaxPollEventsCap
+	return
+}
+
 // Close closes the poller.
 func (p *Poller) Close() error {
 	_ = unix.Close(p.epa.FD)
+	if p.ring != nil {
+		return p.ring.close()
+	}
 	return os.NewSyscallError("close", unix.Close(p.fd))
 }
 
@@ -111,17 +149,32 @@ func (p *Poller) Trigger(priority queue.EventPriority, fn queue.Func, param any)
 	return os.NewSyscallError("write", err)
 }
 
+// TaskQueueLengths returns the number of pending tasks in urgentAsyncTaskQueue and asyncTaskQueue.
+func (p *Poller) TaskQueueLengths() (urgent, normal int) {
+	return int(p.urgentAsyncTaskQueue.Length()), int(p.asyncTaskQueue.Length())
+}
+
 // Polling blocks the current goroutine, monitoring the registered file descriptors and waiting for network I/O.
 // When I/O occurs on any of the file descriptors, the provided callback function is invoked.
 func (p *Poller) Polling() error {
+	if p.ring != nil {
+		return p.pollingURing(p.epa.FD, func(pa *PollAttachment, ev IOEvent) error {
+			return pa.Callback(pa.FD, ev, 0)
+		})
+	}
+
 	el := newEventList(InitPollEventsCap)
 	var doChores bool
 
 	msec := -1
 	for {
-		n, err := epollWait(p.fd, el.events, msec)
+		n, err := epollWait(p.fd, el.events, p.timeout(msec))
+		p.now = time.Now()
 		if n == 0 || (n < 0 && err == unix.EINTR) {
 			msec = -1
+			if err = p.runTimers(); err != nil {
+				return err
+			}
 			runtime.Gosched()
 			continue
 		} else if err != nil {
@@ -179,6 +232,10 @@ func (p *Poller) Polling() error {
 			}
 		}
 
+		if err = p.runTimers(); err != nil {
+			return err
+		}
+
 		if n == el.size {
 			el.expand()
 		} else if n < el.size>>1 {
@@ -194,6 +251,9 @@ func (p *Poller) AddReadWrite(pa *PollAttachment, edgeTriggered bool) error {
 	if edgeTriggered {
 		ev.events |= unix.EPOLLET | unix.EPOLLRDHUP
 	}
+	if p.ring != nil {
+		return p.ring.add(pa, ev.events)
+	}
 	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
 	return os.NewSyscallError("epoll_ctl add", epollCtl(p.fd, unix.EPOLL_CTL_ADD, pa.FD, &ev))
 }
@@ -205,6 +265,9 @@ func (p *Poller) AddRead(pa *PollAttachment, edgeTriggered bool) error {
 	if edgeTriggered {
 		ev.events |= unix.EPOLLET | unix.EPOLLRDHUP
 	}
+	if p.ring != nil {
+		return p.ring.add(pa, ev.events)
+	}
 	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
 	return os.NewSyscallError("epoll_ctl add", epollCtl(p.fd, unix.EPOLL_CTL_ADD, pa.FD, &ev))
 }
@@ -216,6 +279,9 @@ func (p *Poller) AddWrite(pa *PollAttachment, edgeTriggered bool) error {
 	if edgeTriggered {
 		ev.events |= unix.EPOLLET | unix.EPOLLRDHUP
 	}
+	if p.ring != nil {
+		return p.ring.add(pa, ev.events)
+	}
 	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
 	return os.NewSyscallError("epoll_ctl add", epollCtl(p.fd, unix.EPOLL_CTL_ADD, pa.FD, &ev))
 }
@@ -227,6 +293,9 @@ func (p *Poller) ModRead(pa *PollAttachment, edgeTriggered bool) error {
 	if edgeTriggered {
 		ev.events |= unix.EPOLLET | unix.EPOLLRDHUP
 	}
+	if p.ring != nil {
+		return p.ring.mod(pa, ev.events)
+	}
 	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
 	return os.NewSyscallError("epoll_ctl mod", epollCtl(p.fd, unix.EPOLL_CTL_MOD, pa.FD, &ev))
 }
@@ -238,11 +307,52 @@ func (p *Poller) ModReadWrite(pa *PollAttachment, edgeTriggered bool) error {
 	if edgeTriggered {
 		ev.events |= unix.EPOLLET | unix.EPOLLRDHUP
 	}
+	if p.ring != nil {
+		return p.ring.mod(pa, ev.events)
+	}
+	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
+	return os.NewSyscallError("epoll_ctl mod", epollCtl(p.fd, unix.EPOLL_CTL_MOD, pa.FD, &ev))
+}
+
+// ModWrite modifies the given file descriptor with writable event in the poller.
+func (p *Poller) ModWrite(pa *PollAttachment, edgeTriggered bool) error {
+	var ev epollevent
+	ev.events = WriteEvents
+	if edgeTriggered {
+		ev.events |= unix.EPOLLET | unix.EPOLLRDHUP
+	}
+	if p.ring != nil {
+		return p.ring.mod(pa, ev.events)
+	}
+	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
+	return os.NewSyscallError("epoll_ctl mod", epollCtl(p.fd, unix.EPOLL_CTL_MOD, pa.FD, &ev))
+}
+
+// ModNone modifies the given file descriptor with no events in the poller,
+// only the error and hang-up events will be reported for it.
+func (p *Poller) ModNone(pa *PollAttachment) error {
+	if p.ring != nil {
+		return p.ring.mod(pa, 0)
+	}
+	var ev epollevent
 	convertPollAttachment(unsafe.Pointer(&ev.data), pa)
 	return os.NewSyscallError("epoll_ctl mod", epollCtl(p.fd, unix.EPOLL_CTL_MOD, pa.FD, &ev))
 }
 
 // Delete removes the given file descriptor from the poller.
 func (p *Poller) Delete(fd int) error {
+	if p.ring != nil {
+		return p.ring.del(fd)
+	}
 	return os.NewSyscallError("epoll_ctl del", epollCtl(p.fd, unix.EPOLL_CTL_DEL, fd, nil))
 }
+
+// Detach removes the given file descriptor from the poller, it's equivalent to Delete for epoll,
+// while the io_uring-based poller keeps the sockets accepted from a listening socket until
+// it's added back or deleted.
+func (p *Poller) Detach(fd int) error {
+	if p.ring != nil {
+		return p.ring.detach(fd)
+	}
+	return p.Delete(fd)
+}
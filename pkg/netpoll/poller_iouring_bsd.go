@@ -0,0 +1,56 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package netpoll
+
+import (
+	"golang.org/x/sys/unix"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+	gio "github.com/panjf2000/gnet/v2/pkg/io"
+	"github.com/panjf2000/gnet/v2/pkg/socket"
+)
+
+// OpenIOURingPoller always returns errorx.ErrUnsupportedOp as io_uring is Linux-specific,
+// the caller ought to fall back to OpenPoller.
+func OpenIOURingPoller() (*Poller, error) {
+	return nil, errorx.ErrUnsupportedOp
+}
+
+// IOURing always returns false as io_uring is Linux-specific.
+func (p *Poller) IOURing() bool {
+	return false
+}
+
+// Accept accepts a connection with accept(2).
+func (p *Poller) Accept(fd int) (int, unix.Sockaddr, error) {
+	return socket.Accept(fd)
+}
+
+// Read reads with read(2).
+func (p *Poller) Read(fd int, buf []byte) (int, error) {
+	return unix.Read(fd, buf)
+}
+
+// Write writes with write(2).
+func (p *Poller) Write(fd int, buf []byte) (int, error) {
+	return unix.Write(fd, buf)
+}
+
+// Writev writes with writev(2).
+func (p *Poller) Writev(fd int, iov [][]byte) (int, error) {
+	return gio.Writev(fd, iov)
+}
@@ -0,0 +1,1159 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netpoll
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+	gio "github.com/panjf2000/gnet/v2/pkg/io"
+	"github.com/panjf2000/gnet/v2/pkg/logging"
+	bsPool "github.com/panjf2000/gnet/v2/pkg/pool/byteslice"
+	"github.com/panjf2000/gnet/v2/pkg/queue"
+	"github.com/panjf2000/gnet/v2/pkg/socket"
+)
+
+// The io_uring poller performs the I/O of the file descriptors registered with a Submission
+// by submitting requests, and monitors the readiness of the others with IORING_OP_POLL_ADD.
+//
+// For a listening socket registered with SubmitAccept, a few IORING_OP_ACCEPT requests are kept
+// pending while the readable event is monitored, the accepted sockets are queued up along with
+// their addresses and taken by Poller.Accept after the readable event is notified.
+//
+// For a stream socket registered with SubmitStream, a multi-shot IORING_OP_RECV request receives
+// data into the buffers provided by a buffer ring that is shared by all sockets of the poller,
+// the buffers are recycled once the data is taken by Poller.Read after the readable event is
+// notified. Poller.Write and Poller.Writev gather the data into a buffer of the poller, since
+// the memory of the caller may be reused before the kernel is done with it, and submit it with
+// an IORING_OP_WRITE request. They report EAGAIN while the request is pending, the writable event
+// is notified once it completes, and the result of it is returned by the next call, which is
+// supposed to be made with the same pending data. While there is no pending write, the writable
+// event is monitored with IORING_OP_POLL_ADD for the data sent without the poller, e.g. files.
+//
+// For other file descriptors, a level-triggered registration is an one-shot poll request that
+// is re-armed after its completion has been handled, and an edge-triggered registration is a
+// multi-shot poll request.
+//
+// The readable and writable events of the file descriptors registered with a Submission are
+// level-triggered unless they're registered as edge-triggered, in which case they're notified
+// whenever a request completes. All requests are batched up in the submission queue and get
+// submitted along with the wait for completions, which saves the system calls of the I/O.
+
+const (
+	ioURingEntries = MaxPollEventsCap
+
+	ioURingOpPollAdd     = 6
+	ioURingOpAccept      = 13
+	ioURingOpAsyncCancel = 14
+	ioURingOpWrite       = 23
+	ioURingOpRecv        = 27
+
+	ioURingSQEBufferSelect = 1 << 5 // IOSQE_BUFFER_SELECT
+	ioURingPollAddMulti    = 1 << 0 // IORING_POLL_ADD_MULTI
+	ioURingRecvMultishot   = 1 << 1 // IORING_RECV_MULTISHOT
+
+	ioURingCQEFBuffer     = 1 << 0 // IORING_CQE_F_BUFFER
+	ioURingCQEFMore       = 1 << 1 // IORING_CQE_F_MORE
+	ioURingCQEBufferShift = 16     // IORING_CQE_BUFFER_SHIFT
+
+	ioURingEnterGetEvents = 1 << 0 // IORING_ENTER_GETEVENTS
+	ioURingEnterExtArg    = 1 << 3 // IORING_ENTER_EXT_ARG
+
+	ioURingSetupClamp = 1 << 4 // IORING_SETUP_CLAMP
+
+	ioURingRegisterPBufRing = 22 // IORING_REGISTER_PBUF_RING
+
+	ioURingFeatSingleMmap = 1 << 0  // IORING_FEAT_SINGLE_MMAP
+	ioURingFeatNoDrop     = 1 << 1  // IORING_FEAT_NODROP
+	ioURingFeatExtArg     = 1 << 8  // IORING_FEAT_EXT_ARG
+	ioURingFeatRegRegRing = 1 << 13 // IORING_FEAT_REG_REG_RING, shipped in 6.3, later than multi-shot receives (6.0)
+
+	ioURingRequiredFeatures = ioURingFeatSingleMmap | ioURingFeatNoDrop | ioURingFeatExtArg | ioURingFeatRegRegRing
+
+	ioURingOffSQRing = 0
+	ioURingOffSQEs   = 0x10000000
+
+	ioURingBufGroup     = 0        // ID of the group of the provided buffers
+	ioURingBufEntries   = 256      // number of the provided buffers, must be a power of 2
+	ioURingBufSize      = 16 << 10 // size of each provided buffer
+	ioURingAcceptDepth  = 8        // number of pending accept requests of a listening socket
+	ioURingMaxWriteSize = 256 << 10
+)
+
+type ioURingSQRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioURingCQRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioURingParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFD         uint32
+	resv         [3]uint32
+	sqOff        ioURingSQRingOffsets
+	cqOff        ioURingCQRingOffsets
+}
+
+type ioURingSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFDIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type ioURingCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type ioURingGetEventsArg struct {
+	sigmask   uint64
+	sigmaskSz uint32
+	pad       uint32
+	ts        uint64
+}
+
+type ioURingBufReg struct {
+	ringAddr    uint64
+	ringEntries uint32
+	bgid        uint16
+	flags       uint16
+	resv        [3]uint64
+}
+
+// ioURingBuf is an entry of the buffer ring, the tail of the ring
+// overlaps the resv field of the first entry.
+type ioURingBuf struct {
+	addr uint64
+	len  uint32
+	bid  uint16
+	resv uint16
+}
+
+// ioURingRequest is a pending request of a file descriptor.
+type ioURingRequest struct {
+	op        uint8
+	seq       uint64 // user_data of the request
+	file      *ioURingFile
+	cancelled bool
+
+	addr    unix.RawSockaddrAny // peer address of IORING_OP_ACCEPT
+	addrLen uint32
+	buf     []byte // data of IORING_OP_WRITE
+}
+
+// ioURingAccepted is a socket accepted by IORING_OP_ACCEPT, or the error of it.
+type ioURingAccepted struct {
+	fd  int
+	sa  unix.Sockaddr
+	err error
+}
+
+// ioURingChunk is the data received into a provided buffer.
+type ioURingChunk struct {
+	bid  uint16
+	data []byte
+}
+
+// ioURingFile is the registration of a file descriptor.
+type ioURingFile struct {
+	pa      *PollAttachment
+	events  uint32
+	edge    bool
+	deleted bool
+	// detached is set when a listening socket is detached with the accepted sockets kept.
+	detached bool
+	ready    bool   // whether it's in the ready list
+	revents  uint32 // events of the poll requests that haven't been notified
+
+	poll    *ioURingRequest   // pending IORING_OP_POLL_ADD
+	accepts []*ioURingRequest // pending IORING_OP_ACCEPT
+	recv    *ioURingRequest   // pending IORING_OP_RECV
+	write   *ioURingRequest   // pending IORING_OP_WRITE
+
+	accepted  []ioURingAccepted
+	chunks    []ioURingChunk
+	eof       bool
+	readErr   error
+	starved   bool // the receiving stopped for running out of the provided buffers
+	written   int
+	writeErr  error
+	writeDone bool
+}
+
+func (f *ioURingFile) readable() bool {
+	if f.pa.Submission == SubmitAccept {
+		return len(f.accepted) > 0
+	}
+	return len(f.chunks) > 0 || f.eof || f.readErr != nil
+}
+
+// pending returns the events to notify for a file descriptor registered with a Submission.
+func (f *ioURingFile) pending() (ev uint32) {
+	if f.events&ReadEvents != 0 && f.readable() {
+		ev |= unix.EPOLLIN
+	}
+	if f.events&WriteEvents != 0 && f.writeDone {
+		ev |= unix.EPOLLOUT
+	}
+	return
+}
+
+type ioURing struct {
+	fd        int
+	ringMem   []byte
+	sqeMem    []byte
+	sqHead    *uint32
+	sqTail    *uint32
+	sqMask    uint32
+	sqSize    uint32
+	sqes      []ioURingSQE
+	cqHead    *uint32
+	cqTail    *uint32
+	cqMask    uint32
+	cqes      []ioURingCQE
+	tail      uint32 // local tail of the submission queue
+	submitted uint32 // tail that has been published to the kernel
+
+	seq    uint64                     // sequence of requests
+	reqs   map[uint64]*ioURingRequest // pending requests indexed by user_data
+	files  map[int]*ioURingFile       // registrations indexed by file descriptor
+	events []ioURingCQE               // completions reaped in the current round
+
+	ready   []*ioURingFile // files registered with a Submission that have events to notify
+	spare   []*ioURingFile
+	starved []*ioURingFile
+
+	bufRing []byte // ring of the provided buffers
+	bufs    []byte // memory of the provided buffers
+	bufTail uint16
+
+	// Arguments of io_uring_enter are kept here to stay put during system calls.
+	arg ioURingGetEventsArg
+	ts  unix.Timespec
+}
+
+var isBigEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 0
+}()
+
+func openIOURing() (*ioURing, error) {
+	var params ioURingParams
+	params.flags = ioURingSetupClamp
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, ioURingEntries, uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	r := &ioURing{fd: int(fd)}
+	if params.features&ioURingRequiredFeatures != ioURingRequiredFeatures {
+		_ = unix.Close(r.fd)
+		return nil, errorx.ErrUnsupportedOp
+	}
+
+	sqSize := params.sqOff.array + params.sqEntries*4
+	cqSize := params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(ioURingCQE{}))
+	size := sqSize
+	if cqSize > size {
+		size = cqSize
+	}
+	var err error
+	r.ringMem, err = unix.Mmap(r.fd, ioURingOffSQRing, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		_ = unix.Close(r.fd)
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	r.sqeMem, err = unix.Mmap(r.fd, ioURingOffSQEs, int(params.sqEntries)*int(unsafe.Sizeof(ioURingSQE{})),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		_ = unix.Munmap(r.ringMem)
+		_ = unix.Close(r.fd)
+		return nil, os.NewSyscallError("mmap", err)
+	}
+
+	base := unsafe.Pointer(&r.ringMem[0])
+	r.sqHead = (*uint32)(unsafe.Add(base, params.sqOff.head))
+	r.sqTail = (*uint32)(unsafe.Add(base, params.sqOff.tail))
+	r.sqMask = *(*uint32)(unsafe.Add(base, params.sqOff.ringMask))
+	r.sqSize = params.sqEntries
+	r.sqes = unsafe.Slice((*ioURingSQE)(unsafe.Pointer(&r.sqeMem[0])), params.sqEntries)
+	// Map the slots of the submission queue to the SQEs one to one.
+	array := unsafe.Slice((*uint32)(unsafe.Add(base, params.sqOff.array)), params.sqEntries)
+	for i := range array {
+		array[i] = uint32(i)
+	}
+	r.cqHead = (*uint32)(unsafe.Add(base, params.cqOff.head))
+	r.cqTail = (*uint32)(unsafe.Add(base, params.cqOff.tail))
+	r.cqMask = *(*uint32)(unsafe.Add(base, params.cqOff.ringMask))
+	r.cqes = unsafe.Slice((*ioURingCQE)(unsafe.Add(base, params.cqOff.cqes)), params.cqEntries)
+
+	r.tail = atomic.LoadUint32(r.sqTail)
+	r.submitted = r.tail
+	r.reqs = make(map[uint64]*ioURingRequest)
+	r.files = make(map[int]*ioURingFile)
+	r.events = make([]ioURingCQE, 0, InitPollEventsCap)
+	if err = r.provideBuffers(); err != nil {
+		_ = r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// provideBuffers registers the buffer ring from which IORING_OP_RECV picks the buffers.
+func (r *ioURing) provideBuffers() (err error) {
+	size := ioURingBufEntries * int(unsafe.Sizeof(ioURingBuf{}))
+	if r.bufRing, err = unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS); err != nil {
+		return os.NewSyscallError("mmap", err)
+	}
+	if r.bufs, err = unix.Mmap(-1, 0, ioURingBufEntries*ioURingBufSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS); err != nil {
+		return os.NewSyscallError("mmap", err)
+	}
+	reg := ioURingBufReg{
+		ringAddr:    uint64(uintptr(unsafe.Pointer(&r.bufRing[0]))),
+		ringEntries: ioURingBufEntries,
+		bgid:        ioURingBufGroup,
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_IO_URING_REGISTER, uintptr(r.fd), ioURingRegisterPBufRing, uintptr(unsafe.Pointer(&reg)), 1, 0, 0)
+	if errno != 0 {
+		return os.NewSyscallError("io_uring_register", errno)
+	}
+	for bid := 0; bid < ioURingBufEntries; bid++ {
+		r.recycle(uint16(bid))
+	}
+	r.publish()
+	return nil
+}
+
+// recycle puts the provided buffer back to the ring, it becomes
+// available to the kernel after the next call to publish.
+func (r *ioURing) recycle(bid uint16) {
+	buf := (*ioURingBuf)(unsafe.Pointer(&r.bufRing[int(r.bufTail&(ioURingBufEntries-1))*int(unsafe.Sizeof(ioURingBuf{}))]))
+	buf.addr = uint64(uintptr(unsafe.Pointer(&r.bufs[int(bid)*ioURingBufSize])))
+	buf.len = ioURingBufSize
+	buf.bid = bid
+	r.bufTail++
+}
+
+// publish stores the tail of the buffer ring, which shares the 32-bit word with
+// the bid of the first entry, so it's stored along with it to be atomic.
+func (r *ioURing) publish() {
+	first := (*ioURingBuf)(unsafe.Pointer(&r.bufRing[0]))
+	v := uint32(first.bid) | uint32(r.bufTail)<<16
+	if isBigEndian {
+		v = uint32(first.bid)<<16 | uint32(r.bufTail)
+	}
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(&first.bid)), v)
+}
+
+func (r *ioURing) buffer(bid uint16) []byte {
+	return r.bufs[int(bid)*ioURingBufSize : (int(bid)+1)*ioURingBufSize]
+}
+
+func (r *ioURing) close() error {
+	for _, f := range r.files {
+		r.discard(f)
+	}
+	if r.bufs != nil {
+		_ = unix.Munmap(r.bufs)
+	}
+	if r.bufRing != nil {
+		_ = unix.Munmap(r.bufRing)
+	}
+	_ = unix.Munmap(r.sqeMem)
+	_ = unix.Munmap(r.ringMem)
+	return os.NewSyscallError("close", unix.Close(r.fd))
+}
+
+func (r *ioURing) enter(toSubmit, minComplete, flags uint32, arg unsafe.Pointer, argSize uintptr) (int, error) {
+	n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd),
+		uintptr(toSubmit), uintptr(minComplete), uintptr(flags), uintptr(arg), argSize)
+	if errno != 0 {
+		return int(n), errno
+	}
+	return int(n), nil
+}
+
+// submit publishes the pending SQEs to the kernel and submits them.
+func (r *ioURing) submit() error {
+	for r.submitted != r.tail {
+		atomic.StoreUint32(r.sqTail, r.tail)
+		n, err := r.enter(r.tail-r.submitted, 0, 0, nil, 0)
+		if err != nil {
+			if err == unix.EINTR || err == unix.EAGAIN || err == unix.EBUSY {
+				continue
+			}
+			return os.NewSyscallError("io_uring_enter", err)
+		}
+		r.submitted += uint32(n)
+	}
+	return nil
+}
+
+// getSQE returns a zeroed SQE from the submission queue,
+// the pending SQEs are submitted when the queue is full.
+func (r *ioURing) getSQE() (*ioURingSQE, error) {
+	if r.tail-atomic.LoadUint32(r.sqHead) >= r.sqSize {
+		if err := r.submit(); err != nil {
+			return nil, err
+		}
+	}
+	sqe := &r.sqes[r.tail&r.sqMask]
+	*sqe = ioURingSQE{}
+	r.tail++
+	return sqe, nil
+}
+
+// request queues up a request of f with a zeroed SQE.
+func (r *ioURing) request(f *ioURingFile, op uint8) (*ioURingRequest, *ioURingSQE, error) {
+	sqe, err := r.getSQE()
+	if err != nil {
+		return nil, nil, err
+	}
+	r.seq++
+	req := &ioURingRequest{op: op, seq: r.seq, file: f}
+	r.reqs[req.seq] = req
+	sqe.opcode = op
+	sqe.fd = int32(f.pa.FD)
+	sqe.userData = req.seq
+	return req, sqe, nil
+}
+
+// cancel cancels the pending request, the completion of it is still
+// reaped to release the resources held by it.
+func (r *ioURing) cancel(req *ioURingRequest) error {
+	if req == nil || req.cancelled {
+		return nil
+	}
+	req.cancelled = true
+	sqe, err := r.getSQE()
+	if err != nil {
+		return err
+	}
+	sqe.opcode = ioURingOpAsyncCancel
+	sqe.fd = -1
+	sqe.addr = req.seq
+	return nil
+}
+
+func (r *ioURing) armPoll(f *ioURingFile, events uint32, multishot bool) error {
+	req, sqe, err := r.request(f, ioURingOpPollAdd)
+	if err != nil {
+		return err
+	}
+	if isBigEndian {
+		events = events<<16 | events>>16
+	}
+	sqe.opFlags = events
+	if multishot {
+		sqe.len = ioURingPollAddMulti
+	}
+	f.poll = req
+	return nil
+}
+
+func (r *ioURing) armAccepts(f *ioURingFile) error {
+	for len(f.accepts) < ioURingAcceptDepth {
+		req, sqe, err := r.request(f, ioURingOpAccept)
+		if err != nil {
+			return err
+		}
+		req.addrLen = unix.SizeofSockaddrAny
+		sqe.addr = uint64(uintptr(unsafe.Pointer(&req.addr)))
+		sqe.off = uint64(uintptr(unsafe.Pointer(&req.addrLen)))
+		sqe.opFlags = unix.SOCK_NONBLOCK | unix.SOCK_CLOEXEC
+		f.accepts = append(f.accepts, req)
+	}
+	return nil
+}
+
+func (r *ioURing) armRecv(f *ioURingFile) error {
+	if f.recv != nil || f.starved || f.eof || f.readErr != nil {
+		return nil
+	}
+	req, sqe, err := r.request(f, ioURingOpRecv)
+	if err != nil {
+		return err
+	}
+	sqe.ioprio = ioURingRecvMultishot
+	sqe.flags = ioURingSQEBufferSelect
+	sqe.bufIndex = ioURingBufGroup
+	f.recv = req
+	return nil
+}
+
+// watchWritable monitors the writable event of f while there is no pending write.
+func (r *ioURing) watchWritable(f *ioURingFile) error {
+	if f.deleted || f.events&WriteEvents == 0 || f.write != nil || f.writeDone || f.poll != nil {
+		return nil
+	}
+	return r.armPoll(f, unix.EPOLLOUT, f.edge)
+}
+
+func (r *ioURing) markReady(f *ioURingFile) {
+	if !f.ready && !f.deleted {
+		f.ready = true
+		r.ready = append(r.ready, f)
+	}
+}
+
+// add registers pa with the given events, the events are edge-triggered if they contain EPOLLET.
+func (r *ioURing) add(pa *PollAttachment, events uint32) error {
+	if f, ok := r.files[pa.FD]; ok {
+		if !f.detached {
+			return os.NewSyscallError("io_uring poll_add", unix.EEXIST)
+		}
+		if f.pa == pa {
+			// Resume accepting on the listening socket with the sockets accepted before.
+			f.detached = false
+			if err := r.update(f, events); err != nil {
+				return err
+			}
+			if f.readable() {
+				r.markReady(f)
+			}
+			return nil
+		}
+		// The file descriptor has been closed and reused.
+		r.discard(f)
+	}
+	f := &ioURingFile{pa: pa}
+	r.files[pa.FD] = f
+	return r.update(f, events)
+}
+
+func (r *ioURing) mod(pa *PollAttachment, events uint32) error {
+	f, ok := r.files[pa.FD]
+	if !ok || f.detached {
+		return os.NewSyscallError("io_uring poll_add", unix.ENOENT)
+	}
+	f.pa = pa
+	return r.update(f, events)
+}
+
+func (r *ioURing) update(f *ioURingFile, events uint32) (err error) {
+	edge := events&unix.EPOLLET != 0
+	events &^= unix.EPOLLET
+	switch f.pa.Submission {
+	case SubmitAccept:
+		f.events, f.edge = events, edge
+		if events&ReadEvents == 0 {
+			for _, req := range f.accepts {
+				if err = r.cancel(req); err != nil {
+					return
+				}
+			}
+			f.accepts = f.accepts[:0]
+		} else if err = r.armAccepts(f); err != nil {
+			return
+		}
+	case SubmitStream:
+		if f.edge != edge {
+			if err = r.cancel(f.poll); err != nil {
+				return
+			}
+			f.poll = nil
+		}
+		f.events, f.edge = events, edge
+		if events&ReadEvents == 0 {
+			if err = r.cancel(f.recv); err != nil {
+				return
+			}
+			f.recv = nil
+		} else if err = r.armRecv(f); err != nil {
+			return
+		}
+		if events&WriteEvents == 0 {
+			if err = r.cancel(f.poll); err != nil {
+				return
+			}
+			f.poll = nil
+		} else if err = r.watchWritable(f); err != nil {
+			return
+		}
+	default:
+		if err = r.cancel(f.poll); err != nil {
+			return
+		}
+		f.poll = nil
+		f.events, f.edge = events, edge
+		return r.armPoll(f, events, edge)
+	}
+	if !edge && f.pending() != 0 {
+		r.markReady(f)
+	}
+	return
+}
+
+// del unregisters fd and submits the cancellations right away, a pending request
+// holds a reference to the file, which would otherwise stop it from being released
+// after fd is closed. The data received or the sockets accepted but not taken yet
+// are discarded.
+func (r *ioURing) del(fd int) error {
+	f, ok := r.files[fd]
+	if !ok {
+		return os.NewSyscallError("io_uring poll_remove", unix.ENOENT)
+	}
+	delete(r.files, fd)
+	if f.detached {
+		r.discard(f)
+		return nil
+	}
+	reqs := append([]*ioURingRequest{f.poll, f.recv, f.write}, f.accepts...)
+	for _, req := range reqs {
+		if err := r.cancel(req); err != nil {
+			return err
+		}
+	}
+	r.discard(f)
+	return r.submit()
+}
+
+// detach is del except that a listening socket registered with SubmitAccept stays registered
+// without events, the sockets accepted but not taken yet are still taken by Poller.Accept,
+// and they're queued up again if the same PollAttachment is added back later.
+func (r *ioURing) detach(fd int) error {
+	f, ok := r.files[fd]
+	if !ok || f.detached {
+		return os.NewSyscallError("io_uring poll_remove", unix.ENOENT)
+	}
+	if f.pa.Submission != SubmitAccept {
+		return r.del(fd)
+	}
+	for _, req := range f.accepts {
+		if err := r.cancel(req); err != nil {
+			return err
+		}
+	}
+	f.accepts, f.events, f.detached = nil, 0, true
+	return r.submit()
+}
+
+// discard releases the resources held by the registration f which has been removed.
+func (r *ioURing) discard(f *ioURingFile) {
+	f.deleted = true
+	f.poll, f.recv, f.write, f.accepts = nil, nil, nil, nil
+	for _, c := range f.chunks {
+		r.recycle(c.bid)
+	}
+	if len(f.chunks) > 0 {
+		r.publish()
+	}
+	for _, a := range f.accepted {
+		if a.fd >= 0 {
+			_ = unix.Close(a.fd)
+		}
+	}
+	f.chunks, f.accepted = nil, nil
+}
+
+// complete handles the completion of a request of a file descriptor registered with a Submission.
+func (r *ioURing) complete(req *ioURingRequest, cqe *ioURingCQE) {
+	f := req.file
+	more := cqe.flags&ioURingCQEFMore != 0
+	var errno unix.Errno
+	if cqe.res < 0 {
+		errno = unix.Errno(-cqe.res)
+	}
+	switch req.op {
+	case ioURingOpPollAdd:
+		if !more && f.poll == req {
+			f.poll = nil
+		}
+		if f.deleted || req.cancelled {
+			return
+		}
+		if cqe.res < 0 {
+			f.revents |= unix.EPOLLERR
+		} else {
+			f.revents |= uint32(cqe.res)
+		}
+		r.markReady(f)
+	case ioURingOpAccept:
+		for i, a := range f.accepts {
+			if a == req {
+				f.accepts = append(f.accepts[:i], f.accepts[i+1:]...)
+				break
+			}
+		}
+		switch {
+		case cqe.res >= 0:
+			// The socket accepted before the cancellation is kept as well.
+			if f.deleted {
+				_ = unix.Close(int(cqe.res))
+				return
+			}
+			f.accepted = append(f.accepted, ioURingAccepted{fd: int(cqe.res), sa: sockaddr(&req.addr)})
+		case f.deleted || req.cancelled || errno == unix.ECANCELED:
+			return
+		case errno == unix.EINTR || errno == unix.EAGAIN || errno == unix.ECONNABORTED || errno == unix.ECONNRESET:
+		default:
+			// The error is left to the caller of Poller.Accept without accepting any further.
+			f.accepted = append(f.accepted, ioURingAccepted{fd: -1, err: os.NewSyscallError("accept", errno)})
+			r.markReady(f)
+			return
+		}
+		r.markReady(f)
+		if !req.cancelled && f.events&ReadEvents != 0 {
+			if err := r.armAccepts(f); err != nil {
+				logging.Errorf("failed to re-arm accept requests on fd=%d, %v", f.pa.FD, err)
+			}
+		}
+	case ioURingOpRecv:
+		if !more && f.recv == req {
+			f.recv = nil
+		}
+		if cqe.flags&ioURingCQEFBuffer != 0 {
+			bid := uint16(cqe.flags >> ioURingCQEBufferShift)
+			if f.deleted || cqe.res <= 0 {
+				r.recycle(bid)
+				r.publish()
+			} else {
+				f.chunks = append(f.chunks, ioURingChunk{bid: bid, data: r.buffer(bid)[:cqe.res]})
+			}
+		}
+		if f.deleted {
+			return
+		}
+		switch {
+		case cqe.res > 0:
+		case cqe.res == 0:
+			f.eof = true
+		case errno == unix.ENOBUFS:
+			f.starved = true
+			r.starved = append(r.starved, f)
+			return
+		case errno == unix.ECANCELED:
+			return
+		default:
+			f.readErr = os.NewSyscallError("recv", errno)
+		}
+		r.markReady(f)
+		if f.recv == nil && !req.cancelled && f.events&ReadEvents != 0 {
+			if err := r.armRecv(f); err != nil {
+				logging.Errorf("failed to re-arm receive request on fd=%d, %v", f.pa.FD, err)
+			}
+		}
+	case ioURingOpWrite:
+		bsPool.Put(req.buf)
+		req.buf = nil
+		if f.write == req {
+			f.write = nil
+		}
+		if f.deleted || req.cancelled {
+			return
+		}
+		if cqe.res >= 0 {
+			f.written = int(cqe.res)
+		} else {
+			f.writeErr = os.NewSyscallError("write", errno)
+		}
+		f.writeDone = true
+		r.markReady(f)
+	}
+}
+
+func (r *ioURing) accept(f *ioURingFile) (int, unix.Sockaddr, error) {
+	if len(f.accepted) == 0 {
+		return -1, nil, unix.EAGAIN
+	}
+	a := f.accepted[0]
+	f.accepted = f.accepted[:copy(f.accepted, f.accepted[1:])]
+	return a.fd, a.sa, a.err
+}
+
+func (r *ioURing) read(f *ioURingFile, buf []byte) (n int, err error) {
+	if len(f.chunks) == 0 {
+		switch {
+		case f.readErr != nil:
+			return 0, f.readErr
+		case f.eof:
+			return 0, nil
+		}
+		return 0, unix.EAGAIN
+	}
+	for n < len(buf) && len(f.chunks) > 0 {
+		c := &f.chunks[0]
+		m := copy(buf[n:], c.data)
+		n += m
+		if c.data = c.data[m:]; len(c.data) == 0 {
+			r.recycle(c.bid)
+			f.chunks = f.chunks[:copy(f.chunks, f.chunks[1:])]
+		}
+	}
+	if len(f.chunks) == 0 {
+		r.publish()
+		r.feedStarved()
+	}
+	return
+}
+
+// feedStarved resumes the receiving of the files that ran out of the provided buffers.
+func (r *ioURing) feedStarved() {
+	starved := r.starved
+	r.starved = nil
+	for _, f := range starved {
+		f.starved = false
+		if f.deleted || f.events&ReadEvents == 0 {
+			continue
+		}
+		if err := r.armRecv(f); err != nil {
+			logging.Errorf("failed to re-arm receive request on fd=%d, %v", f.pa.FD, err)
+		}
+	}
+}
+
+func (r *ioURing) writev(f *ioURingFile, iov [][]byte) (int, error) {
+	if f.writeDone {
+		n, err := f.written, f.writeErr
+		f.written, f.writeErr, f.writeDone = 0, nil, false
+		return n, err
+	}
+	if f.write != nil {
+		return 0, unix.EAGAIN
+	}
+	size := 0
+	for _, b := range iov {
+		size += len(b)
+	}
+	if size > ioURingMaxWriteSize {
+		size = ioURingMaxWriteSize
+	}
+	if size == 0 {
+		return 0, nil
+	}
+	buf := bsPool.Get(size)
+	n := 0
+	for _, b := range iov {
+		if n += copy(buf[n:], b); n == size {
+			break
+		}
+	}
+	req, sqe, err := r.request(f, ioURingOpWrite)
+	if err != nil {
+		bsPool.Put(buf)
+		return 0, err
+	}
+	req.buf = buf
+	sqe.addr = uint64(uintptr(unsafe.Pointer(&buf[0])))
+	sqe.len = uint32(size)
+	sqe.off = ^uint64(0)
+	f.write = req
+	return 0, unix.EAGAIN
+}
+
+// sockaddr converts the peer address of IORING_OP_ACCEPT the same way as unix.Accept4.
+func sockaddr(rsa *unix.RawSockaddrAny) unix.Sockaddr {
+	switch rsa.Addr.Family {
+	case unix.AF_INET:
+		pp := (*unix.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		sa := &unix.SockaddrInet4{Addr: pp.Addr}
+		p := (*[2]byte)(unsafe.Pointer(&pp.Port))
+		sa.Port = int(p[0])<<8 + int(p[1])
+		return sa
+	case unix.AF_INET6:
+		pp := (*unix.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		sa := &unix.SockaddrInet6{Addr: pp.Addr, ZoneId: pp.Scope_id}
+		p := (*[2]byte)(unsafe.Pointer(&pp.Port))
+		sa.Port = int(p[0])<<8 + int(p[1])
+		return sa
+	case unix.AF_UNIX:
+		pp := (*unix.RawSockaddrUnix)(unsafe.Pointer(rsa))
+		if pp.Path[0] == 0 {
+			// Rewrite the leading NUL of the abstract address as @.
+			pp.Path[0] = '@'
+		}
+		n := 0
+		for n < len(pp.Path) && pp.Path[n] != 0 {
+			n++
+		}
+		return &unix.SockaddrUnix{Name: string(unsafe.Slice((*byte)(unsafe.Pointer(&pp.Path[0])), n))}
+	}
+	return nil
+}
+
+// submission returns the registration of fd if it's registered with s to the io_uring poller.
+func (p *Poller) submission(fd int, s Submission) *ioURingFile {
+	if p.ring == nil {
+		return nil
+	}
+	if f := p.ring.files[fd]; f != nil && f.pa.Submission == s {
+		return f
+	}
+	return nil
+}
+
+// IOURing reports whether the poller is backed by io_uring.
+func (p *Poller) IOURing() bool {
+	return p.ring != nil
+}
+
+// Accept takes a connection accepted from the listening socket registered with SubmitAccept,
+// or accepts one with accept4(2) otherwise.
+func (p *Poller) Accept(fd int) (int, unix.Sockaddr, error) {
+	if f := p.submission(fd, SubmitAccept); f != nil {
+		return p.ring.accept(f)
+	}
+	return socket.Accept(fd)
+}
+
+// Read takes the data received from the stream socket registered with SubmitStream,
+// or reads with read(2) otherwise.
+func (p *Poller) Read(fd int, buf []byte) (int, error) {
+	if f := p.submission(fd, SubmitStream); f != nil {
+		return p.ring.read(f, buf)
+	}
+	return unix.Read(fd, buf)
+}
+
+// Write submits the data to the stream socket registered with SubmitStream,
+// or writes with write(2) otherwise.
+func (p *Poller) Write(fd int, buf []byte) (int, error) {
+	if f := p.submission(fd, SubmitStream); f != nil {
+		return p.ring.writev(f, [][]byte{buf})
+	}
+	return unix.Write(fd, buf)
+}
+
+// Writev submits the data to the stream socket registered with SubmitStream,
+// or writes with writev(2) otherwise.
+func (p *Poller) Writev(fd int, iov [][]byte) (int, error) {
+	if f := p.submission(fd, SubmitStream); f != nil {
+		return p.ring.writev(f, iov)
+	}
+	return gio.Writev(fd, iov)
+}
+
+// wait submits the pending SQEs and waits for completions for up to msec milliseconds,
+// it returns the number of completions reaped into r.events.
+func (r *ioURing) wait(msec int) (int, error) {
+	var (
+		minComplete uint32
+		flags       uint32 = ioURingEnterGetEvents
+		arg         unsafe.Pointer
+		argSize     uintptr
+	)
+	if msec != 0 && atomic.LoadUint32(r.cqTail) == *r.cqHead {
+		minComplete = 1
+		if msec > 0 {
+			r.ts = unix.NsecToTimespec(int64(msec) * 1e6)
+			r.arg = ioURingGetEventsArg{ts: uint64(uintptr(unsafe.Pointer(&r.ts)))}
+			flags |= ioURingEnterExtArg
+			arg, argSize = unsafe.Pointer(&r.arg), unsafe.Sizeof(r.arg)
+		}
+	}
+	atomic.StoreUint32(r.sqTail, r.tail)
+	n, err := r.enter(r.tail-r.submitted, minComplete, flags, arg, argSize)
+	if n > 0 {
+		r.submitted += uint32(n)
+	}
+	switch err {
+	case nil, unix.EINTR, unix.ETIME, unix.EAGAIN, unix.EBUSY:
+	default:
+		return 0, err
+	}
+
+	r.events = r.events[:0]
+	head, tail := *r.cqHead, atomic.LoadUint32(r.cqTail)
+	for ; head != tail; head++ {
+		r.events = append(r.events, r.cqes[head&r.cqMask])
+	}
+	atomic.StoreUint32(r.cqHead, head)
+	return len(r.events), nil
+}
+
+// pollingURing is the counterpart of Polling for the io_uring poller,
+// dispatch delivers the readiness of a file descriptor to its handler.
+func (p *Poller) pollingURing(efd int, dispatch func(*PollAttachment, IOEvent) error) error {
+	r := p.ring
+	var doChores bool
+
+	msec := -1
+	for {
+		if len(r.ready) > 0 {
+			msec = 0
+		}
+		n, err := r.wait(p.timeout(msec))
+		p.now = time.Now()
+		if n == 0 && len(r.ready) == 0 && err == nil {
+			msec = -1
+			if err = p.runTimers(); err != nil {
+				return err
+			}
+			runtime.Gosched()
+			continue
+		} else if err != nil {
+			logging.Errorf("error occurs in io_uring: %v", os.NewSyscallError("io_uring_enter", err))
+			return err
+		}
+		msec = 0
+
+		for i := range r.events {
+			cqe := &r.events[i]
+			req, ok := r.reqs[cqe.userData]
+			if !ok {
+				continue // completions of cancellations
+			}
+			if cqe.flags&ioURingCQEFMore == 0 {
+				delete(r.reqs, req.seq)
+			}
+			f := req.file
+			if f.pa.Submission != SubmitNone {
+				r.complete(req, cqe)
+				continue
+			}
+			if cqe.flags&ioURingCQEFMore == 0 && f.poll == req {
+				f.poll = nil
+			}
+			if f.deleted || req.cancelled {
+				continue
+			}
+			if f.pa.FD == efd { // poller is awakened to run tasks in queues.
+				doChores = true
+			} else {
+				ev := IOEvent(cqe.res)
+				if cqe.res < 0 {
+					ev = unix.EPOLLERR
+				}
+				err = dispatch(f.pa, ev)
+				if errors.Is(err, errorx.ErrAcceptSocket) || errors.Is(err, errorx.ErrEngineShutdown) {
+					return err
+				}
+				if cqe.res < 0 {
+					continue
+				}
+			}
+			// Re-arm the poll request unless it's still pending, or it has been
+			// modified or removed during the callback.
+			if f.poll == nil && !f.deleted {
+				if err = r.armPoll(f, f.events, f.edge); err != nil {
+					logging.Errorf("failed to re-arm poll request on fd=%d, %v", f.pa.FD, err)
+				}
+			}
+		}
+
+		// Notify the events of the file descriptors registered with a Submission, the ones
+		// that are level-triggered stay in the ready list until there is nothing to notify.
+		ready := r.ready
+		r.ready = r.spare[:0]
+		for _, f := range ready {
+			f.ready = false
+			if f.deleted {
+				continue
+			}
+			ev := f.revents | f.pending()
+			f.revents = 0
+			if ev != 0 {
+				err = dispatch(f.pa, IOEvent(ev))
+				if errors.Is(err, errorx.ErrAcceptSocket) || errors.Is(err, errorx.ErrEngineShutdown) {
+					return err
+				}
+			}
+			if f.deleted {
+				continue
+			}
+			if !f.edge && f.pending() != 0 {
+				r.markReady(f)
+			}
+			if err = r.watchWritable(f); err != nil {
+				logging.Errorf("failed to arm poll request on fd=%d, %v", f.pa.FD, err)
+			}
+		}
+		for i := range ready {
+			ready[i] = nil
+		}
+		r.spare = ready[:0]
+
+		if doChores {
+			doChores = false
+			task := p.urgentAsyncTaskQueue.Dequeue()
+			for ; task != nil; task = p.urgentAsyncTaskQueue.Dequeue() {
+				err = task.Exec(task.Param)
+				if errors.Is(err, errorx.ErrEngineShutdown) {
+					return err
+				}
+				queue.PutTask(task)
+			}
+			for i := 0; i < MaxAsyncTasksAtOneTime; i++ {
+				if task = p.asyncTaskQueue.Dequeue(); task == nil {
+					break
+				}
+				err = task.Exec(task.Param)
+				if errors.Is(err, errorx.ErrEngineShutdown) {
+					return err
+				}
+				queue.PutTask(task)
+			}
+			atomic.StoreInt32(&p.wakeupCall, 0)
+			if (!p.asyncTaskQueue.IsEmpty() || !p.urgentAsyncTaskQueue.IsEmpty()) && atomic.CompareAndSwapInt32(&p.wakeupCall, 0, 1) {
+				for {
+					_, err = unix.Write(efd, b)
+					if err == unix.EAGAIN {
+						_, _ = unix.Read(efd, p.efdBuf)
+						continue
+					}
+					if err != nil {
+						logging.Errorf("failed to notify next round of event-loop for leftover tasks, %v", os.NewSyscallError("write", err))
+					}
+					break
+				}
+			}
+		}
+
+		if err = p.runTimers(); err != nil {
+			return err
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"runtime"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -37,6 +38,9 @@ type Poller struct {
 	asyncTaskQueue              queue.AsyncTaskQueue // queue with low priority
 	urgentAsyncTaskQueue        queue.AsyncTaskQueue // queue with high priority
 	highPriorityEventsThreshold int32                // threshold of high-priority events
+	timers                      timerHeap            // timers ordered by expiration
+	ctxTimers                   []*Timer             // armed timers with a ctx that can be done
+	now                         time.Time            // time sampled after the latest wait for events
 }
 
 // OpenPoller instantiates a poller.
@@ -90,6 +94,11 @@ func (p *Poller) Trigger(priority queue.EventPriority, fn queue.Func, param any)
 	return os.NewSyscallError("kevent | write", err)
 }
 
+// TaskQueueLengths returns the number of pending tasks in urgentAsyncTaskQueue and asyncTaskQueue.
+func (p *Poller) TaskQueueLengths() (urgent, normal int) {
+	return int(p.urgentAsyncTaskQueue.Length()), int(p.asyncTaskQueue.Length())
+}
+
 // Polling blocks the current goroutine, monitoring the registered file descriptors and waiting for network I/O.
 // When I/O occurs on any of the file descriptors, the provided callback function is invoked.
 func (p *Poller) Polling(callback PollEventHandler) error {
@@ -97,13 +106,18 @@ func (p *Poller) Polling(callback PollEventHandler) error {
 
 	var (
 		ts       unix.Timespec
+		tts      unix.Timespec
 		tsp      *unix.Timespec
 		doChores bool
 	)
 	for {
-		n, err := unix.Kevent(p.fd, nil, el.events, tsp)
+		n, err := unix.Kevent(p.fd, nil, el.events, p.timespec(tsp, &tts))
+		p.now = time.Now()
 		if n == 0 || (n < 0 && err == unix.EINTR) {
 			tsp = nil
+			if err = p.runTimers(); err != nil {
+				return err
+			}
 			runtime.Gosched()
 			continue
 		} else if err != nil {
@@ -153,6 +167,10 @@ func (p *Poller) Polling(callback PollEventHandler) error {
 			}
 		}
 
+		if err = p.runTimers(); err != nil {
+			return err
+		}
+
 		if n == el.size {
 			el.expand()
 		} else if n < el.size>>1 {
@@ -199,26 +217,75 @@ func (p *Poller) AddWrite(pa *PollAttachment, edgeTriggered bool) error {
 }
 
 // ModRead modifies the given file descriptor with readable event in the poller.
-func (p *Poller) ModRead(pa *PollAttachment, _ bool) error {
+func (p *Poller) ModRead(pa *PollAttachment, edgeTriggered bool) error {
+	var flags IOFlags = unix.EV_ADD
+	if edgeTriggered {
+		flags |= unix.EV_CLEAR
+	}
+	// The readable event may have been deleted by ModWrite, so add it back.
 	_, err := unix.Kevent(p.fd, []unix.Kevent_t{
+		{Ident: keventIdent(pa.FD), Flags: flags, Filter: unix.EVFILT_READ},
 		{Ident: keventIdent(pa.FD), Flags: unix.EV_DELETE, Filter: unix.EVFILT_WRITE},
 	}, nil, nil)
+	if err == unix.ENOENT {
+		err = nil // the writable event was not registered
+	}
 	return os.NewSyscallError("kevent delete", err)
 }
 
 // ModReadWrite modifies the given file descriptor with readable and writable events in the poller.
 func (p *Poller) ModReadWrite(pa *PollAttachment, edgeTriggered bool) error {
+	var flags IOFlags = unix.EV_ADD
+	if edgeTriggered {
+		flags |= unix.EV_CLEAR
+	}
+	_, err := unix.Kevent(p.fd, []unix.Kevent_t{
+		{Ident: keventIdent(pa.FD), Flags: flags, Filter: unix.EVFILT_READ},
+		{Ident: keventIdent(pa.FD), Flags: flags, Filter: unix.EVFILT_WRITE},
+	}, nil, nil)
+	return os.NewSyscallError("kevent add", err)
+}
+
+// ModWrite modifies the given file descriptor with writable event in the poller.
+func (p *Poller) ModWrite(pa *PollAttachment, edgeTriggered bool) error {
 	var flags IOFlags = unix.EV_ADD
 	if edgeTriggered {
 		flags |= unix.EV_CLEAR
 	}
 	_, err := unix.Kevent(p.fd, []unix.Kevent_t{
 		{Ident: keventIdent(pa.FD), Flags: flags, Filter: unix.EVFILT_WRITE},
+		{Ident: keventIdent(pa.FD), Flags: unix.EV_DELETE, Filter: unix.EVFILT_READ},
 	}, nil, nil)
+	if err == unix.ENOENT {
+		err = nil // the readable event was not registered
+	}
 	return os.NewSyscallError("kevent add", err)
 }
 
+// ModNone modifies the given file descriptor with no events in the poller.
+func (p *Poller) ModNone(pa *PollAttachment) error {
+	return p.Detach(pa.FD)
+}
+
 // Delete removes the given file descriptor from the poller.
 func (*Poller) Delete(_ int) error {
 	return nil
 }
+
+// Detach removes the given file descriptor from the poller without closing it.
+// Unlike Delete, which is a no-op since closing a file descriptor removes it
+// from kqueue automatically, it deletes the readable and writable events of fd
+// from kqueue explicitly.
+func (p *Poller) Detach(fd int) error {
+	evs := []unix.Kevent_t{
+		{Ident: keventIdent(fd), Flags: unix.EV_DELETE, Filter: unix.EVFILT_READ},
+		{Ident: keventIdent(fd), Flags: unix.EV_DELETE, Filter: unix.EVFILT_WRITE},
+	}
+	// Delete the events one by one as either of them may not be registered.
+	for i := range evs {
+		if _, err := unix.Kevent(p.fd, evs[i:i+1], nil, nil); err != nil && err != unix.ENOENT {
+			return os.NewSyscallError("kevent delete", err)
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"runtime"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -38,6 +39,9 @@ type Poller struct {
 	asyncTaskQueue              queue.AsyncTaskQueue // queue with low priority
 	urgentAsyncTaskQueue        queue.AsyncTaskQueue // queue with high priority
 	highPriorityEventsThreshold int32                // threshold of high-priority events
+	timers                      timerHeap            // timers ordered by expiration
+	ctxTimers                   []*Timer             // armed timers with a ctx that can be done
+	now                         time.Time            // time sampled after the latest wait for events
 }
 
 // OpenPoller instantiates a poller.
@@ -91,6 +95,11 @@ func (p *Poller) Trigger(priority queue.EventPriority, fn queue.Func, param any)
 	return os.NewSyscallError("kevent | write", err)
 }
 
+// TaskQueueLengths returns the number of pending tasks in urgentAsyncTaskQueue and asyncTaskQueue.
+func (p *Poller) TaskQueueLengths() (urgent, normal int) {
+	return int(p.urgentAsyncTaskQueue.Length()), int(p.asyncTaskQueue.Length())
+}
+
 // Polling blocks the current goroutine, monitoring the registered file descriptors and waiting for network I/O.
 // When I/O occurs on any of the file descriptors, the provided callback function is invoked.
 func (p *Poller) Polling() error {
@@ -98,13 +107,18 @@ func (p *Poller) Polling() error {
 
 	var (
 		ts       unix.Timespec
+		tts      unix.Timespec
 		tsp      *unix.Timespec
 		doChores bool
 	)
 	for {
-		n, err := unix.Kevent(p.fd, nil, el.events, tsp)
+		n, err := unix.Kevent(p.fd, nil, el.events, p.timespec(tsp, &tts))
+		p.now = time.Now()
 		if n == 0 || (n < 0 && err == unix.EINTR) {
 			tsp = nil
+			if err = p.runTimers(); err != nil {
+				return err
+			}
 			runtime.Gosched()
 			continue
 		} else if err != nil {
@@ -155,6 +169,10 @@ func (p *Poller) Polling() error {
 			}
 		}
 
+		if err = p.runTimers(); err != nil {
+			return err
+		}
+
 		if n == el.size {
 			el.expand()
 		} else if n < el.size>>1 {
@@ -208,18 +226,45 @@ func (p *Poller) AddWrite(pa *PollAttachment, edgeTriggered bool) error {
 }
 
 // ModRead modifies the given file descriptor with readable event in the poller.
-func (p *Poller) ModRead(pa *PollAttachment, _ bool) error {
-	var evs [1]unix.Kevent_t
+func (p *Poller) ModRead(pa *PollAttachment, edgeTriggered bool) error {
+	// The readable event may have been deleted by ModWrite, so add it back.
+	var evs [2]unix.Kevent_t
 	evs[0].Ident = keventIdent(pa.FD)
-	evs[0].Filter = unix.EVFILT_WRITE
-	evs[0].Flags = unix.EV_DELETE
+	evs[0].Filter = unix.EVFILT_READ
+	evs[0].Flags = unix.EV_ADD
+	if edgeTriggered {
+		evs[0].Flags |= unix.EV_CLEAR
+	}
+	convertPollAttachment(unsafe.Pointer(&evs[0].Udata), pa)
+	evs[1].Ident = keventIdent(pa.FD)
+	evs[1].Filter = unix.EVFILT_WRITE
+	evs[1].Flags = unix.EV_DELETE
 	_, err := unix.Kevent(p.fd, evs[:], nil, nil)
+	if err == unix.ENOENT {
+		err = nil // the writable event was not registered
+	}
 	return os.NewSyscallError("kevent delete", err)
 }
 
 // ModReadWrite modifies the given file descriptor with readable and writable events in the poller.
 func (p *Poller) ModReadWrite(pa *PollAttachment, edgeTriggered bool) error {
-	var evs [1]unix.Kevent_t
+	var evs [2]unix.Kevent_t
+	evs[0].Ident = keventIdent(pa.FD)
+	evs[0].Filter = unix.EVFILT_READ
+	evs[0].Flags = unix.EV_ADD
+	if edgeTriggered {
+		evs[0].Flags |= unix.EV_CLEAR
+	}
+	convertPollAttachment(unsafe.Pointer(&evs[0].Udata), pa)
+	evs[1] = evs[0]
+	evs[1].Filter = unix.EVFILT_WRITE
+	_, err := unix.Kevent(p.fd, evs[:], nil, nil)
+	return os.NewSyscallError("kevent add", err)
+}
+
+// ModWrite modifies the given file descriptor with writable event in the poller.
+func (p *Poller) ModWrite(pa *PollAttachment, edgeTriggered bool) error {
+	var evs [2]unix.Kevent_t
 	evs[0].Ident = keventIdent(pa.FD)
 	evs[0].Filter = unix.EVFILT_WRITE
 	evs[0].Flags = unix.EV_ADD
@@ -227,11 +272,40 @@ func (p *Poller) ModReadWrite(pa *PollAttachment, edgeTriggered bool) error {
 		evs[0].Flags |= unix.EV_CLEAR
 	}
 	convertPollAttachment(unsafe.Pointer(&evs[0].Udata), pa)
+	evs[1].Ident = keventIdent(pa.FD)
+	evs[1].Filter = unix.EVFILT_READ
+	evs[1].Flags = unix.EV_DELETE
 	_, err := unix.Kevent(p.fd, evs[:], nil, nil)
+	if err == unix.ENOENT {
+		err = nil // the readable event was not registered
+	}
 	return os.NewSyscallError("kevent add", err)
 }
 
+// ModNone modifies the given file descriptor with no events in the poller.
+func (p *Poller) ModNone(pa *PollAttachment) error {
+	return p.Detach(pa.FD)
+}
+
 // Delete removes the given file descriptor from the poller.
 func (p *Poller) Delete(_ int) error {
 	return nil
 }
+
+// Detach removes the given file descriptor from the poller without closing it.
+// Unlike Delete, which is a no-op since closing a file descriptor removes it
+// from kqueue automatically, it deletes the readable and writable events of fd
+// from kqueue explicitly.
+func (p *Poller) Detach(fd int) error {
+	evs := []unix.Kevent_t{
+		{Ident: keventIdent(fd), Flags: unix.EV_DELETE, Filter: unix.EVFILT_READ},
+		{Ident: keventIdent(fd), Flags: unix.EV_DELETE, Filter: unix.EVFILT_WRITE},
+	}
+	// Delete the events one by one as either of them may not be registered.
+	for i := range evs {
+		if _, err := unix.Kevent(p.fd, evs[i:i+1], nil, nil); err != nil && err != unix.ENOENT {
+			return os.NewSyscallError("kevent delete", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package netpoll
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+	"github.com/panjf2000/gnet/v2/pkg/queue"
+)
+
+// monoStart is the reference point of the monotonic clock used by timers.
+var monoStart = time.Now()
+
+// ctxTimerSweepInterval is the longest time that the poller blocks for while there
+// are timers with a ctx that can be done, so that the timers are swept in time.
+const ctxTimerSweepInterval = time.Second
+
+// nanotime returns the current monotonic time in nanoseconds.
+func nanotime() int64 {
+	return int64(time.Since(monoStart))
+}
+
+// Now returns the time sampled by the poller right after the latest wait for events, it's
+// a coarse clock for the callbacks that saves calling time.Now on every I/O. It must be
+// called on the poller goroutine, and it falls back to time.Now before the first wait.
+func (p *Poller) Now() time.Time {
+	if p.now.IsZero() {
+		return time.Now()
+	}
+	return p.now
+}
+
+// Timer represents a task that is about to be executed by the poller
+// after a delay, and optionally repeatedly at a fixed period.
+//
+// A Timer must be armed on the poller via Poller.AddTimer, which is
+// not concurrency-safe, while Timer.Stop is concurrency-safe.
+type Timer struct {
+	when    int64           // monotonic time in nanoseconds when the timer expires
+	period  int64           // period in nanoseconds for periodic timers, 0 for one-shot timers
+	fn      queue.Func      // function to execute
+	param   any             // parameter of fn
+	index   int             // index in the timer heap, -1 if the timer is not armed
+	ctx     context.Context // the timer is removed once ctx is done, nil if ctx can't be done
+	cindex  int             // index in the ctx timers of the poller, -1 if ctx is nil or the timer is not armed
+	stopped atomic.Bool
+}
+
+// NewTimer creates a Timer that will execute fn with param after delay,
+// and then every period afterward if period is greater than 0.
+func NewTimer(delay, period time.Duration, fn queue.Func, param any) *Timer {
+	if delay < 0 {
+		delay = 0
+	}
+	if period < 0 {
+		period = 0
+	}
+	return &Timer{
+		when:   nanotime() + int64(delay),
+		period: int64(period),
+		fn:     fn,
+		param:  param,
+		index:  -1,
+		cindex: -1,
+	}
+}
+
+// NewContextTimer is like NewTimer, but the Timer is stopped and removed from
+// the poller once ctx is done, without waiting for it to expire.
+func NewContextTimer(ctx context.Context, delay, period time.Duration, fn queue.Func, param any) *Timer {
+	t := NewTimer(delay, period, fn, param)
+	if ctx != nil && ctx.Done() != nil {
+		t.ctx = ctx
+	}
+	return t
+}
+
+// Stop prevents the Timer from firing, it's concurrency-safe.
+// It returns true if the call stops the timer, false if the timer
+// has already expired or been stopped.
+//
+// A stopped timer is removed from the poller lazily when it expires,
+// call Poller.DelTimer on the poller goroutine to remove it eagerly.
+func (t *Timer) Stop() bool {
+	return t.stopped.CompareAndSwap(false, true)
+}
+
+// Stopped reports whether the Timer has been stopped or has expired (for one-shot timers).
+func (t *Timer) Stopped() bool {
+	return t.stopped.Load()
+}
+
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].when < h[j].when }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
+
+// AddTimer arms the given Timer on the poller.
+//
+// Note that this method is not concurrency-safe, it must be invoked on the
+// goroutine that runs Polling, use Trigger to arm a timer from other goroutines.
+func (p *Poller) AddTimer(t *Timer) {
+	if t.index >= 0 || t.stopped.Load() {
+		return
+	}
+	p.pushTimer(t)
+}
+
+// ResetTimer changes the Timer to expire after delay, re-arming it if it
+// has already expired or been stopped.
+//
+// Note that this method is not concurrency-safe, it must be invoked on the
+// goroutine that runs Polling.
+func (p *Poller) ResetTimer(t *Timer, delay time.Duration) {
+	if delay < 0 {
+		delay = 0
+	}
+	t.when = nanotime() + int64(delay)
+	t.stopped.Store(false)
+	if t.index >= 0 {
+		heap.Fix(&p.timers, t.index)
+	} else {
+		p.pushTimer(t)
+	}
+}
+
+// DelTimer stops the Timer and removes it from the poller, it returns false
+// if the timer has already expired or been stopped.
+//
+// Note that this method is not concurrency-safe, it must be invoked on the
+// goroutine that runs Polling.
+func (p *Poller) DelTimer(t *Timer) bool {
+	if t.index >= 0 {
+		heap.Remove(&p.timers, t.index)
+		p.untrackTimer(t)
+	}
+	return t.Stop()
+}
+
+// pushTimer pushes the Timer into the timer heap and keeps track of it
+// if it has a ctx, so that it can be swept once the ctx is done.
+func (p *Poller) pushTimer(t *Timer) {
+	heap.Push(&p.timers, t)
+	if t.ctx != nil {
+		t.cindex = len(p.ctxTimers)
+		p.ctxTimers = append(p.ctxTimers, t)
+	}
+}
+
+// untrackTimer stops keeping track of the Timer that has left the timer heap.
+func (p *Poller) untrackTimer(t *Timer) {
+	i := t.cindex
+	if i < 0 {
+		return
+	}
+	last := len(p.ctxTimers) - 1
+	p.ctxTimers[i] = p.ctxTimers[last]
+	p.ctxTimers[i].cindex = i
+	p.ctxTimers[last] = nil
+	p.ctxTimers = p.ctxTimers[:last]
+	t.cindex = -1
+}
+
+// sweepTimers removes the timers whose ctx is done from the poller.
+func (p *Poller) sweepTimers() {
+	for i := 0; i < len(p.ctxTimers); {
+		if t := p.ctxTimers[i]; t.ctx.Err() != nil {
+			p.DelTimer(t) // moves the last ctx timer to i
+			continue
+		}
+		i++
+	}
+}
+
+// earliest returns the duration in nanoseconds until the poller has to run timers,
+// it's only valid when there are timers on the poller.
+func (p *Poller) earliest() int64 {
+	d := p.timers[0].when - nanotime()
+	if len(p.ctxTimers) > 0 && d > int64(ctxTimerSweepInterval) {
+		d = int64(ctxTimerSweepInterval)
+	}
+	return d
+}
+
+// timeout returns the number of milliseconds that the poller is allowed to block
+// for, based on the given timeout msec and the earliest timer on the poller.
+func (p *Poller) timeout(msec int) int {
+	if msec == 0 || len(p.timers) == 0 {
+		return msec
+	}
+	d := p.earliest()
+	if d <= 0 {
+		return 0
+	}
+	// Round up to avoid waking up before the timer expires.
+	ms := int((d + int64(time.Millisecond) - 1) / int64(time.Millisecond))
+	if msec < 0 || ms < msec {
+		return ms
+	}
+	return msec
+}
+
+// runTimers executes all expired timers, it only returns errorx.ErrEngineShutdown
+// which indicates that the poller should exit.
+func (p *Poller) runTimers() error {
+	if len(p.timers) == 0 {
+		return nil
+	}
+	p.sweepTimers()
+	now := nanotime()
+	for len(p.timers) > 0 {
+		t := p.timers[0]
+		if t.when > now {
+			break
+		}
+		if t.period > 0 && !t.stopped.Load() {
+			if t.when += t.period; t.when <= now {
+				t.when = now + t.period // don't try to catch up with the missed periods
+			}
+			heap.Fix(&p.timers, 0)
+		} else {
+			heap.Pop(&p.timers)
+			p.untrackTimer(t)
+			if !t.stopped.CompareAndSwap(false, true) {
+				continue
+			}
+		}
+		if err := t.fn(t.param); errors.Is(err, errorx.ErrEngineShutdown) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package netpoll
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+)
+
+func TestTimerOrder(t *testing.T) {
+	p := new(Poller)
+	var fired []int
+	record := func(a any) error {
+		fired = append(fired, a.(int))
+		return nil
+	}
+	for i, delay := range []time.Duration{30, 10, 40, 0, 20} {
+		p.AddTimer(NewTimer(delay*time.Millisecond, 0, record, i))
+	}
+	require.Len(t, p.timers, 5)
+	for i, tm := range p.timers {
+		assert.Equal(t, i, tm.index)
+	}
+
+	assert.Zero(t, p.timeout(-1))
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, []int{3, 1, 4, 0, 2}, fired)
+	assert.Empty(t, p.timers)
+	assert.Equal(t, -1, p.timeout(-1))
+
+	// The poller doesn't block for longer than the earliest timer.
+	p.AddTimer(NewTimer(time.Hour, 0, record, 5))
+	p.AddTimer(NewTimer(time.Second, 0, record, 6))
+	assert.Equal(t, 10, p.timeout(10))
+	if ms := p.timeout(-1); assert.Positive(t, ms) {
+		assert.LessOrEqual(t, ms, 1000)
+	}
+}
+
+func TestTimerStop(t *testing.T) {
+	p := new(Poller)
+	var fired int
+	count := func(any) error {
+		fired++
+		return nil
+	}
+
+	// A stopped timer is left in the heap until it expires, but it doesn't fire.
+	t1 := NewTimer(time.Millisecond, 0, count, nil)
+	p.AddTimer(t1)
+	assert.True(t, t1.Stop())
+	assert.False(t, t1.Stop())
+	assert.True(t, t1.Stopped())
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, p.runTimers())
+	assert.Zero(t, fired)
+	assert.Empty(t, p.timers)
+
+	// A stopped timer can't be armed again.
+	p.AddTimer(t1)
+	assert.Empty(t, p.timers)
+
+	// DelTimer removes the timer from the heap right away.
+	t2 := NewTimer(time.Hour, 0, count, nil)
+	t3 := NewTimer(time.Hour, 0, count, nil)
+	p.AddTimer(t2)
+	p.AddTimer(t3)
+	assert.True(t, p.DelTimer(t2))
+	assert.False(t, p.DelTimer(t2))
+	require.Len(t, p.timers, 1)
+	assert.Same(t, t3, p.timers[0])
+	assert.Equal(t, -1, t2.index)
+
+	// A periodic timer stops firing once it's stopped.
+	t4 := NewTimer(0, time.Millisecond, count, nil)
+	p.AddTimer(t4)
+	p.DelTimer(t3)
+	for i := 0; i < 3; i++ {
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, p.runTimers())
+	}
+	assert.Equal(t, 3, fired)
+	assert.Len(t, p.timers, 1)
+	assert.True(t, t4.Stop())
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, 3, fired)
+	assert.Empty(t, p.timers)
+}
+
+func TestTimerReset(t *testing.T) {
+	p := new(Poller)
+	var fired int
+	count := func(any) error {
+		fired++
+		return nil
+	}
+
+	// An expired one-shot timer is re-armed by ResetTimer.
+	tm := NewTimer(0, 0, count, nil)
+	p.AddTimer(tm)
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, 1, fired)
+	assert.True(t, tm.Stopped())
+	assert.Empty(t, p.timers)
+	p.ResetTimer(tm, 0)
+	assert.False(t, tm.Stopped())
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, 2, fired)
+
+	// So is a stopped timer.
+	p.ResetTimer(tm, time.Hour)
+	p.DelTimer(tm)
+	p.ResetTimer(tm, 0)
+	require.Len(t, p.timers, 1)
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, 3, fired)
+
+	// An armed timer is postponed in place.
+	early := NewTimer(time.Millisecond, 0, count, nil)
+	p.AddTimer(early)
+	p.AddTimer(NewTimer(time.Hour, 0, count, nil))
+	p.ResetTimer(early, 2*time.Hour)
+	require.Len(t, p.timers, 2)
+	assert.NotSame(t, early, p.timers[0])
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, 3, fired)
+}
+
+func TestTimerContext(t *testing.T) {
+	p := new(Poller)
+	var fired int
+	count := func(any) error {
+		fired++
+		return nil
+	}
+
+	// A timer with a ctx that can't be done is an ordinary timer.
+	tm := NewContextTimer(context.Background(), time.Hour, 0, count, nil)
+	p.AddTimer(tm)
+	assert.Empty(t, p.ctxTimers)
+	p.DelTimer(tm)
+
+	// Timers are swept out of the poller once their ctx is done, long before they expire.
+	var cancels []context.CancelFunc
+	var timers []*Timer
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels = append(cancels, cancel)
+		tm := NewContextTimer(ctx, time.Hour, 0, count, nil)
+		timers = append(timers, tm)
+		p.AddTimer(tm)
+	}
+	require.Len(t, p.ctxTimers, 4)
+	assert.Equal(t, int(ctxTimerSweepInterval/time.Millisecond), p.timeout(-1))
+	cancels[1]()
+	cancels[3]()
+	require.NoError(t, p.runTimers())
+	assert.Len(t, p.timers, 2)
+	require.Len(t, p.ctxTimers, 2)
+	for i, tm := range p.ctxTimers {
+		assert.Equal(t, i, tm.cindex)
+	}
+	assert.True(t, timers[1].Stopped())
+	assert.True(t, timers[3].Stopped())
+	assert.False(t, timers[0].Stopped())
+
+	// Removing a timer from the heap, either by DelTimer or by expiring, stops tracking it.
+	p.DelTimer(timers[0])
+	p.ResetTimer(timers[2], 0)
+	require.NoError(t, p.runTimers())
+	assert.Equal(t, 1, fired)
+	assert.Empty(t, p.timers)
+	assert.Empty(t, p.ctxTimers)
+	cancels[0]()
+	cancels[2]()
+}
+
+func TestTimerShutdown(t *testing.T) {
+	p := new(Poller)
+	var fired int
+	p.AddTimer(NewTimer(0, 0, func(any) error {
+		fired++
+		return errorx.ErrEngineShutdown
+	}, nil))
+	p.AddTimer(NewTimer(0, 0, func(any) error {
+		fired++
+		return errorx.ErrEngineShutdown
+	}, nil))
+	assert.ErrorIs(t, p.runTimers(), errorx.ErrEngineShutdown)
+	assert.Equal(t, 1, fired)
+	assert.Len(t, p.timers, 1)
+}
@@ -123,6 +123,15 @@ func SockaddrToTCPOrUnixAddr(sa unix.Sockaddr) net.Addr {
 	return nil
 }
 
+// SockaddrToUnixAddr converts a unix.Sockaddr to a net.UnixAddr of the given network.
+// Returns nil if conversion fails.
+func SockaddrToUnixAddr(sa unix.Sockaddr, network string) net.Addr {
+	if sa, ok := sa.(*unix.SockaddrUnix); ok {
+		return &net.UnixAddr{Name: sa.Name, Net: network}
+	}
+	return nil
+}
+
 // SockaddrToUDPAddr converts a unix.Sockaddr to a net.UDPAddr
 // Returns nil if conversion fails.
 func SockaddrToUDPAddr(sa unix.Sockaddr) net.Addr {
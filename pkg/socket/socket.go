@@ -51,8 +51,9 @@ func UDPSocket(proto, addr string, connect bool, sockOptInts []Option[int], sock
 	return udpSocket(proto, addr, connect, sockOptInts, sockOptStrs)
 }
 
-// UnixSocket creates a Unix socket and returns a file descriptor that refers to it.
-// The given socket options will be set on the returned file descriptor.
+// UnixSocket creates a Unix socket of unix, unixgram, or unixpacket and returns
+// a file descriptor that refers to it. The given socket options will be set on
+// the returned file descriptor.
 func UnixSocket(proto, addr string, passive bool, sockOptInts []Option[int], sockOptStrs []Option[string]) (int, net.Addr, error) {
 	return udsSocket(proto, addr, passive, sockOptInts, sockOptStrs)
 }
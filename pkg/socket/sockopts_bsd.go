@@ -23,3 +23,14 @@ import errorx "github.com/panjf2000/gnet/v2/pkg/errors"
 func SetBindToDevice(_ int, _ string) error {
 	return errorx.ErrUnsupportedOp
 }
+
+// SetUDPGRO is not implemented on *BSD because there is
+// no equivalent of Linux's UDP_GRO.
+func SetUDPGRO(_, _ int) error {
+	return errorx.ErrUnsupportedOp
+}
+
+// SetUDPPacketInfo is not implemented on *BSD at the moment.
+func SetUDPPacketInfo(_, _ int) error {
+	return errorx.ErrUnsupportedOp
+}
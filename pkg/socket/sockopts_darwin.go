@@ -75,3 +75,27 @@ func SetKeepAlive(fd int, enabled bool, idle, intvl, cnt int) error {
 func SetBindToDevice(_ int, _ string) error {
 	return errorx.ErrUnsupportedOp
 }
+
+// SetUDPGRO is not implemented on macOS because there is
+// no equivalent of Linux's UDP_GRO.
+func SetUDPGRO(_, _ int) error {
+	return errorx.ErrUnsupportedOp
+}
+
+// SetUDPPacketInfo is not implemented on macOS at the moment.
+func SetUDPPacketInfo(_, _ int) error {
+	return errorx.ErrUnsupportedOp
+}
+
+// GetPeerCredentials returns the process ID, the user ID and the group ID of the peer
+// of the Unix domain socket with LOCAL_PEERPID and LOCAL_PEERCRED.
+func GetPeerCredentials(fd int) (pid, uid, gid int, err error) {
+	cred, err := unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	if err != nil {
+		return 0, 0, 0, os.NewSyscallError("getsockopt", err)
+	}
+	if pid, err = unix.GetsockoptInt(fd, unix.SOL_LOCAL, unix.LOCAL_PEERPID); err != nil {
+		return 0, 0, 0, os.NewSyscallError("getsockopt", err)
+	}
+	return pid, int(cred.Uid), int(cred.Groups[0]), nil
+}
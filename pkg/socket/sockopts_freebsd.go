@@ -27,3 +27,14 @@ import (
 func SetReuseport(fd, reusePort int) error {
 	return os.NewSyscallError("setsockopt", unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT_LB, reusePort))
 }
+
+// GetPeerCredentials returns the user ID and the group ID of the peer of the Unix domain
+// socket with LOCAL_PEERCRED, the process ID is always -1 since it's not reported by all
+// versions of FreeBSD.
+func GetPeerCredentials(fd int) (pid, uid, gid int, err error) {
+	cred, err := unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	if err != nil {
+		return 0, 0, 0, os.NewSyscallError("getsockopt", err)
+	}
+	return -1, int(cred.Uid), int(cred.Groups[0]), nil
+}
@@ -28,3 +28,44 @@ import (
 func SetBindToDevice(fd int, ifname string) error {
 	return os.NewSyscallError("setsockopt", unix.BindToDevice(fd, ifname))
 }
+
+// SetUDPGRO enables or disables UDP_GRO on the UDP socket, which has the kernel
+// coalesce the datagrams of the same flow into one large buffer.
+func SetUDPGRO(fd, gro int) error {
+	return os.NewSyscallError("setsockopt", unix.SetsockoptInt(fd, unix.SOL_UDP, unix.UDP_GRO, gro))
+}
+
+// GetPeerCredentials returns the process ID, the user ID and the group ID of the peer
+// of the Unix domain socket with SO_PEERCRED, which are taken when the socket is connected.
+func GetPeerCredentials(fd int) (pid, uid, gid int, err error) {
+	cred, err := unix.GetsockoptUcred(fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	if err != nil {
+		return 0, 0, 0, os.NewSyscallError("getsockopt", err)
+	}
+	return int(cred.Pid), int(cred.Uid), int(cred.Gid), nil
+}
+
+// SetUDPPacketInfo enables or disables the ancillary data of the datagrams received on
+// the UDP socket: IP_PKTINFO and IP_RECVTOS, along with IPV6_RECVPKTINFO and IPV6_RECVTCLASS
+// for IPv6 sockets, and the software receive timestamp of SO_TIMESTAMPING.
+func SetUDPPacketInfo(fd, on int) error {
+	domain, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_DOMAIN)
+	if err != nil {
+		return os.NewSyscallError("getsockopt", err)
+	}
+	// The IPv4 options also take effect on the IPv4 datagrams of a dual-stack socket.
+	opts := [][2]int{{unix.IPPROTO_IP, unix.IP_PKTINFO}, {unix.IPPROTO_IP, unix.IP_RECVTOS}}
+	if domain == unix.AF_INET6 {
+		opts = append(opts, [2]int{unix.IPPROTO_IPV6, unix.IPV6_RECVPKTINFO}, [2]int{unix.IPPROTO_IPV6, unix.IPV6_RECVTCLASS})
+	}
+	for _, opt := range opts {
+		if err = unix.SetsockoptInt(fd, opt[0], opt[1], on); err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+	}
+	flags := 0
+	if on != 0 {
+		flags = unix.SOF_TIMESTAMPING_RX_SOFTWARE | unix.SOF_TIMESTAMPING_SOFTWARE
+	}
+	return os.NewSyscallError("setsockopt", unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPING, flags))
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build dragonfly || netbsd || openbsd
+
+package socket
+
+import errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+
+// GetPeerCredentials is not implemented on DragonFlyBSD, NetBSD, and OpenBSD at the moment.
+func GetPeerCredentials(_ int) (pid, uid, gid int, err error) {
+	return 0, 0, 0, errorx.ErrUnsupportedOp
+}
@@ -34,7 +34,7 @@ func GetUnixSockAddr(proto, addr string) (sa unix.Sockaddr, family int, unixAddr
 	}
 
 	switch unixAddr.Network() {
-	case "unix":
+	case "unix", "unixgram", "unixpacket":
 		sa, family = &unix.SockaddrUnix{Name: unixAddr.Name}, unix.AF_UNIX
 	default:
 		err = errorx.ErrUnsupportedUDSProtocol
@@ -54,7 +54,14 @@ func udsSocket(proto, addr string, passive bool, sockOptInts []Option[int], sock
 		return
 	}
 
-	if fd, err = sysSocket(family, unix.SOCK_STREAM, 0); err != nil {
+	sotype := unix.SOCK_STREAM
+	switch proto {
+	case "unixgram":
+		sotype = unix.SOCK_DGRAM
+	case "unixpacket":
+		sotype = unix.SOCK_SEQPACKET
+	}
+	if fd, err = sysSocket(family, sotype, 0); err != nil {
 		err = os.NewSyscallError("socket", err)
 		return
 	}
@@ -80,6 +87,9 @@ func udsSocket(proto, addr string, passive bool, sockOptInts []Option[int], sock
 		if err = os.NewSyscallError("bind", unix.Bind(fd, sa)); err != nil {
 			return
 		}
+		if sotype == unix.SOCK_DGRAM {
+			return // there is no need to listen on a datagram socket
+		}
 
 		// Set backlog size to the maximum.
 		err = os.NewSyscallError("listen", unix.Listen(fd, listenerBacklogMaxSize))
@@ -0,0 +1,260 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+)
+
+// The types of the TLVs defined by the version 2 of the PROXY protocol.
+const (
+	ProxyTLVTypeALPN      byte = 0x01
+	ProxyTLVTypeAuthority byte = 0x02
+	ProxyTLVTypeCRC32C    byte = 0x03
+	ProxyTLVTypeNoop      byte = 0x04
+	ProxyTLVTypeUniqueID  byte = 0x05
+	ProxyTLVTypeSSL       byte = 0x20
+	ProxyTLVTypeNetNS     byte = 0x30
+)
+
+// ProxyHeader is the PROXY protocol header received at the beginning of a connection,
+// which is reported by Conn.ProxyHeader when ProxyProtocol is enabled.
+type ProxyHeader struct {
+	// Version is the version of the PROXY protocol, either 1 or 2.
+	Version int
+	// Local reports whether the header carries no addresses, which is the case for the
+	// UNKNOWN protocol of version 1, and the LOCAL command or the unspecified address family
+	// of version 2, the addresses of the connection are left untouched then.
+	Local bool
+	// SourceAddr is the address of the client, nil if it's not carried by the header.
+	SourceAddr net.Addr
+	// DestinationAddr is the address that the client connected to on the proxy,
+	// nil if it's not carried by the header.
+	DestinationAddr net.Addr
+	// TLVs are the Type-Length-Value vectors of version 2 in order of appearance.
+	TLVs []ProxyTLV
+}
+
+// ProxyTLV is a Type-Length-Value vector of the version 2 of the PROXY protocol.
+type ProxyTLV struct {
+	Type  byte
+	Value []byte
+}
+
+// TLV returns the value of the first TLV of the given type.
+func (h *ProxyHeader) TLV(typ byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == typ {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+const (
+	proxyV1Signature = "PROXY "
+	proxyV2Signature = "\r\n\r\n\x00\r\nQUIT\n"
+	// proxyV1MaxLength is the maximum length of a version 1 header, including the CRLF.
+	proxyV1MaxLength = 107
+	// proxyV2HeaderLength is the length of the fixed part of a version 2 header.
+	proxyV2HeaderLength = 16
+)
+
+// errNoProxyHeader indicates that the data doesn't start with a PROXY protocol header.
+var errNoProxyHeader = errors.New("gnet: no PROXY protocol header")
+
+// hasPrefixOf reports whether data and sig agree on their common prefix.
+func hasPrefixOf(data []byte, sig string) bool {
+	n := len(sig)
+	if len(data) < n {
+		n = len(data)
+	}
+	return string(data[:n]) == sig[:n]
+}
+
+// parseProxyHeader decodes the PROXY protocol header at the beginning of data, it returns
+// the header and its length, or a nil header if data is too short to hold the entire header.
+// errNoProxyHeader is returned if data doesn't start with a header at all.
+func parseProxyHeader(data []byte) (*ProxyHeader, int, error) {
+	switch {
+	case hasPrefixOf(data, proxyV2Signature):
+		return parseProxyHeaderV2(data)
+	case hasPrefixOf(data, proxyV1Signature):
+		return parseProxyHeaderV1(data)
+	default:
+		return nil, 0, errNoProxyHeader
+	}
+}
+
+func parseProxyHeaderV1(data []byte) (*ProxyHeader, int, error) {
+	end := bytes.Index(data, []byte("\r\n"))
+	if end < 0 {
+		if len(data) >= proxyV1MaxLength {
+			return nil, 0, errorx.ErrInvalidProxyHeader
+		}
+		return nil, 0, nil
+	}
+	if end+2 > proxyV1MaxLength {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+
+	h := &ProxyHeader{Version: 1}
+	fields := strings.Split(string(data[len(proxyV1Signature):end]), " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		// The rest of the line is ignored.
+		h.Local = true
+		return h, end + 2, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	if len(fields) != 5 {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	src, dst := net.ParseIP(fields[1]), net.ParseIP(fields[2])
+	if fields[0] == "TCP4" {
+		src, dst = src.To4(), dst.To4()
+	}
+	if src == nil || dst == nil {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	srcPort, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	dstPort, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	h.SourceAddr = &net.TCPAddr{IP: src, Port: int(srcPort)}
+	h.DestinationAddr = &net.TCPAddr{IP: dst, Port: int(dstPort)}
+	return h, end + 2, nil
+}
+
+func parseProxyHeaderV2(data []byte) (*ProxyHeader, int, error) {
+	if len(data) < proxyV2HeaderLength {
+		return nil, 0, nil
+	}
+	n := proxyV2HeaderLength + int(binary.BigEndian.Uint16(data[14:16]))
+	if len(data) < n {
+		return nil, 0, nil
+	}
+	if data[12]>>4 != 2 {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+
+	h := &ProxyHeader{Version: 2}
+	switch data[12] & 0x0f {
+	case 0x00: // LOCAL
+		h.Local = true
+	case 0x01: // PROXY
+	default:
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+
+	// Copy the payload so that the TLVs don't refer to the read buffer.
+	payload := append([]byte(nil), data[proxyV2HeaderLength:n]...)
+	family, transport := data[13]>>4, data[13]&0x0f
+	var addrLen int
+	switch family {
+	case 0x0: // AF_UNSPEC
+		h.Local = true
+	case 0x1: // AF_INET
+		addrLen = 2*net.IPv4len + 4
+	case 0x2: // AF_INET6
+		addrLen = 2*net.IPv6len + 4
+	case 0x3: // AF_UNIX
+		addrLen = 2 * 108
+	default:
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	if len(payload) < addrLen || transport > 0x2 {
+		return nil, 0, errorx.ErrInvalidProxyHeader
+	}
+	if !h.Local {
+		h.SourceAddr, h.DestinationAddr = proxyAddrs(family, transport, payload[:addrLen])
+	}
+
+	for tlvs := payload[addrLen:]; len(tlvs) > 0; {
+		if len(tlvs) < 3 {
+			return nil, 0, errorx.ErrInvalidProxyHeader
+		}
+		size := 3 + int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if len(tlvs) < size {
+			return nil, 0, errorx.ErrInvalidProxyHeader
+		}
+		h.TLVs = append(h.TLVs, ProxyTLV{Type: tlvs[0], Value: tlvs[3:size:size]})
+		tlvs = tlvs[size:]
+	}
+	return h, n, nil
+}
+
+// proxyAddrs decodes the source and destination addresses of a version 2 header.
+func proxyAddrs(family, transport byte, b []byte) (src, dst net.Addr) {
+	if family == 0x3 {
+		network := "unix"
+		if transport == 0x2 {
+			network = "unixgram"
+		}
+		name := func(b []byte) string {
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				b = b[:i]
+			}
+			return string(b)
+		}
+		return &net.UnixAddr{Name: name(b[:108]), Net: network}, &net.UnixAddr{Name: name(b[108:]), Net: network}
+	}
+
+	ipLen := (len(b) - 4) / 2
+	srcIP, dstIP := net.IP(b[:ipLen]), net.IP(b[ipLen:2*ipLen])
+	srcPort := int(binary.BigEndian.Uint16(b[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(b[2*ipLen+2:]))
+	if transport == 0x2 {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}
+}
+
+// isTrustedProxy reports whether addr belongs to one of the trusted networks,
+// every address is trusted if there is no trusted network at all, which is only
+// allowed in the ProxyProtocolRequired mode.
+func isTrustedProxy(addr net.Addr, cidrs []*net.IPNet) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UnixAddr:
+		return true
+	default:
+		return false
+	}
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnet
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+)
+
+// proxyHeaderV2 builds a version 2 header with the given command, family,
+// transport, address block and TLVs.
+func proxyHeaderV2(cmd, famTrans byte, addrs []byte, tlvs ...ProxyTLV) []byte {
+	payload := append([]byte(nil), addrs...)
+	for _, tlv := range tlvs {
+		payload = append(payload, tlv.Type, 0, 0)
+		binary.BigEndian.PutUint16(payload[len(payload)-2:], uint16(len(tlv.Value)))
+		payload = append(payload, tlv.Value...)
+	}
+	b := append([]byte(proxyV2Signature), 0x20|cmd, famTrans, 0, 0)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(payload)))
+	return append(b, payload...)
+}
+
+func TestParseProxyHeaderV1(t *testing.T) {
+	data := []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nGET /")
+	h, n, err := parseProxyHeader(data)
+	require.NoError(t, err)
+	require.NotNil(t, h)
+	assert.Equal(t, "GET /", string(data[n:]))
+	assert.Equal(t, 1, h.Version)
+	assert.False(t, h.Local)
+	assert.Equal(t, "192.168.0.1:56324", h.SourceAddr.String())
+	assert.Equal(t, "192.168.0.11:443", h.DestinationAddr.String())
+
+	h, _, err = parseProxyHeader([]byte("PROXY TCP6 2001:db8::1 ::1 1 65535\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:1", h.SourceAddr.String())
+	assert.Equal(t, "[::1]:65535", h.DestinationAddr.String())
+
+	h, n, err = parseProxyHeader([]byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"))
+	require.NoError(t, err)
+	assert.True(t, h.Local)
+	assert.Nil(t, h.SourceAddr)
+	assert.Equal(t, 35, n)
+
+	// Incomplete headers.
+	for _, s := range []string{"P", "PROXY", "PROXY TCP4 192.168.0.1"} {
+		h, n, err = parseProxyHeader([]byte(s))
+		assert.NoError(t, err, s)
+		assert.Nil(t, h, s)
+		assert.Zero(t, n, s)
+	}
+
+	// Malformed headers.
+	for _, s := range []string{
+		"PROXY TCP5 192.168.0.1 192.168.0.11 56324 443\r\n",
+		"PROXY TCP4 2001:db8::1 192.168.0.11 56324 443\r\n",
+		"PROXY TCP4 192.168.0.1 192.168.0.11 56324 65536\r\n",
+		"PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n",
+		"PROXY TCP4 192.168.0.1  192.168.0.11 56324 443\r\n",
+		"PROXY " + string(make([]byte, proxyV1MaxLength)),
+	} {
+		_, _, err = parseProxyHeader([]byte(s))
+		assert.ErrorIs(t, err, errorx.ErrInvalidProxyHeader, s)
+	}
+
+	_, _, err = parseProxyHeader([]byte("GET / HTTP/1.1\r\n"))
+	assert.ErrorIs(t, err, errNoProxyHeader)
+}
+
+func TestParseProxyHeaderV2(t *testing.T) {
+	addrs := []byte{
+		10, 0, 0, 1, // source address
+		10, 0, 0, 2, // destination address
+		0x1f, 0x90, // source port
+		0x01, 0xbb, // destination port
+	}
+	data := proxyHeaderV2(0x1, 0x11, addrs,
+		ProxyTLV{Type: ProxyTLVTypeALPN, Value: []byte("h2")},
+		ProxyTLV{Type: ProxyTLVTypeNoop},
+		ProxyTLV{Type: ProxyTLVTypeAuthority, Value: []byte("example.com")})
+	data = append(data, "payload"...)
+	h, n, err := parseProxyHeader(data)
+	require.NoError(t, err)
+	require.NotNil(t, h)
+	assert.Equal(t, "payload", string(data[n:]))
+	assert.Equal(t, 2, h.Version)
+	assert.False(t, h.Local)
+	assert.Equal(t, &net.TCPAddr{IP: net.IP{10, 0, 0, 1}, Port: 8080}, h.SourceAddr)
+	assert.Equal(t, &net.TCPAddr{IP: net.IP{10, 0, 0, 2}, Port: 443}, h.DestinationAddr)
+	require.Len(t, h.TLVs, 3)
+	alpn, ok := h.TLV(ProxyTLVTypeALPN)
+	assert.True(t, ok)
+	assert.Equal(t, "h2", string(alpn))
+	authority, ok := h.TLV(ProxyTLVTypeAuthority)
+	assert.True(t, ok)
+	assert.Equal(t, "example.com", string(authority))
+	_, ok = h.TLV(ProxyTLVTypeUniqueID)
+	assert.False(t, ok)
+
+	// The TLVs must not refer to the data.
+	for i := range data {
+		data[i] = 0
+	}
+	assert.Equal(t, "h2", string(h.TLVs[0].Value))
+
+	// Every prefix of a header is incomplete.
+	data = proxyHeaderV2(0x1, 0x11, addrs, ProxyTLV{Type: ProxyTLVTypeUniqueID, Value: []byte("id")})
+	for i := 1; i < len(data); i++ {
+		h, n, err = parseProxyHeader(data[:i])
+		assert.NoError(t, err)
+		assert.Nil(t, h)
+		assert.Zero(t, n)
+	}
+
+	// IPv6 over UDP.
+	addrs6 := make([]byte, 36)
+	addrs6[15], addrs6[31], addrs6[33], addrs6[35] = 1, 2, 53, 54
+	h, _, err = parseProxyHeader(proxyHeaderV2(0x1, 0x22, addrs6))
+	require.NoError(t, err)
+	assert.Equal(t, "[::1]:53", h.SourceAddr.String())
+	assert.Equal(t, "udp", h.SourceAddr.Network())
+	assert.Equal(t, "[::2]:54", h.DestinationAddr.String())
+
+	// Unix domain sockets.
+	addrsUnix := make([]byte, 216)
+	copy(addrsUnix, "/tmp/src.sock")
+	copy(addrsUnix[108:], "/tmp/dst.sock")
+	h, _, err = parseProxyHeader(proxyHeaderV2(0x1, 0x31, addrsUnix))
+	require.NoError(t, err)
+	assert.Equal(t, &net.UnixAddr{Name: "/tmp/src.sock", Net: "unix"}, h.SourceAddr)
+	assert.Equal(t, &net.UnixAddr{Name: "/tmp/dst.sock", Net: "unix"}, h.DestinationAddr)
+
+	// The addresses of LOCAL are ignored.
+	h, _, err = parseProxyHeader(proxyHeaderV2(0x0, 0x11, addrs))
+	require.NoError(t, err)
+	assert.True(t, h.Local)
+	assert.Nil(t, h.SourceAddr)
+	assert.Nil(t, h.DestinationAddr)
+
+	h, _, err = parseProxyHeader(proxyHeaderV2(0x1, 0x00, nil))
+	require.NoError(t, err)
+	assert.True(t, h.Local)
+
+	// Malformed headers.
+	badVersion := proxyHeaderV2(0x1, 0x11, addrs)
+	badVersion[12] = 0x11
+	truncatedTLV := proxyHeaderV2(0x1, 0x11, append(append([]byte(nil), addrs...), ProxyTLVTypeALPN, 0, 5, 'h'))
+	for _, data := range [][]byte{
+		badVersion,
+		proxyHeaderV2(0x2, 0x11, addrs),
+		proxyHeaderV2(0x1, 0x41, addrs),
+		proxyHeaderV2(0x1, 0x13, addrs),
+		proxyHeaderV2(0x1, 0x21, addrs),
+		truncatedTLV,
+	} {
+		_, _, err = parseProxyHeader(data)
+		assert.ErrorIs(t, err, errorx.ErrInvalidProxyHeader)
+	}
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	_, lan, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	_, ula, err := net.ParseCIDR("fd00::/8")
+	require.NoError(t, err)
+	cidrs := []*net.IPNet{lan, ula}
+
+	assert.True(t, isTrustedProxy(&net.TCPAddr{IP: net.IPv4(192, 168, 0, 1)}, nil))
+	assert.True(t, isTrustedProxy(&net.TCPAddr{IP: net.IPv4(10, 1, 2, 3)}, cidrs))
+	assert.True(t, isTrustedProxy(&net.TCPAddr{IP: net.IP{10, 1, 2, 3}}, cidrs))
+	assert.True(t, isTrustedProxy(&net.TCPAddr{IP: net.ParseIP("fd00::1")}, cidrs))
+	assert.True(t, isTrustedProxy(&net.UnixAddr{Name: "sock", Net: "unix"}, cidrs))
+	assert.False(t, isTrustedProxy(&net.TCPAddr{IP: net.IPv4(192, 168, 0, 1)}, cidrs))
+	assert.False(t, isTrustedProxy(&net.TCPAddr{IP: net.ParseIP("fe80::1")}, cidrs))
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package gnet
+
+import (
+	"net"
+	"time"
+
+	"github.com/panjf2000/gnet/v2/pkg/bs"
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+	"github.com/panjf2000/gnet/v2/pkg/netpoll"
+	bsPool "github.com/panjf2000/gnet/v2/pkg/pool/byteslice"
+)
+
+const defaultProxyHeaderTimeout = 10 * time.Second
+
+// proxyState is the state of a connection whose PROXY protocol header is pending.
+type proxyState struct {
+	buf   []byte         // the beginning of the header that has been read
+	timer *netpoll.Timer // timer for the header timeout
+}
+
+// startProxy starts waiting for the PROXY protocol header of c,
+// or opens c right away if it's not from a trusted proxy.
+func (el *eventloop) startProxy(c *conn) error {
+	opts := c.options()
+	if !isTrustedProxy(c.remoteAddr, opts.ProxyProtocolTrustedCIDRs) {
+		if opts.ProxyProtocol == ProxyProtocolRequired {
+			return el.close(c, errorx.ErrUntrustedProxy)
+		}
+		return el.finishProxy(c, nil, nil)
+	}
+
+	timeout := opts.ProxyProtocolTimeout
+	if timeout <= 0 {
+		timeout = defaultProxyHeaderTimeout
+	}
+	c.proxy.timer = netpoll.NewTimer(timeout, 0, el.proxyTimeout, c)
+	el.poller.AddTimer(c.proxy.timer)
+	return nil
+}
+
+// readProxy consumes the data read from c until the PROXY protocol header is complete.
+func (el *eventloop) readProxy(c *conn, data []byte) error {
+	p := c.proxy
+	if len(p.buf) > 0 {
+		p.buf = append(p.buf, data...)
+		data = p.buf
+	}
+
+	h, n, err := parseProxyHeader(data)
+	switch {
+	case err == errNoProxyHeader && c.options().ProxyProtocol == ProxyProtocolOptional:
+		return el.finishProxy(c, nil, data)
+	case err == errNoProxyHeader:
+		return el.close(c, errorx.ErrInvalidProxyHeader)
+	case err != nil:
+		return el.close(c, err)
+	case h == nil:
+		if len(p.buf) == 0 {
+			p.buf = append(p.buf, data...)
+		}
+		return nil
+	}
+	return el.finishProxy(c, h, data[n:])
+}
+
+func (el *eventloop) proxyTimeout(a any) error {
+	c := a.(*conn)
+	if c.proxy == nil || el.connections.getConn(c.fd) != c {
+		return nil // ignore stale connections
+	}
+	if c.options().ProxyProtocol == ProxyProtocolOptional {
+		// Whatever has been read isn't a header as it's never completed.
+		return el.finishProxy(c, nil, c.proxy.buf)
+	}
+	return el.close(c, errorx.ErrProxyHeaderTimeout)
+}
+
+// finishProxy applies the PROXY protocol header h to c if it's not nil and opens c,
+// rest is the data that follows the header.
+func (el *eventloop) finishProxy(c *conn, h *ProxyHeader, rest []byte) error {
+	if c.proxy.timer != nil {
+		el.poller.DelTimer(c.proxy.timer)
+	}
+	c.proxy = nil
+	if h != nil {
+		c.proxyHeader = h
+		if h.SourceAddr != nil {
+			if addr, ok := c.remoteAddr.(*net.TCPAddr); ok && len(addr.Zone) > 0 {
+				bsPool.Put(bs.StringToBytes(addr.Zone))
+			}
+			c.remoteAddr = h.SourceAddr
+		}
+		if h.DestinationAddr != nil {
+			c.localAddr = h.DestinationAddr
+		}
+	}
+
+	if c.tls != nil {
+		c.tls.laddr, c.tls.raddr = c.localAddr, c.remoteAddr
+		if len(rest) > 0 {
+			c.tls.feed(rest)
+		}
+		return el.startHandshake(c)
+	}
+
+	if err := el.open(c); err != nil || !c.opened || len(rest) == 0 {
+		return err
+	}
+	c.buffer = rest
+	action, err := el.onTraffic(c)
+	switch action {
+	case None:
+	case Close:
+		return el.close(c, err)
+	case Shutdown:
+		return errorx.ErrEngineShutdown
+	}
+	_, _ = c.inboundBuffer.Write(c.buffer)
+	c.buffer = c.buffer[:0]
+	return nil
+}
+
+func (c *conn) ProxyHeader() *ProxyHeader {
+	return c.proxyHeader
+}
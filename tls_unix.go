@@ -0,0 +1,428 @@
+// Copyright (c) 2026 The Gnet Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package gnet
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+	"github.com/panjf2000/gnet/v2/pkg/netpoll"
+	bsPool "github.com/panjf2000/gnet/v2/pkg/pool/byteslice"
+	"github.com/panjf2000/gnet/v2/pkg/pool/goroutine"
+	"github.com/panjf2000/gnet/v2/pkg/queue"
+)
+
+const (
+	defaultTLSHandshakeTimeout   = 5 * time.Second
+	defaultMaxTLSHandshakes      = 4096
+	defaultMaxTLSHandshakesPerIP = 64
+
+	tlsRecordHeaderLen     = 5
+	tlsRecordTypeHandshake = 22
+	tlsMaxCiphertext       = 16384 + 2048
+)
+
+// errTLSWouldBlock is returned by tlsConn.Read when there is no more ciphertext
+// to consume, crypto/tls treats it as a temporary error and keeps the incomplete
+// record for the next Read.
+var errTLSWouldBlock net.Error = wouldBlockError{}
+
+type wouldBlockError struct{}
+
+func (wouldBlockError) Error() string   { return "gnet: no more TLS records available" }
+func (wouldBlockError) Timeout() bool   { return false }
+func (wouldBlockError) Temporary() bool { return true }
+
+// tlsConn is the TLS record layer of a stream-oriented connection, it implements
+// net.Conn on top of in-memory buffers for crypto/tls: the ciphertext read from
+// the socket is fed to it by the event-loop, and the ciphertext produced by
+// crypto/tls is written to the socket via the underlying conn.
+//
+// The handshake of crypto/tls can't be resumed once it's interrupted, so each
+// handshake runs on a goroutine of its own, where Read blocks until the event-loop
+// feeds more data. A server doesn't start that goroutine until the first record of
+// the ClientHello has arrived, and the goroutines are capped by MaxTLSHandshakes and
+// MaxTLSHandshakesPerIP. Once the handshake completes, Read never blocks and all
+// reads and writes take place on the event-loop.
+type tlsConn struct {
+	c        *conn
+	conn     *tls.Conn
+	laddr    net.Addr
+	raddr    net.Addr
+	client   bool
+	timeout  time.Duration
+	deadline time.Time // deadline of the handshake, set when it's started
+
+	timer   *netpoll.Timer // timer for the ClientHello, only accessed on the event-loop
+	started bool           // whether the handshake is running on a goroutine, only accessed on the event-loop
+	limited bool           // whether the handshake is counted in MaxTLSHandshakes
+	perIP   bool           // whether the handshake is also counted in MaxTLSHandshakesPerIP
+	ip      [16]byte       // remote IP of the handshake counted in MaxTLSHandshakesPerIP
+
+	mu          sync.Mutex
+	cond        sync.Cond
+	in          bytes.Buffer // ciphertext from the remote that hasn't been consumed
+	handshaking bool         // whether the handshake is in progress
+	err         error        // error that interrupts the blocking Read
+
+	notified     bool // whether OnHandshake has been fired, only accessed on the event-loop
+	once         sync.Once
+	done         func() // invoked once the handshake completes, set by the client
+	handshakeErr error  // result of the handshake, only valid after done is invoked
+}
+
+func newTLSConn(c *conn, config *tls.Config, isClient bool) *tlsConn {
+	tc := &tlsConn{
+		c:           c,
+		laddr:       c.localAddr,
+		raddr:       c.remoteAddr,
+		client:      isClient,
+		timeout:     c.options().TLSHandshakeTimeout,
+		handshaking: true,
+	}
+	if tc.timeout <= 0 {
+		tc.timeout = defaultTLSHandshakeTimeout
+	}
+	tc.cond.L = &tc.mu
+	if isClient {
+		tc.conn = tls.Client(tc, config)
+	} else {
+		tc.conn = tls.Server(tc, config)
+	}
+	return tc
+}
+
+func (tc *tlsConn) Read(b []byte) (int, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for tc.in.Len() == 0 {
+		if tc.err != nil {
+			return 0, tc.err
+		}
+		if !tc.handshaking {
+			return 0, errTLSWouldBlock
+		}
+		tc.cond.Wait()
+	}
+	return tc.in.Read(b)
+}
+
+func (tc *tlsConn) Write(b []byte) (int, error) {
+	tc.mu.Lock()
+	handshaking, err := tc.handshaking, tc.err
+	tc.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	if handshaking {
+		// Hand the handshake messages over to the event-loop,
+		// crypto/tls may reuse b after Write returns.
+		data := bsPool.Get(len(b))
+		copy(data, b)
+		return len(b), tc.c.loop.poller.Trigger(queue.HighPriority, tc.writeHandshake, data)
+	}
+	return tc.c.write(b)
+}
+
+func (tc *tlsConn) writeHandshake(a any) error {
+	data := a.([]byte)
+	defer bsPool.Put(data)
+	if tc.c.tls != tc {
+		return nil // the connection has been closed
+	}
+	_, _ = tc.c.write(data)
+	return nil
+}
+
+func (tc *tlsConn) Close() error {
+	tc.abort(net.ErrClosed)
+	return nil
+}
+
+func (tc *tlsConn) LocalAddr() net.Addr                { return tc.laddr }
+func (tc *tlsConn) RemoteAddr() net.Addr               { return tc.raddr }
+func (tc *tlsConn) SetDeadline(_ time.Time) error      { return nil }
+func (tc *tlsConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (tc *tlsConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+// feed appends the ciphertext read from the socket.
+func (tc *tlsConn) feed(data []byte) {
+	tc.mu.Lock()
+	_, _ = tc.in.Write(data)
+	tc.mu.Unlock()
+	tc.cond.Signal()
+}
+
+// recordBuffered reports whether a whole TLS record has been fed, or whatever has been
+// fed can't be the beginning of a handshake record, which crypto/tls will reject.
+func (tc *tlsConn) recordBuffered() bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	b := tc.in.Bytes()
+	if len(b) < tlsRecordHeaderLen {
+		return false
+	}
+	n := int(binary.BigEndian.Uint16(b[3:tlsRecordHeaderLen]))
+	return b[0] != tlsRecordTypeHandshake || n > tlsMaxCiphertext || len(b) >= tlsRecordHeaderLen+n
+}
+
+// abort interrupts the pending and future reads with err.
+func (tc *tlsConn) abort(err error) {
+	tc.mu.Lock()
+	if tc.err == nil {
+		tc.err = err
+	}
+	tc.mu.Unlock()
+	tc.cond.Broadcast()
+}
+
+// handshake performs the TLS handshake, it must be run on a separate goroutine.
+func (tc *tlsConn) handshake() {
+	el := tc.c.loop
+	ctx, cancel := context.WithDeadline(context.Background(), tc.deadline)
+	err := tc.conn.HandshakeContext(ctx)
+	cancel()
+	if tc.limited {
+		el.engine.handshakes.release(tc.ip, tc.perIP)
+	}
+	if e := el.poller.Trigger(queue.HighPriority, func(_ any) error {
+		return el.handshakeDone(tc, err)
+	}, nil); e != nil {
+		el.getLogger().Errorf("failed to enqueue the result of TLS handshake to poller: %v", e)
+		tc.finish(e)
+	}
+}
+
+// finish reports the result of the handshake to whoever is waiting for it,
+// only the first call takes effect.
+func (tc *tlsConn) finish(err error) {
+	tc.once.Do(func() {
+		tc.handshakeErr = err
+		if tc.done != nil {
+			tc.done()
+		}
+	})
+}
+
+// startHandshake starts the TLS handshake of c. A client runs it right away, whereas a server
+// waits for the first record of the ClientHello on the event-loop, so that the peers that never
+// send anything don't take up the goroutine pool.
+func (el *eventloop) startHandshake(c *conn) error {
+	tc := c.tls
+	tc.deadline = time.Now().Add(tc.timeout)
+	if tc.client || tc.recordBuffered() {
+		return el.runHandshake(c)
+	}
+	tc.timer = netpoll.NewTimer(tc.timeout, 0, el.handshakeTimeout, tc)
+	el.poller.AddTimer(tc.timer)
+	return nil
+}
+
+// handshakeTimeout closes the connection whose ClientHello hasn't arrived in time.
+func (el *eventloop) handshakeTimeout(a any) error {
+	tc := a.(*tlsConn)
+	if tc.c.tls != tc || tc.started {
+		return nil // ignore stale handshakes
+	}
+	tc.timer = nil
+	return el.close(tc.c, context.DeadlineExceeded)
+}
+
+// runHandshake runs the handshake of c on the global goroutine pool,
+// c is closed if MaxTLSHandshakes or MaxTLSHandshakesPerIP has been reached.
+func (el *eventloop) runHandshake(c *conn) error {
+	tc := c.tls
+	if tc.timer != nil {
+		el.poller.DelTimer(tc.timer)
+		tc.timer = nil
+	}
+	tc.started = true
+	eng := el.engine
+	if !tc.client {
+		limit := eng.opts.MaxTLSHandshakes
+		if limit <= 0 {
+			limit = defaultMaxTLSHandshakes
+		}
+		limitPerIP := eng.opts.MaxTLSHandshakesPerIP
+		if limitPerIP <= 0 {
+			limitPerIP = defaultMaxTLSHandshakesPerIP
+		}
+		tc.ip, tc.perIP = handshakeKey(c.remoteAddr)
+		if !eng.handshakes.acquire(tc.ip, tc.perIP, limit, limitPerIP) {
+			return el.close(c, errorx.ErrTooManyHandshakes)
+		}
+		tc.limited = true
+	}
+	if err := goroutine.DefaultWorkerPool.Submit(tc.handshake); err != nil {
+		if tc.limited {
+			eng.handshakes.release(tc.ip, tc.perIP)
+			tc.limited = false
+		}
+		return el.close(c, err)
+	}
+	return nil
+}
+
+// tlsHandshakes keeps track of the TLS handshakes in progress on a server
+// for MaxTLSHandshakes and MaxTLSHandshakesPerIP.
+type tlsHandshakes struct {
+	mu    sync.Mutex
+	total int32              // number of the handshakes in progress
+	perIP map[[16]byte]int32 // number of the handshakes in progress of each remote IP
+}
+
+// handshakeKey returns the IP of addr in the 16-byte form,
+// it reports false for the addresses that are not limited per IP.
+func handshakeKey(addr net.Addr) (key [16]byte, ok bool) {
+	a, ok := addr.(*net.TCPAddr)
+	if !ok || a.IP.To16() == nil {
+		return key, false
+	}
+	copy(key[:], a.IP.To16())
+	return key, true
+}
+
+// acquire counts in a handshake, from key as well if perIP is true, unless there are
+// limit ones in progress already, or limitPerIP ones from key.
+func (h *tlsHandshakes) acquire(key [16]byte, perIP bool, limit, limitPerIP int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total >= int32(limit) || (perIP && h.perIP[key] >= int32(limitPerIP)) {
+		return false
+	}
+	h.total++
+	if !perIP {
+		return true
+	}
+	if h.perIP == nil {
+		h.perIP = make(map[[16]byte]int32)
+	}
+	h.perIP[key]++
+	return true
+}
+
+// release counts out a handshake, from key as well if perIP is true.
+func (h *tlsHandshakes) release(key [16]byte, perIP bool) {
+	h.mu.Lock()
+	h.total--
+	if perIP {
+		if h.perIP[key]--; h.perIP[key] <= 0 {
+			delete(h.perIP, key)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func (el *eventloop) handshakeDone(tc *tlsConn, err error) error {
+	c := tc.c
+	if c.tls != tc {
+		if err == nil {
+			err = net.ErrClosed
+		}
+		tc.finish(err)
+		return nil // the connection has been closed during the handshake
+	}
+
+	tc.mu.Lock()
+	tc.handshaking = false
+	tc.mu.Unlock()
+
+	tc.notified = true
+	action := None
+	if h, ok := c.handler().(HandshakeHandler); ok {
+		action = h.OnHandshake(c, err)
+	}
+	if err == nil && action == Close {
+		err = net.ErrClosed
+	}
+	defer tc.finish(err)
+
+	if err != nil {
+		return el.close(c, err)
+	}
+	if action == Shutdown {
+		return errorx.ErrEngineShutdown
+	}
+	if err = el.open(c); err != nil || !c.opened {
+		return err
+	}
+	// Process the application data that arrived along with the final handshake messages.
+	return el.readTLS(c, nil)
+}
+
+// abortHandshake reports err as the result of the handshake
+// for a connection that is closed before its handshake completes.
+func (el *eventloop) abortHandshake(c *conn, err error) {
+	tc := c.tls
+	if tc.notified {
+		return
+	}
+	tc.notified = true
+	if err == nil {
+		err = net.ErrClosed
+	}
+	if h, ok := c.handler().(HandshakeHandler); ok {
+		_ = h.OnHandshake(c, err)
+	}
+	tc.finish(err)
+}
+
+// readTLS feeds the ciphertext to the TLS record layer
+// and hands the decrypted bytes over to the event handler.
+func (el *eventloop) readTLS(c *conn, data []byte) error {
+	tc := c.tls
+	tc.feed(data)
+	if !c.opened {
+		if !tc.started && tc.recordBuffered() {
+			return el.runHandshake(c)
+		}
+		return nil // the handshake is in progress
+	}
+
+	for {
+		n, err := tc.conn.Read(el.buffer)
+		if n > 0 {
+			c.buffer = el.buffer[:n]
+			action, e := el.onTraffic(c)
+			switch action {
+			case None:
+			case Close:
+				return el.close(c, e)
+			case Shutdown:
+				return errorx.ErrEngineShutdown
+			}
+			_, _ = c.inboundBuffer.Write(c.buffer)
+			c.buffer = c.buffer[:0]
+			if c.readPause != 0 {
+				return nil // the rest of records will be decrypted after reading is resumed
+			}
+		}
+		if err != nil {
+			if errors.Is(err, errTLSWouldBlock) {
+				return nil
+			}
+			return el.close(c, err)
+		}
+	}
+}
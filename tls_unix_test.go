@@ -0,0 +1,449 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package gnet
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errorx "github.com/panjf2000/gnet/v2/pkg/errors"
+	goPool "github.com/panjf2000/gnet/v2/pkg/pool/goroutine"
+)
+
+func newTestCertificate(t *testing.T, host string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(crand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestTLS(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testTLS(t, "tcp", ":9905", false)
+	})
+	t.Run("tcp-et", func(t *testing.T) {
+		testTLS(t, "tcp", ":9906", true)
+	})
+	t.Run("unix", func(t *testing.T) {
+		testTLS(t, "unix", testUnixAddr(t), false)
+	})
+}
+
+type testTLSServer struct {
+	BuiltinEventEngine
+	tester        *testing.T
+	network, addr string
+	roots         *x509.CertPool
+	eng           Engine
+
+	handshakes int32
+	failures   int32
+	opened     int32
+	closed     int32
+}
+
+func (s *testTLSServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	err := goPool.DefaultWorkerPool.Submit(s.runClients)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testTLSServer) OnHandshake(c Conn, err error) (action Action) {
+	if err != nil {
+		atomic.AddInt32(&s.failures, 1)
+		return
+	}
+	state, ok := c.ConnectionState()
+	assert.True(s.tester, ok)
+	assert.True(s.tester, state.HandshakeComplete)
+	atomic.AddInt32(&s.handshakes, 1)
+	return
+}
+
+func (s *testTLSServer) OnOpen(c Conn) (out []byte, action Action) {
+	state, ok := c.ConnectionState()
+	assert.True(s.tester, ok)
+	assert.True(s.tester, state.HandshakeComplete, "OnOpen fired before the handshake completes")
+	atomic.AddInt32(&s.opened, 1)
+	return
+}
+
+func (s *testTLSServer) OnTraffic(c Conn) (action Action) {
+	buf, err := c.Next(-1)
+	assert.NoError(s.tester, err)
+	_, err = c.Write(buf)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testTLSServer) OnClose(Conn, error) (action Action) {
+	atomic.AddInt32(&s.closed, 1)
+	return
+}
+
+func (s *testTLSServer) dial(config *tls.Config) (*tls.Conn, error) {
+	return tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, s.network, s.addr, config)
+}
+
+func (s *testTLSServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	payload := make([]byte, 256*1024)
+	_, err := crand.Read(payload)
+	assert.NoError(s.tester, err)
+
+	// SNI-based certificate selection, ALPN and session resumption with crypto/tls clients.
+	config := &tls.Config{
+		ServerName:         "example.com",
+		RootCAs:            s.roots,
+		NextProtos:         []string{"h2"},
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+	for i := 0; i < 2; i++ {
+		c, err := s.dial(config)
+		if !assert.NoError(s.tester, err) {
+			return
+		}
+		state := c.ConnectionState()
+		assert.Equal(s.tester, "h2", state.NegotiatedProtocol)
+		assert.Equal(s.tester, "example.com", state.PeerCertificates[0].Subject.CommonName)
+		assert.Equal(s.tester, i > 0, state.DidResume, "session resumption")
+		_, err = c.Write(payload)
+		assert.NoError(s.tester, err)
+		buf := make([]byte, len(payload))
+		_, err = io.ReadFull(c, buf)
+		assert.NoError(s.tester, err)
+		assert.True(s.tester, bytes.Equal(payload, buf))
+		assert.NoError(s.tester, c.Close())
+	}
+
+	// Untrusted certificate.
+	_, err = s.dial(&tls.Config{ServerName: "localhost"})
+	assert.Error(s.tester, err)
+
+	// Handshake timeout.
+	c, err := net.Dial(s.network, s.addr)
+	if assert.NoError(s.tester, err) {
+		_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = c.Read(make([]byte, 1))
+		assert.ErrorIs(s.tester, err, io.EOF)
+		_ = c.Close()
+	}
+
+	// gnet client.
+	config = &tls.Config{
+		RootCAs:    s.roots,
+		NextProtos: []string{"http/1.1"},
+	}
+	addr := s.addr
+	if s.network == "unix" {
+		config.ServerName = "localhost"
+	} else {
+		addr = "localhost" + addr // the ServerName is derived from the address
+	}
+	events := &testTLSClient{tester: s.tester, payload: payload, done: make(chan struct{})}
+	cli, err := NewClient(events, WithTLSConfig(config))
+	assert.NoError(s.tester, err)
+	assert.NoError(s.tester, cli.Start())
+	defer cli.Stop() //nolint:errcheck
+	_, err = cli.Dial(s.network, addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	select {
+	case <-events.done:
+	case <-time.After(5 * time.Second):
+		s.tester.Error("timeout waiting for the echo of gnet client")
+	}
+	config.ServerName = "example.org"
+	_, err = cli.Dial(s.network, s.addr)
+	assert.Error(s.tester, err, "mismatched server name")
+}
+
+type testTLSClient struct {
+	BuiltinEventEngine
+	tester  *testing.T
+	payload []byte
+	done    chan struct{}
+}
+
+func (cli *testTLSClient) OnOpen(c Conn) (out []byte, action Action) {
+	state, ok := c.ConnectionState()
+	assert.True(cli.tester, ok)
+	assert.Equal(cli.tester, "http/1.1", state.NegotiatedProtocol)
+	assert.Equal(cli.tester, "localhost", state.PeerCertificates[0].Subject.CommonName)
+	return cli.payload, None
+}
+
+func (cli *testTLSClient) OnTraffic(c Conn) (action Action) {
+	if c.InboundBuffered() < len(cli.payload) {
+		return
+	}
+	buf, err := c.Next(-1)
+	assert.NoError(cli.tester, err)
+	assert.True(cli.tester, bytes.Equal(cli.payload, buf))
+	close(cli.done)
+	return
+}
+
+func testTLS(t *testing.T, network, addr string, et bool) {
+	localhost, rootA := newTestCertificate(t, "localhost")
+	example, rootB := newTestCertificate(t, "example.com")
+	roots := x509.NewCertPool()
+	roots.AddCert(rootA)
+	roots.AddCert(rootB)
+	config := &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "example.com" {
+				return &example, nil
+			}
+			return &localhost, nil
+		},
+	}
+
+	events := &testTLSServer{tester: t, network: network, addr: addr, roots: roots}
+	err := Run(events, network+"://"+addr,
+		WithTLSConfig(config),
+		WithTLSHandshakeTimeout(200*time.Millisecond),
+		WithEdgeTriggeredIO(et),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&events.handshakes))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&events.failures))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&events.opened))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&events.closed))
+}
+
+func TestTLSHandshakeLimit(t *testing.T) {
+	t.Run("per-ip", func(t *testing.T) {
+		testTLSHandshakeLimit(t, WithMaxTLSHandshakesPerIP(1))
+	})
+	t.Run("engine-wide", func(t *testing.T) {
+		testTLSHandshakeLimit(t, WithMaxTLSHandshakes(1))
+	})
+}
+
+func testTLSHandshakeLimit(t *testing.T, limit Option) {
+	cert, root := newTestCertificate(t, "localhost")
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	svr := &testTLSHandshakeLimitServer{tester: t, addr: "127.0.0.1:9968", roots: roots}
+	err := Run(svr, "tcp://"+svr.addr,
+		WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+		limit,
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&svr.rejected))
+}
+
+type testTLSHandshakeLimitServer struct {
+	BuiltinEventEngine
+	tester   *testing.T
+	addr     string
+	roots    *x509.CertPool
+	eng      Engine
+	rejected int32
+}
+
+func (s *testTLSHandshakeLimitServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	err := goPool.DefaultWorkerPool.Submit(s.runClients)
+	assert.NoError(s.tester, err)
+	return
+}
+
+func (s *testTLSHandshakeLimitServer) OnHandshake(_ Conn, err error) (action Action) {
+	if errors.Is(err, errorx.ErrTooManyHandshakes) {
+		atomic.AddInt32(&s.rejected, 1)
+	}
+	return
+}
+
+// running returns the number of the handshakes in progress, and the number of the
+// ones from the loopback address, which are the same in this test.
+func (s *testTLSHandshakeLimitServer) running() (int32, int32) {
+	key, _ := handshakeKey(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	h := &s.eng.eng.handshakes
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total, h.perIP[key]
+}
+
+// stalledConn fails every read, so that the handshake of crypto/tls gives up
+// right after sending the ClientHello without closing the connection.
+type stalledConn struct {
+	net.Conn
+}
+
+func (stalledConn) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func (s *testTLSHandshakeLimitServer) runClients() {
+	defer func() {
+		assert.NoError(s.tester, s.eng.Stop(context.Background()))
+	}()
+
+	config := &tls.Config{ServerName: "localhost", RootCAs: s.roots}
+	dial := func() error {
+		c, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", s.addr, config)
+		if err == nil {
+			_ = c.Close()
+		}
+		return err
+	}
+
+	// The peer that never sends its ClientHello doesn't count.
+	idle, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer idle.Close()
+	assert.NoError(s.tester, dial())
+	// The server may still be finishing the handshake after the client is done with it.
+	assert.Eventually(s.tester, func() bool {
+		total, perIP := s.running()
+		return total == 0 && perIP == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(s.tester, atomic.LoadInt32(&s.rejected))
+
+	// The peer that stalls after its ClientHello takes up the only handshake allowed.
+	raw, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	assert.Error(s.tester, tls.Client(stalledConn{raw}, config).Handshake())
+	assert.Eventually(s.tester, func() bool {
+		total, perIP := s.running()
+		return total == 1 && perIP == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Error(s.tester, dial())
+	assert.EqualValues(s.tester, 1, atomic.LoadInt32(&s.rejected))
+
+	// The handshakes are admitted again once the stalled one is gone.
+	_ = raw.Close()
+	assert.Eventually(s.tester, func() bool {
+		total, perIP := s.running()
+		return total == 0 && perIP == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(s.tester, dial())
+}
+
+func TestTLSPendingDeadline(t *testing.T) {
+	cert, root := newTestCertificate(t, "localhost")
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	svr := &testTLSPendingDeadlineServer{tester: t, addr: "127.0.0.1:9975", roots: roots}
+	err := Run(svr, "tcp://"+svr.addr,
+		WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+		WithTicker(true),
+		WithReuseAddr(true))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, svr.closeErr, errorx.ErrReadDeadlineExceeded)
+}
+
+type testTLSPendingDeadlineServer struct {
+	BuiltinEventEngine
+	tester   *testing.T
+	addr     string
+	roots    *x509.CertPool
+	eng      Engine
+	started  bool
+	closeErr error
+}
+
+func (s *testTLSPendingDeadlineServer) OnBoot(eng Engine) (action Action) {
+	s.eng = eng
+	return
+}
+
+func (s *testTLSPendingDeadlineServer) OnTick() (delay time.Duration, action Action) {
+	if !s.started {
+		s.started = true
+		err := goPool.DefaultWorkerPool.Submit(s.runClient)
+		assert.NoError(s.tester, err)
+	}
+	delay = time.Minute
+	return
+}
+
+func (s *testTLSPendingDeadlineServer) OnClose(_ Conn, err error) (action Action) {
+	s.closeErr = err
+	return Shutdown
+}
+
+// setPendingDeadline sets the read deadline of the connection whose TLS handshake is pending.
+func (s *testTLSPendingDeadlineServer) setPendingDeadline(el *eventloop, d time.Duration) bool {
+	found := make(chan bool, 1)
+	err := el.Execute(context.Background(), RunnableFunc(func(context.Context) error {
+		var c *conn
+		el.connections.iterate(func(gc *conn) bool {
+			if !gc.opened && gc.tls != nil {
+				c = gc
+			}
+			return c == nil
+		})
+		if c != nil {
+			assert.NoError(s.tester, c.SetReadDeadline(time.Now().Add(d)))
+		}
+		found <- c != nil
+		return nil
+	}))
+	return assert.NoError(s.tester, err) && <-found
+}
+
+func (s *testTLSPendingDeadlineServer) runClient() {
+	raw, err := net.Dial("tcp", s.addr)
+	if !assert.NoError(s.tester, err) {
+		return
+	}
+	defer raw.Close()
+
+	// The deadline set before the handshake takes effect once the connection is opened.
+	var el *eventloop
+	s.eng.eng.eventLoops.iterate(func(_ int, l *eventloop) bool {
+		el = l
+		return false
+	})
+	assert.Eventually(s.tester, func() bool {
+		return s.setPendingDeadline(el, 100*time.Millisecond)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_ = raw.SetDeadline(time.Now().Add(5 * time.Second))
+	c := tls.Client(raw, &tls.Config{ServerName: "localhost", RootCAs: s.roots})
+	assert.NoError(s.tester, c.Handshake())
+	_, err = c.Read(make([]byte, 1))
+	assert.ErrorIs(s.tester, err, io.EOF)
+}